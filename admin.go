@@ -0,0 +1,39 @@
+package keystone
+
+import (
+	"net/http"
+	"time"
+)
+
+// defaultAdminCacheTime is the default of Auth.AdminCacheTime
+const defaultAdminCacheTime = 30 * time.Second
+
+// AdminOnly returns a handler for mounting sensitive endpoint trees like /admin or /debug.
+// It validates tokens like the handler returned by Handler, but regardless of RejectUnauthenticated, Routes and Classes
+// requests without a valid token are rejected with 401 and tokens lacking all of AdminRoles with 403.
+// Tokens are cached for at most AdminCacheTime, so revoked admin roles take effect sooner.
+//
+//	mux.Handle("/", auth.Handler(app))
+//	mux.Handle("/admin/", auth.AdminOnly(adminHandler))
+func (a *Auth) AdminOnly(h http.Handler) http.Handler {
+	if a.Endpoint == "" && !a.OfflineMode {
+		panic(ErrNoEndpoint)
+	}
+	a.ensureDefaults()
+	roles := a.AdminRoles
+	if len(roles) == 0 {
+		roles = []string{"admin"}
+	}
+	cacheTime := a.AdminCacheTime
+	if cacheTime <= 0 {
+		cacheTime = defaultAdminCacheTime
+	}
+	if cacheTime > a.CacheTime {
+		cacheTime = a.CacheTime
+	}
+	return &handler{Auth: a, handler: h, class: &ClassOptions{
+		Access:     TokenRequired,
+		Roles:      roles,
+		Validation: ValidationOptions{CacheTime: cacheTime},
+	}}
+}
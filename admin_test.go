@@ -0,0 +1,57 @@
+package keystone
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminOnly(t *testing.T) {
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Subject-Token") {
+		case "admin":
+			w.Write([]byte(`{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u-1"}, "roles": [{"name": "admin"}]}}`))
+		case "member":
+			w.Write([]byte(`{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u-2"}, "roles": [{"name": "member"}]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer idServer.Close()
+
+	cache := ttlCache{}
+	a := New(idServer.URL)
+	a.TokenCache = cache
+	//Routes and Classes don't apply to admin handlers
+	a.Routes = []Route{{Pattern: "/**", Access: Anonymous}}
+	h := a.AdminOnly(okHandler)
+
+	for token, status := range map[string]int{"": 401, "invalid": 401, "member": 403, "admin": 200} {
+		req := httptest.NewRequest("GET", "/admin/users", nil)
+		if token != "" {
+			req.Header.Set("X-Auth-Token", token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Errorf("Expected status %d for token %q, got %d", status, token, rec.Code)
+		}
+	}
+	if ttl := cache[hashToken("admin")]; ttl != defaultAdminCacheTime {
+		t.Errorf("Expected token to be cached for %s, got %s", defaultAdminCacheTime, ttl)
+	}
+
+	a.AdminRoles = []string{"cloud_admin"}
+	a.CacheTime = 10 * defaultAdminCacheTime
+	a.AdminCacheTime = 20 * defaultAdminCacheTime
+	req := httptest.NewRequest("GET", "/admin/users", nil)
+	req.Header.Set("X-Auth-Token", "admin")
+	rec := httptest.NewRecorder()
+	a.AdminOnly(okHandler).ServeHTTP(rec, req)
+	if rec.Code != 403 {
+		t.Errorf("Expected token without AdminRoles to be rejected, got %d", rec.Code)
+	}
+	if ttl := cache[hashToken("admin")]; ttl != a.CacheTime {
+		t.Errorf("Expected AdminCacheTime to be capped by CacheTime, got %s", ttl)
+	}
+}
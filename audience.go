@@ -0,0 +1,44 @@
+package keystone
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrAudience is returned for tokens rejected by Auth.VerifyAudience
+var ErrAudience = errors.New("Token is not intended for this service")
+
+// AudienceClaim returns a check for Auth.VerifyAudience accepting tokens whose payload contains the
+// field claim with any of the given audiences. The field may either hold a single string or a list of strings.
+// Tokens without the field are rejected.
+//
+//	auth.VerifyAudience = keystone.AudienceClaim("audience", "object-store")
+func AudienceClaim(claim string, audiences ...string) func(token *Token, payload json.RawMessage) error {
+	return func(_ *Token, payload json.RawMessage) error {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(payload, &fields); err != nil {
+			return err
+		}
+		raw, ok := fields[claim]
+		if !ok {
+			return fmt.Errorf("token has no %s claim", claim)
+		}
+		var values []string
+		if err := json.Unmarshal(raw, &values); err != nil {
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return fmt.Errorf("invalid %s claim: %s", claim, raw)
+			}
+			values = []string{value}
+		}
+		for _, have := range values {
+			for _, want := range audiences {
+				if have == want {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("token is intended for %v", values)
+	}
+}
@@ -0,0 +1,34 @@
+package keystone
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAudienceClaim(t *testing.T) {
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		audience := map[string]string{
+			"single": `"audience": "object-store"`,
+			"list":   `"audience": ["compute", "object-store"]`,
+			"other":  `"audience": "compute"`,
+			"none":   `"methods": ["password"]`,
+		}[r.Header.Get("X-Subject-Token")]
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z", `+audience+`}}`)
+	}))
+	defer idServer.Close()
+
+	a := Auth{Endpoint: idServer.URL, VerifyAudience: AudienceClaim("audience", "object-store")}
+	a.Handler(okHandler)
+	for token, valid := range map[string]bool{"single": true, "list": true, "other": false, "none": false} {
+		_, err := a.Validate(token)
+		if valid && err != nil {
+			t.Errorf("Expected token %s to be accepted, got %v", token, err)
+		}
+		if !valid && !errors.Is(err, ErrAudience) {
+			t.Errorf("Expected token %s to be rejected with ErrAudience, got %v", token, err)
+		}
+	}
+}
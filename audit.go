@@ -0,0 +1,290 @@
+package keystone
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cadfEventType is the CADF type URI of audit events
+const cadfEventType = "http://schemas.dmtf.org/cloud/audit/1.0/event"
+
+// AuditEvent is a CADF event describing an API request, like the notifications of keystonemiddleware's audit middleware
+type AuditEvent struct {
+	TypeURI   string    `json:"typeURI"`
+	ID        string    `json:"id"`
+	EventTime time.Time `json:"eventTime"`
+	EventType string    `json:"eventType"`
+	//CADF action derived from the HTTP method, e.g. read, create, update or delete
+	Action string `json:"action"`
+	//success if the response status is below 400, failure otherwise
+	Outcome   string         `json:"outcome"`
+	Initiator AuditInitiator `json:"initiator"`
+	Target    AuditResource  `json:"target"`
+	Observer  AuditResource  `json:"observer"`
+	Reason    AuditReason    `json:"reason"`
+	//HTTP method and path of the request
+	Method      string `json:"method"`
+	RequestPath string `json:"requestPath"`
+}
+
+// AuditInitiator is the identity which sent the request
+type AuditInitiator struct {
+	TypeURI   string `json:"typeURI"`
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	DomainID  string `json:"domain_id,omitempty"`
+	ProjectID string `json:"project_id,omitempty"`
+	//Audit IDs of the token, see Token.AuditIDs
+	AuditIDs []string `json:"audit_ids,omitempty"`
+	//Value of X-Identity-Status
+	IdentityStatus string `json:"identity_status"`
+	Host           struct {
+		Address string `json:"address,omitempty"`
+		Agent   string `json:"agent,omitempty"`
+	} `json:"host"`
+}
+
+// AuditResource identifies the target or observer of an audit event
+type AuditResource struct {
+	TypeURI string `json:"typeURI,omitempty"`
+	ID      string `json:"id,omitempty"`
+	Name    string `json:"name,omitempty"`
+}
+
+// AuditReason carries the response status of an audited request
+type AuditReason struct {
+	ReasonType string `json:"reasonType"`
+	ReasonCode string `json:"reasonCode"`
+}
+
+// AuditSink receives audit events. Emit is called after the response was written, but before the handler
+// returns, so sinks shouldn't block.
+type AuditSink interface {
+	Emit(event AuditEvent)
+}
+
+// AuditSinkFunc adapts a function to an AuditSink
+type AuditSinkFunc func(event AuditEvent)
+
+// Emit calls f(event)
+func (f AuditSinkFunc) Emit(event AuditEvent) {
+	f(event)
+}
+
+// Audit is a middleware emitting an AuditEvent for each request once it completed.
+// It must be placed behind the handler returned by Auth.Handler.
+//
+//	audit := &keystone.Audit{
+//		Sink:   keystone.WriterSink(os.Stdout),
+//		Target: keystone.AuditResource{TypeURI: "service/compute", Name: "nova"},
+//	}
+//	http.ListenAndServe(":3000", auth.Handler(audit.Handler(myApp)))
+type Audit struct {
+	Sink AuditSink
+	//Service the events are reported for, also used as observer
+	Target AuditResource
+	//Also audit requests without a confirmed token, only authenticated requests are audited by default
+	Unauthenticated bool
+}
+
+// Handler returns a http handler for use in a middleware chain.
+func (a *Audit) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, confirmed := TokenFromContext(r.Context())
+		if !confirmed && !a.Unauthenticated {
+			h.ServeHTTP(w, r)
+			return
+		}
+		rw := &responseWriter{ResponseWriter: w}
+		h.ServeHTTP(rw, r)
+		status := rw.status
+		if status == 0 {
+			//net/http sends 200 for handlers not writing anything
+			status = http.StatusOK
+		}
+		a.Sink.Emit(a.event(r, token, status))
+	})
+}
+
+func (a *Audit) event(r *http.Request, token *Token, status int) AuditEvent {
+	e := AuditEvent{
+		TypeURI:     cadfEventType,
+		ID:          newEventID(),
+		EventTime:   time.Now().UTC(),
+		EventType:   "activity",
+		Action:      auditAction(r.Method),
+		Outcome:     "success",
+		Target:      a.Target,
+		Observer:    AuditResource{ID: "target"},
+		Reason:      AuditReason{ReasonType: "HTTP", ReasonCode: strconv.Itoa(status)},
+		Method:      r.Method,
+		RequestPath: r.URL.Path,
+	}
+	if status >= 400 {
+		e.Outcome = "failure"
+	}
+	e.Initiator.TypeURI = "service/security/account/user"
+	e.Initiator.IdentityStatus = r.Header.Get("X-Identity-Status")
+	if e.Initiator.IdentityStatus == "" {
+		e.Initiator.IdentityStatus = "Invalid"
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		e.Initiator.Host.Address = host
+	}
+	e.Initiator.Host.Agent = r.UserAgent()
+	if token != nil {
+		e.Initiator.ID = token.User.ID
+		e.Initiator.Name = token.User.Name
+		e.Initiator.DomainID = token.User.Domain.ID
+		if token.Project != nil {
+			e.Initiator.ProjectID = token.Project.ID
+		}
+		e.Initiator.AuditIDs = token.AuditIDs
+	}
+	return e
+}
+
+// auditAction maps a HTTP method to a CADF action
+func auditAction(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return "read"
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut, http.MethodPatch:
+		return "update"
+	case http.MethodDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// newEventID returns a random UUID
+func newEventID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	s := hex.EncodeToString(b[:])
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// WriterSink writes audit events as JSON lines to w, e.g. a log file
+func WriterSink(w io.Writer) AuditSink {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return AuditSinkFunc(func(event AuditEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(event)
+	})
+}
+
+// ChannelSink delivers audit events to ch. Events are dropped if ch is full.
+func ChannelSink(ch chan<- AuditEvent) AuditSink {
+	return AuditSinkFunc(func(event AuditEvent) {
+		select {
+		case ch <- event:
+		default:
+		}
+	})
+}
+
+// UDPSink sends each audit event as JSON datagram, e.g. to a syslog or log shipper endpoint
+type UDPSink struct {
+	conn    net.Conn
+	dropped atomic.Uint64
+}
+
+// NewUDPSink returns a sink sending events to addr. It has to be closed once it isn't used anymore.
+func NewUDPSink(addr string) (*UDPSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPSink{conn: conn}, nil
+}
+
+// Emit sends an event
+func (s *UDPSink) Emit(event AuditEvent) {
+	b, err := json.Marshal(event)
+	if err == nil {
+		_, err = s.conn.Write(b)
+	}
+	if err != nil {
+		s.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of events which couldn't be sent
+func (s *UDPSink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close closes the connection, events emitted afterwards are dropped
+func (s *UDPSink) Close() error {
+	return s.conn.Close()
+}
+
+// HTTPSink posts audit events as JSON to url from a background goroutine, so slow receivers never delay requests.
+// Events are dropped if more than 1000 events are waiting.
+type HTTPSink struct {
+	URL string
+	//Client used for posting events, defaults to http.DefaultClient
+	Client *http.Client
+
+	start   sync.Once
+	events  chan AuditEvent
+	dropped atomic.Uint64
+}
+
+// Emit queues an event for delivery
+func (s *HTTPSink) Emit(event AuditEvent) {
+	s.start.Do(func() {
+		s.events = make(chan AuditEvent, 1000)
+		go s.deliver()
+	})
+	select {
+	case s.events <- event:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of events dropped because the queue was full or the receiver failed
+func (s *HTTPSink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *HTTPSink) deliver() {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	for event := range s.events {
+		b, err := json.Marshal(event)
+		if err != nil {
+			s.dropped.Add(1)
+			continue
+		}
+		resp, err := client.Post(s.URL, "application/json", bytes.NewReader(b))
+		if err != nil {
+			s.dropped.Add(1)
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			s.dropped.Add(1)
+		}
+	}
+}
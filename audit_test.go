@@ -0,0 +1,117 @@
+package keystone
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAudit(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z", "audit_ids": ["a-1"],
+		"user": {"id": "u-1", "name": "jane", "domain": {"id": "d-1"}}, "project": {"id": "p-1", "domain": {"id": "d-1"}}}}`)
+	defer idServer.Close()
+
+	events := make(chan AuditEvent, 10)
+	audit := &Audit{Sink: ChannelSink(events), Target: AuditResource{TypeURI: "service/compute", Name: "nova"}}
+	h := New(idServer.URL).Handler(audit.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	})))
+
+	req := httptest.NewRequest("GET", "/v2/servers", nil)
+	req.Header.Set("X-Auth-Token", "1234")
+	req.Header.Set("User-Agent", "test-agent")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	e := <-events
+	if e.TypeURI != cadfEventType || e.ID == "" || e.Action != "read" || e.Outcome != "success" || e.Reason.ReasonCode != "200" ||
+		e.Method != "GET" || e.RequestPath != "/v2/servers" || e.Target.Name != "nova" {
+		t.Errorf("Unexpected event %+v", e)
+	}
+	i := e.Initiator
+	if i.ID != "u-1" || i.Name != "jane" || i.ProjectID != "p-1" || i.IdentityStatus != "Confirmed" ||
+		len(i.AuditIDs) != 1 || i.AuditIDs[0] != "a-1" || i.Host.Agent != "test-agent" || i.Host.Address != "192.0.2.1" {
+		t.Errorf("Unexpected initiator %+v", i)
+	}
+
+	req = httptest.NewRequest("DELETE", "/v2/servers/1", nil)
+	req.Header.Set("X-Auth-Token", "1234")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if e := <-events; e.Action != "delete" || e.Outcome != "failure" || e.Reason.ReasonCode != "403" {
+		t.Errorf("Unexpected event for failed request %+v", e)
+	}
+
+	//unauthenticated requests are only audited if enabled
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	audit.Unauthenticated = true
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+	if e := <-events; e.Action != "create" || e.Initiator.ID != "" || e.Initiator.IdentityStatus != "Invalid" {
+		t.Errorf("Unexpected event for unauthenticated request %+v", e)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no further events, got %d", len(events))
+	}
+}
+
+func TestAuditSinks(t *testing.T) {
+	event := AuditEvent{ID: "e-1", Action: "read"}
+
+	var buf bytes.Buffer
+	WriterSink(&buf).Emit(event)
+	var decoded AuditEvent
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.ID != "e-1" {
+		t.Errorf("Expected JSON line, got %q (%v)", buf.String(), err)
+	}
+
+	received := make(chan AuditEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e AuditEvent
+		json.NewDecoder(r.Body).Decode(&e)
+		received <- e
+	}))
+	defer server.Close()
+	sink := &HTTPSink{URL: server.URL}
+	sink.Emit(event)
+	select {
+	case e := <-received:
+		if e.ID != "e-1" {
+			t.Errorf("Unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected event to be posted")
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	udp, err := NewUDPSink(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	udp.Emit(event)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 4096)
+	n, _, err := conn.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b[:n], &decoded); err != nil || decoded.Action != "read" {
+		t.Errorf("Unexpected datagram %q (%v)", b[:n], err)
+	}
+	if udp.Dropped() != 0 {
+		t.Errorf("Expected no dropped events, got %d", udp.Dropped())
+	}
+	if err := udp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	udp.Emit(event)
+	if udp.Dropped() != 1 {
+		t.Errorf("Expected event emitted after Close to be dropped, got %d", udp.Dropped())
+	}
+}
@@ -0,0 +1,67 @@
+package keystone
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// AuthRouter validates the tokens of requests against one of several Keystones, e.g. for a gateway
+// fronting multiple clouds or regions. Requests are mapped to an Auth by a key like the virtual host.
+// Every Auth has its own endpoint, credentials and cache; use NewNamespacedCache to share a cache backend.
+//
+//	router := &keystone.AuthRouter{Routes: map[string]*keystone.Auth{
+//		"api.region-one.example.com": regionOne,
+//		"api.region-two.example.com": regionTwo,
+//	}}
+//	http.ListenAndServe(":3000", router.Handler(myApp))
+type AuthRouter struct {
+	//Auth instances by route key. Keys are matched case insensitively and a trailing dot is ignored.
+	Routes map[string]*Auth
+	//Returns the route key of a request, defaults to HostKey
+	Key func(r *http.Request) string
+	//Handles requests whose key isn't in Routes. If nil they are rejected with 421 Misdirected Request.
+	Default *Auth
+}
+
+// HostKey returns the host of a request without port
+func HostKey(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host
+}
+
+// routeKey normalizes a route key, so fully qualified and differently cased host names match
+func routeKey(key string) string {
+	return strings.ToLower(strings.TrimSuffix(key, "."))
+}
+
+// Handler returns a http handler for use in a middleware chain.
+// Changes to Routes and Default after calling Handler have no effect.
+func (r *AuthRouter) Handler(h http.Handler) http.Handler {
+	handlers := make(map[string]http.Handler, len(r.Routes))
+	for key, a := range r.Routes {
+		handlers[routeKey(key)] = a.Handler(h)
+	}
+	var fallback http.Handler
+	if r.Default != nil {
+		fallback = r.Default.Handler(h)
+	}
+	key := r.Key
+	if key == nil {
+		key = HostKey
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if handler, ok := handlers[routeKey(key(req))]; ok {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		if fallback != nil {
+			fallback.ServeHTTP(w, req)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusMisdirectedRequest), http.StatusMisdirectedRequest)
+	})
+}
@@ -0,0 +1,62 @@
+package keystone
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthRouter(t *testing.T) {
+	regionOne := identityMock(200, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z", "user": {"id": "u-one"}}}`)
+	defer regionOne.Close()
+	regionTwo := identityMock(200, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z", "user": {"id": "u-two"}}}`)
+	defer regionTwo.Close()
+
+	shared := NewInMemoryCache(10)
+	one, two := New(regionOne.URL), New(regionTwo.URL)
+	one.TokenCache = NewNamespacedCache(shared, "one")
+	two.TokenCache = NewNamespacedCache(shared, "two")
+	router := &AuthRouter{Routes: map[string]*Auth{"one.example.com": one, "Two.Example.com": two}}
+
+	var user string
+	h := router.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user = r.Header.Get("X-User-Id")
+	}))
+	for _, tc := range []struct {
+		host, user string
+	}{
+		{"one.example.com", "u-one"},
+		{"TWO.example.com:8443", "u-two"},
+		{"two.example.com.", "u-two"},
+		//the same token is cached separately per route
+		{"one.example.com", "u-one"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = tc.host
+		req.Header.Set("X-Auth-Token", "1234")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if user != tc.user {
+			t.Errorf("Expected user %s for host %s, got %s", tc.user, tc.host, user)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "unknown.example.com"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMisdirectedRequest {
+		t.Errorf("Expected unknown host to be rejected, got %d", rec.Code)
+	}
+
+	router.Key = func(r *http.Request) string { return r.Header.Get("X-Cloud") }
+	router.Default = two
+	h = router.Handler(okHandler)
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Auth-Token", "1234")
+	req.Header.Set("X-Cloud", "other")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected default Auth to handle unknown keys, got %d", rec.Code)
+	}
+}
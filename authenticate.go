@@ -0,0 +1,218 @@
+package keystone
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// Credentials are used for obtaining a token from Keystone
+type Credentials interface {
+	//identity returns the identity section of the authentication request
+	identity() map[string]interface{}
+}
+
+// PasswordCredentials authenticate a user with its password.
+// The user is either given by UserID or by Username together with its domain.
+type PasswordCredentials struct {
+	UserID         string
+	Username       string
+	UserDomainID   string
+	UserDomainName string
+	Password       string
+}
+
+func (c PasswordCredentials) identity() map[string]interface{} {
+	user := map[string]interface{}{"password": c.Password}
+	if c.UserID != "" {
+		user["id"] = c.UserID
+	} else {
+		user["name"] = c.Username
+		user["domain"] = nameOrID(c.UserDomainID, c.UserDomainName)
+	}
+	return map[string]interface{}{
+		"methods":  []string{"password"},
+		"password": map[string]interface{}{"user": user},
+	}
+}
+
+// ApplicationCredentials authenticate with a Keystone application credential, the recommended method for
+// non-interactive service identities. The credential is either given by ID or by Name together with its user.
+// Tokens obtained with application credentials are always scoped to the credential's project,
+// so no Scope may be requested.
+type ApplicationCredentials struct {
+	ID             string
+	Name           string
+	UserID         string
+	Username       string
+	UserDomainID   string
+	UserDomainName string
+	Secret         string
+}
+
+func (c ApplicationCredentials) identity() map[string]interface{} {
+	credential := map[string]interface{}{"secret": c.Secret}
+	if c.ID != "" {
+		credential["id"] = c.ID
+	} else {
+		credential["name"] = c.Name
+		if c.UserID != "" {
+			credential["user"] = map[string]interface{}{"id": c.UserID}
+		} else {
+			credential["user"] = map[string]interface{}{
+				"name":   c.Username,
+				"domain": nameOrID(c.UserDomainID, c.UserDomainName),
+			}
+		}
+	}
+	return map[string]interface{}{
+		"methods":                []string{"application_credential"},
+		"application_credential": credential,
+	}
+}
+
+// Scope describes the requested authorization scope of a token.
+// Projects are either given by ProjectID or by ProjectName together with its domain.
+// An empty scope requests an unscoped token.
+type Scope struct {
+	ProjectID         string
+	ProjectName       string
+	ProjectDomainID   string
+	ProjectDomainName string
+	DomainID          string
+	DomainName        string
+}
+
+func (s *Scope) scope() map[string]interface{} {
+	switch {
+	case s == nil:
+		return nil
+	case s.ProjectID != "":
+		return map[string]interface{}{"project": map[string]string{"id": s.ProjectID}}
+	case s.ProjectName != "":
+		return map[string]interface{}{"project": map[string]interface{}{
+			"name":   s.ProjectName,
+			"domain": nameOrID(s.ProjectDomainID, s.ProjectDomainName),
+		}}
+	case s.DomainID != "" || s.DomainName != "":
+		return map[string]interface{}{"domain": nameOrID(s.DomainID, s.DomainName)}
+	}
+	return nil
+}
+
+func nameOrID(id, name string) map[string]string {
+	if id != "" {
+		return map[string]string{"id": id}
+	}
+	return map[string]string{"name": name}
+}
+
+// Authenticate obtains a new token from Keystone.
+// It returns the token to be used as X-Auth-Token for subsequent requests together with its token context.
+//
+// This is useful for background jobs which need to run with a well defined identity, e.g. the service user:
+//
+//	authToken, token, err := auth.Authenticate(keystone.PasswordCredentials{...}, &keystone.Scope{ProjectID: "..."})
+//	req, _ := http.NewRequest("POST", "/internal/job", nil)
+//	token.SetHeaders(req.Header)
+func (a *Auth) Authenticate(c Credentials, scope *Scope) (string, *Token, error) {
+	return a.authenticate(a.Endpoint, c, scope)
+}
+
+// authenticate is Authenticate against the given keystone endpoint
+func (a *Auth) authenticate(endpoint string, c Credentials, scope *Scope) (string, *Token, error) {
+	auth := map[string]interface{}{"identity": c.identity()}
+	if s := scope.scope(); s != nil {
+		auth["scope"] = s
+	}
+	body, err := json.Marshal(map[string]interface{}{"auth": auth})
+	if err != nil {
+		return "", nil, err
+	}
+
+	req, err := http.NewRequest("POST", endpoint+"/auth/tokens?nocatalog", bytes.NewReader(body))
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", a.UserAgent)
+
+	r, err := a.Client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer r.Body.Close()
+
+	token, err := decodeToken(r, http.StatusCreated, a.ClockSkew)
+	if err != nil {
+		return "", nil, err
+	}
+	authToken := r.Header.Get("X-Subject-Token")
+	if authToken == "" {
+		return "", nil, errors.New("Response didn't contain X-Subject-Token header")
+	}
+	return authToken, token, nil
+}
+
+// TOTPCredentials authenticate a user with a time based one time passcode.
+// The user is either given by UserID or by Username together with its domain.
+type TOTPCredentials struct {
+	UserID         string
+	Username       string
+	UserDomainID   string
+	UserDomainName string
+	Passcode       string
+}
+
+func (c TOTPCredentials) identity() map[string]interface{} {
+	user := map[string]interface{}{"passcode": c.Passcode}
+	if c.UserID != "" {
+		user["id"] = c.UserID
+	} else {
+		user["name"] = c.Username
+		user["domain"] = nameOrID(c.UserDomainID, c.UserDomainName)
+	}
+	return map[string]interface{}{
+		"methods": []string{"totp"},
+		"totp":    map[string]interface{}{"user": user},
+	}
+}
+
+// VerifyCredentials checks credentials (e.g. a password or TOTP passcode) against Keystone
+// and returns the token context of the authenticated user.
+// The unscoped token issued by Keystone in the process is revoked right away.
+// This is useful for step-up confirmation of sensitive actions.
+func (a *Auth) VerifyCredentials(c Credentials) (*Token, error) {
+	authToken, token, err := a.Authenticate(c, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.revoke(authToken); err != nil {
+		a.log(context.Background(), slog.LevelWarn, "Failed to revoke token issued for credential verification", "error", err)
+	}
+	return token, nil
+}
+
+// revoke invalidates a token in keystone
+func (a *Auth) revoke(authToken string) error {
+	req, err := http.NewRequest("DELETE", a.Endpoint+"/auth/tokens", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", authToken)
+	req.Header.Set("X-Subject-Token", authToken)
+	req.Header.Set("User-Agent", a.UserAgent)
+
+	r, err := a.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusNoContent {
+		return &Error{StatusCode: r.StatusCode, Status: r.Status}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package keystone
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	var authRequest map[string]interface{}
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Expected POST request, got %s", r.Method)
+		}
+		json.NewDecoder(r.Body).Decode(&authRequest)
+		w.Header().Set("X-Subject-Token", "service-token")
+		w.WriteHeader(201)
+		io.WriteString(w, `
+{
+  "token": {
+    "expires_at": "2120-10-09T15:09:12.355Z",
+    "issued_at": "2015-10-08T15:09:12.355Z",
+    "user": {
+      "id": "u-service",
+      "name": "service",
+      "domain": {
+        "id": "o-default",
+        "name": "Default"
+      }
+    },
+    "project": {
+      "id": "p-service",
+      "name": "service",
+      "domain": {
+        "id": "o-default",
+        "name": "Default"
+      }
+    },
+    "roles": [
+      {
+        "id": "r-service",
+        "name": "service"
+      }
+    ]
+  }
+}`)
+	}))
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	authToken, token, err := a.Authenticate(
+		PasswordCredentials{Username: "service", UserDomainName: "Default", Password: "secret"},
+		&Scope{ProjectName: "service", ProjectDomainName: "Default"},
+	)
+	if err != nil {
+		t.Fatal("Authentication failed: ", err)
+	}
+	if authToken != "service-token" {
+		t.Errorf("Expected token %q, got %q", "service-token", authToken)
+	}
+
+	expected := `{"auth":{"identity":{"methods":["password"],"password":{"user":{"domain":{"name":"Default"},"name":"service","password":"secret"}}},"scope":{"project":{"domain":{"name":"Default"},"name":"service"}}}}`
+	if b, _ := json.Marshal(authRequest); string(b) != expected {
+		t.Errorf("Unexpected auth request. expected\n%s\ngot\n%s", expected, b)
+	}
+
+	header := http.Header{}
+	token.SetHeaders(header)
+	for k, v := range map[string]string{"X-Identity-Status": "Confirmed", "X-User-Id": "u-service", "X-Project-Id": "p-service", "X-Roles": "service"} {
+		if header.Get(k) != v {
+			t.Errorf("Expected header %s to be %q, got %q", k, v, header.Get(k))
+		}
+	}
+}
+
+func TestApplicationCredentials(t *testing.T) {
+	for _, tc := range []struct {
+		credentials ApplicationCredentials
+		expected    string
+	}{
+		{
+			ApplicationCredentials{ID: "ac-1", Secret: "secret"},
+			`{"application_credential":{"id":"ac-1","secret":"secret"},"methods":["application_credential"]}`,
+		},
+		{
+			ApplicationCredentials{Name: "monitoring", UserID: "u-1", Secret: "secret"},
+			`{"application_credential":{"name":"monitoring","secret":"secret","user":{"id":"u-1"}},"methods":["application_credential"]}`,
+		},
+		{
+			ApplicationCredentials{Name: "monitoring", Username: "service", UserDomainName: "Default", Secret: "secret"},
+			`{"application_credential":{"name":"monitoring","secret":"secret","user":{"domain":{"name":"Default"},"name":"service"}},"methods":["application_credential"]}`,
+		},
+	} {
+		if b, _ := json.Marshal(tc.credentials.identity()); string(b) != tc.expected {
+			t.Errorf("Unexpected identity. expected\n%s\ngot\n%s", tc.expected, b)
+		}
+	}
+}
+
+func TestVerifyCredentials(t *testing.T) {
+	var revoked string
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			var req struct {
+				Auth struct {
+					Identity struct {
+						Methods []string
+						TOTP    struct {
+							User struct {
+								ID       string
+								Passcode string
+							}
+						}
+					}
+				}
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Auth.Identity.TOTP.User.Passcode != "123456" {
+				w.WriteHeader(401)
+				io.WriteString(w, `{"error": {"code": 401, "message": "The request you have made requires authentication.", "title": "Unauthorized"}}`)
+				return
+			}
+			w.Header().Set("X-Subject-Token", "short-lived")
+			w.WriteHeader(201)
+			io.WriteString(w, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z", "issued_at": "2015-10-08T15:09:12.355Z", "user": {"id": "u-1"}}}`)
+		case "DELETE":
+			revoked = r.Header.Get("X-Subject-Token")
+			w.WriteHeader(204)
+		}
+	}))
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	token, err := a.VerifyCredentials(TOTPCredentials{UserID: "u-1", Passcode: "123456"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.User.ID != "u-1" {
+		t.Errorf("Expected user u-1, got %s", token.User.ID)
+	}
+	if revoked != "short-lived" {
+		t.Errorf("Expected issued token to be revoked, got %q", revoked)
+	}
+
+	if _, err := a.VerifyCredentials(TOTPCredentials{UserID: "u-1", Passcode: "000000"}); err == nil {
+		t.Error("Expected verification with wrong passcode to fail")
+	}
+}
@@ -0,0 +1,72 @@
+package keystone
+
+import "context"
+
+// Authority combines the identities of a request as in keystonemiddleware's composite authority model:
+// the token of the end user and the optional token of the service calling on behalf of the user (X-Service-Token).
+// The role sets of both identities are kept separate.
+type Authority struct {
+	//Token context of the X-Auth-Token, nil if not authenticated
+	User *Token
+	//Token context of the X-Service-Token, nil if not present or invalid
+	Service *Token
+}
+
+// AuthorityFromContext returns the identities of a request authenticated by Auth.Handler
+func AuthorityFromContext(ctx context.Context) Authority {
+	user, _ := TokenFromContext(ctx)
+	return Authority{
+		User:    user,
+		Service: serviceTokenFromContext(ctx),
+	}
+}
+
+func withServiceToken(ctx context.Context, t *Token) context.Context {
+	return context.WithValue(ctx, serviceTokenKey, t)
+}
+
+func serviceTokenFromContext(ctx context.Context) *Token {
+	t, _ := ctx.Value(serviceTokenKey).(*Token)
+	return t
+}
+
+// UserRoles returns the role names of the user token
+func (a Authority) UserRoles() []string {
+	if a.User == nil {
+		return nil
+	}
+	return a.User.RoleNames()
+}
+
+// ServiceRoles returns the role names of the service token
+func (a Authority) ServiceRoles() []string {
+	if a.Service == nil {
+		return nil
+	}
+	return a.Service.RoleNames()
+}
+
+// HasUserRole reports whether the user token has any of the given roles
+func (a Authority) HasUserRole(roles ...string) bool {
+	return a.User != nil && HasRole(roles...)(a.User)
+}
+
+// HasServiceRole reports whether the service token has any of the given roles
+func (a Authority) HasServiceRole(roles ...string) bool {
+	return a.Service != nil && HasRole(roles...)(a.Service)
+}
+
+// HasRole reports whether the service token or the user token has any of the given roles.
+// This implements the "service role OR user role" rules used by OpenStack service to service APIs.
+func (a Authority) HasRole(roles ...string) bool {
+	return a.HasServiceRole(roles...) || a.HasUserRole(roles...)
+}
+
+// RoleNames returns the names of the token's roles
+func (t Token) RoleNames() []string {
+	names := make([]string, 0, len(t.Roles))
+	for _, role := range t.Roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
@@ -0,0 +1,38 @@
+package keystone
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func tokenWithRoles(roles ...string) *Token {
+	t := &Token{}
+	for _, r := range roles {
+		t.Roles = append(t.Roles, struct {
+			ID   string
+			Name string
+		}{ID: "r-" + r, Name: r})
+	}
+	return t
+}
+
+func TestAuthority(t *testing.T) {
+	ctx := withToken(context.Background(), tokenWithRoles("member"))
+	ctx = withServiceToken(ctx, tokenWithRoles("service"))
+	a := AuthorityFromContext(ctx)
+
+	if !reflect.DeepEqual(a.UserRoles(), []string{"member"}) || !reflect.DeepEqual(a.ServiceRoles(), []string{"service"}) {
+		t.Errorf("Unexpected role sets: user %v, service %v", a.UserRoles(), a.ServiceRoles())
+	}
+	if !a.HasRole("service") || !a.HasRole("member") || a.HasRole("admin") {
+		t.Error("HasRole should match roles of either token")
+	}
+	if a.HasUserRole("service") || a.HasServiceRole("member") {
+		t.Error("Role sets should be kept separate")
+	}
+
+	if a := AuthorityFromContext(context.Background()); a.HasRole("member") || a.UserRoles() != nil {
+		t.Error("Unauthenticated authority should not have roles")
+	}
+}
@@ -0,0 +1,59 @@
+package keystone
+
+import "net/http"
+
+// RequireUnscoped returns a handler only accepting requests authenticated with an unscoped token.
+// This is useful for endpoints like project listings used for choosing a scope.
+// Requests without a valid token are rejected with 401, scoped tokens with 403.
+// It must be placed behind the handler returned by Auth.Handler.
+func RequireUnscoped(h http.Handler) http.Handler {
+	return requireToken(h, func(t *Token) bool { return t.Project == nil && t.Domain == nil && t.System == nil })
+}
+
+// RequireRoles returns a handler only accepting requests authenticated with a token having any of the given roles.
+// Requests without a valid token are rejected with 401, tokens lacking the roles with 403.
+// It must be placed behind the handler returned by Auth.Handler.
+//
+//	mux.Handle("/admin/", keystone.RequireRoles(adminHandler, "admin"))
+//	http.ListenAndServe(":3000", auth.Handler(mux))
+func RequireRoles(h http.Handler, roles ...string) http.Handler {
+	return requireToken(h, HasRole(roles...))
+}
+
+// RequireProjectScope returns a handler only accepting requests authenticated with a project scoped token.
+// Requests without a valid token are rejected with 401, other tokens with 403.
+// It must be placed behind the handler returned by Auth.Handler.
+func RequireProjectScope(h http.Handler) http.Handler {
+	return requireToken(h, func(t *Token) bool { return ProjectScope.check(t) == nil })
+}
+
+// RequireDomainScope returns a handler only accepting requests authenticated with a domain scoped token.
+// Requests without a valid token are rejected with 401, other tokens with 403.
+// It must be placed behind the handler returned by Auth.Handler.
+func RequireDomainScope(h http.Handler) http.Handler {
+	return requireToken(h, func(t *Token) bool { return DomainScope.check(t) == nil })
+}
+
+// RequireSystemScope returns a handler only accepting requests authenticated with a system scoped token,
+// e.g. for deployment wide administrative APIs.
+// Requests without a valid token are rejected with 401, other tokens with 403.
+// It must be placed behind the handler returned by Auth.Handler.
+func RequireSystemScope(h http.Handler) http.Handler {
+	return requireToken(h, func(t *Token) bool { return SystemScope.check(t) == nil })
+}
+
+// requireToken rejects requests without a validated token or with a token not satisfying rule
+func requireToken(h http.Handler, rule Rule) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := TokenFromContext(r.Context())
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if !rule(token) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,89 @@
+package keystone
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireUnscoped(t *testing.T) {
+	h := RequireUnscoped(okHandler)
+	cases := []struct {
+		name  string
+		token *Token
+		code  int
+	}{
+		{"no token", nil, 401},
+		{"unscoped", &Token{}, 200},
+		{"project scoped", &Token{Project: &Project{ID: "p-1"}}, 403},
+		{"domain scoped", &Token{Domain: &Domain{ID: "d-1"}}, 403},
+		{"system scoped", &Token{System: &System{All: true}}, 403},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		req := newRequest("GET", "/projects")
+		//identity headers are ignored
+		req.Header.Set("X-Identity-Status", "Confirmed")
+		if c.token != nil {
+			req = req.WithContext(withToken(req.Context(), c.token))
+		}
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.code {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.code, rec.Code)
+		}
+	}
+}
+
+func TestRequireUnscopedMappedHeaders(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z", "user": {"id": "u-1"}, "project": {"id": "p-1"}}}`)
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.HeaderMapper = func(token *Token, header http.Header) { header.Del("X-Project-Id") }
+	rec := httptest.NewRecorder()
+	req := newRequest("GET", "/projects")
+	req.Header.Set("X-Auth-Token", "1234")
+	a.Handler(RequireUnscoped(okHandler)).ServeHTTP(rec, req)
+	if rec.Code != 403 {
+		t.Errorf("Expected project scoped token to be rejected without X-Project-Id header, got %d", rec.Code)
+	}
+}
+
+func TestRequireTokenWrappers(t *testing.T) {
+	project := &Token{Project: &Project{ID: "p-1"}, Roles: []struct {
+		ID   string
+		Name string
+	}{{"r-1", "member"}}}
+	domain := &Token{Domain: &Domain{ID: "d-1"}}
+	system := &Token{System: &System{All: true}}
+
+	cases := []struct {
+		name    string
+		handler http.Handler
+		token   *Token
+		code    int
+	}{
+		{"roles without token", RequireRoles(okHandler, "admin", "member"), nil, 401},
+		{"roles with member", RequireRoles(okHandler, "admin", "member"), project, 200},
+		{"roles without role", RequireRoles(okHandler, "admin"), project, 403},
+		{"project scope", RequireProjectScope(okHandler), project, 200},
+		{"project scope with domain token", RequireProjectScope(okHandler), domain, 403},
+		{"domain scope", RequireDomainScope(okHandler), domain, 200},
+		{"domain scope with project token", RequireDomainScope(okHandler), project, 403},
+		{"domain scope without token", RequireDomainScope(okHandler), nil, 401},
+		{"system scope", RequireSystemScope(okHandler), system, 200},
+		{"system scope with domain token", RequireSystemScope(okHandler), domain, 403},
+		{"project scope with system token", RequireProjectScope(okHandler), system, 403},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		req := newRequest("GET", "/")
+		if c.token != nil {
+			req = req.WithContext(withToken(req.Context(), c.token))
+		}
+		c.handler.ServeHTTP(rec, req)
+		if rec.Code != c.code {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.code, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package keystone
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+// ErrProjectNotAllowed is returned for tokens rejected by Auth.AllowedProjects
+var ErrProjectNotAllowed = errors.New("Project not allowed")
+
+// checkBinding returns an error if the token isn't scoped to one of Auth.AllowedProjects
+// or lacks all of Auth.AcceptRoles
+func (a *Auth) checkBinding(t *Token) error {
+	if len(a.AllowedProjects) > 0 {
+		if t.Project == nil {
+			return fmt.Errorf("%w: token isn't project scoped", ErrProjectNotAllowed)
+		}
+		if !slices.Contains(a.AllowedProjects, t.Project.ID) {
+			return fmt.Errorf("%w: %s", ErrProjectNotAllowed, t.Project.ID)
+		}
+	}
+	if len(a.AcceptRoles) > 0 && !HasRole(a.AcceptRoles...)(t) {
+		return ErrMissingRole
+	}
+	return nil
+}
+
+// checkServiceRoles returns if a service token has any of Auth.RequiredServiceRoles
+func (h *handler) checkServiceRoles(req *http.Request, token *Token) bool {
+	if len(h.RequiredServiceRoles) == 0 || HasRole(h.RequiredServiceRoles...)(token) {
+		return true
+	}
+	h.log(req.Context(), slog.LevelInfo, "Service token lacks the required service roles", "user_id", token.User.ID, "roles", token.RoleNames())
+	return false
+}
@@ -0,0 +1,64 @@
+package keystone
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBinding(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z", "user": {"id": "u-1"},
+		"project": {"id": "p-1", "domain": {"id": "d-1"}}, "roles": [{"id": "r-1", "name": "member"}]}}`)
+	defer idServer.Close()
+
+	cases := []struct {
+		projects, roles []string
+		err             error
+	}{
+		{nil, nil, nil},
+		{[]string{"p-2", "p-1"}, nil, nil},
+		{[]string{"p-2"}, nil, ErrProjectNotAllowed},
+		{nil, []string{"reader", "member"}, nil},
+		{nil, []string{"admin"}, ErrMissingRole},
+		{[]string{"p-1"}, []string{"admin"}, ErrMissingRole},
+	}
+	for _, c := range cases {
+		a := Auth{Endpoint: idServer.URL, AllowedProjects: c.projects, AcceptRoles: c.roles}
+		a.Handler(okHandler)
+		if _, err := a.Validate("1234"); !errors.Is(err, c.err) {
+			t.Errorf("%+v: expected %v, got %v", c, c.err, err)
+		}
+	}
+}
+
+func TestRequiredServiceRoles(t *testing.T) {
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Subject-Token") {
+		case "user":
+			io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u-1"}, "roles": [{"id": "1", "name": "member"}]}}`)
+		case "service":
+			io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "nova"}, "roles": [{"id": "2", "name": "service"}]}}`)
+		}
+	}))
+	defer idServer.Close()
+
+	var header http.Header
+	a := &Auth{Endpoint: idServer.URL, RequiredServiceRoles: []string{"service"}}
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	for serviceToken, status := range map[string]string{"service": "Confirmed", "user": "Invalid"} {
+		req := newRequest("GET", "/")
+		req.Header.Set("X-Auth-Token", "user")
+		req.Header.Set("X-Service-Token", serviceToken)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if got := header.Get("X-Service-Identity-Status"); got != status {
+			t.Errorf("Expected X-Service-Identity-Status %s for service token %s, got %s", status, serviceToken, got)
+		}
+		if header.Get("X-Identity-Status") != "Confirmed" {
+			t.Errorf("Expected user token to be confirmed, got %s", header.Get("X-Identity-Status"))
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package keystone
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting Keystone while the circuit breaker is open, see Auth.BreakerThreshold
+var ErrCircuitOpen = errors.New("Keystone circuit breaker is open")
+
+const (
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultBreakerCooldown = 30 * time.Second
+)
+
+// circuitBreaker stops requests to Keystone after a number of consecutive failures
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow reports if a request may be sent. Once the cooldown has passed a single request is let through
+// to probe if Keystone recovered.
+func (b *circuitBreaker) allow(threshold int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record tracks the outcome of a request and reports if the breaker changed its state
+func (b *circuitBreaker) record(failed bool, threshold int, cooldown time.Duration) (opened, closed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if !failed {
+		closed = b.failures >= threshold
+		b.failures = 0
+		return false, closed
+	}
+	b.failures++
+	if b.failures >= threshold {
+		b.openUntil = time.Now().Add(cooldown)
+		opened = b.failures == threshold
+	}
+	return opened, false
+}
+
+// skip ends a request which says nothing about Keystone's health without counting it
+func (b *circuitBreaker) skip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// fetchWithRetries validates a token against Keystone, retrying requests failing because Keystone is
+// unavailable (see Auth.Retries) and short circuiting while Keystone is down (see Auth.BreakerThreshold)
+func (a *Auth) fetchWithRetries(ctx context.Context, endpoint, authToken string) (*Token, error) {
+	if a.BreakerThreshold > 0 && !a.breaker.allow(a.BreakerThreshold) {
+		return nil, ErrCircuitOpen
+	}
+	backoff := a.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	token, err := a.fetchFromIssuers(ctx, endpoint, authToken)
+	for attempt := 0; attempt < a.Retries && retryable(err); attempt++ {
+		//jitter spreads the retries of concurrent validations
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		a.log(ctx, slog.LevelDebug, "Retrying Keystone request", "endpoint", endpoint, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+		backoff *= 2
+		token, err = a.fetchFromIssuers(ctx, endpoint, authToken)
+	}
+	if a.BreakerThreshold > 0 {
+		cooldown := a.BreakerCooldown
+		if cooldown <= 0 {
+			cooldown = defaultBreakerCooldown
+		}
+		//requests cancelled because every caller gave up (e.g. clients disconnecting) and throttled requests
+		//don't indicate Keystone being down
+		if errors.Is(err, context.Canceled) || errors.Is(err, ErrThrottled) {
+			a.breaker.skip()
+			return token, err
+		}
+		opened, closed := a.breaker.record(IsUnavailable(err), a.BreakerThreshold, cooldown)
+		if opened {
+			a.log(ctx, slog.LevelWarn, "Keystone unavailable, opening circuit breaker", "cooldown", cooldown, "error", err)
+		} else if closed {
+			a.log(ctx, slog.LevelInfo, "Keystone recovered, closing circuit breaker")
+		}
+	}
+	return token, err
+}
+
+// retryable reports if a failed request should be retried. Throttled requests aren't retried
+// as Keystone told us when to come back.
+func retryable(err error) bool {
+	return IsUnavailable(err) && !errors.Is(err, ErrThrottled)
+}
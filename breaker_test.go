@@ -0,0 +1,152 @@
+package keystone
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const validTokenBody = `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u"}}}`
+
+// flakyMock answers with the given status codes in turn, the last one is repeated
+func flakyMock(requests *atomic.Int32, statuses ...int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		if n > len(statuses) {
+			n = len(statuses)
+		}
+		w.WriteHeader(statuses[n-1])
+		if statuses[n-1] == 200 {
+			io.WriteString(w, validTokenBody)
+		}
+	}))
+}
+
+func TestRetries(t *testing.T) {
+	var requests atomic.Int32
+	idServer := flakyMock(&requests, 500, 502, 200)
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.Retries = 2
+	a.RetryBackoff = time.Millisecond
+	if _, err := a.Validate("1234"); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("Expected 3 requests, got %d", n)
+	}
+
+	//rejected tokens aren't retried
+	var rejected atomic.Int32
+	idServer = flakyMock(&rejected, 404)
+	defer idServer.Close()
+	a.Endpoint = idServer.URL
+	if _, err := a.Validate("1234"); err == nil {
+		t.Error("Expected validation to fail")
+	}
+	if n := rejected.Load(); n != 1 {
+		t.Errorf("Expected 1 request, got %d", n)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	idServer := flakyMock(&requests, 500, 500, 200)
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.BreakerThreshold = 2
+	a.BreakerCooldown = 20 * time.Millisecond
+	for i := 0; i < 2; i++ {
+		if _, err := a.Validate("1234"); !IsUnavailable(err) {
+			t.Fatalf("Expected Keystone to be unavailable, got %v", err)
+		}
+	}
+	if _, err := a.Validate("1234"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected no request while the circuit is open, got %d requests", n)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := a.Validate("1234"); err != nil {
+		t.Fatal(err)
+	}
+	if !a.breaker.allow(a.BreakerThreshold) {
+		t.Error("Expected circuit breaker to be closed after a successful probe")
+	}
+}
+
+func TestCircuitBreakerIgnoresCancellation(t *testing.T) {
+	var requests atomic.Int32
+	cancelled := make(chan struct{})
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			<-r.Context().Done()
+			close(cancelled)
+			return
+		}
+		io.WriteString(w, validTokenBody)
+	}))
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.BreakerThreshold = 1
+	a.BreakerCooldown = time.Minute
+	//the client disconnects while its token is being validated
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := a.ValidateContext(ctx, "1234"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected validation to be cancelled, got %v", err)
+	}
+	//let the abandoned validation finish
+	<-cancelled
+	time.Sleep(20 * time.Millisecond)
+	if _, err := a.Validate("1234"); err != nil {
+		t.Errorf("Expected cancelled validation not to open the circuit breaker, got %v", err)
+	}
+}
+
+func TestStaleCache(t *testing.T) {
+	var requests atomic.Int32
+	idServer := flakyMock(&requests, 200, 503, 404)
+	defer idServer.Close()
+
+	cache := cacheMock{}
+	a := New(idServer.URL)
+	a.TokenCache = &cache
+	a.StaleCacheTime = time.Hour
+	if _, err := a.Validate("1234"); err != nil {
+		t.Fatal(err)
+	}
+
+	//let the cached token become stale
+	var entry cachedToken
+	cache.Get(hashToken("1234"), &entry)
+	if entry.StaleAt.IsZero() {
+		t.Fatal("Expected cache entry to carry StaleAt")
+	}
+	entry.StaleAt = time.Now().Add(-time.Second)
+	cache.Set(hashToken("1234"), entry, 0)
+
+	token, cached, err := a.validateToken(context.Background(), "1234")
+	if err != nil || !cached || token.User.ID != "u" {
+		t.Errorf("Expected stale token to be served while Keystone is unavailable, got %v, %v, %v", token, cached, err)
+	}
+	if n := a.Stats().ServedStale; n != 1 {
+		t.Errorf("Expected one stale token to be served, got %d", n)
+	}
+	if _, err := a.Validate("1234"); err == nil {
+		t.Error("Expected stale token rejected by Keystone to be invalid")
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("Expected stale token to be revalidated, got %d requests", n)
+	}
+}
@@ -0,0 +1,165 @@
+package keystone
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// cacheKey returns the key under which the token context of authToken is cached
+func (a *Auth) cacheKey(authToken string) string {
+	if a.RawCacheKeys {
+		return authToken
+	}
+	if a.CacheKeySecret == "" {
+		return hashToken(authToken)
+	}
+	h := hmac.New(sha256.New, []byte(a.CacheKeySecret))
+	h.Write([]byte(authToken))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CacheCtx is implemented by caches honoring deadlines and cancellation of the request being authenticated.
+// This keeps slow networked cache backends from blocking the request path.
+// If a cache implements CacheCtx, its GetCtx and SetCtx methods are used instead of Get and Set.
+type CacheCtx interface {
+	Cache
+	//SetCtx stores a value with the given ttl
+	SetCtx(ctx context.Context, key string, value interface{}, ttl time.Duration)
+	//GetCtx retrieves a value previously stored in the cache, see Cache.Get
+	GetCtx(ctx context.Context, key string, value interface{}) bool
+}
+
+func cacheGet(ctx context.Context, c Cache, key string, value interface{}) bool {
+	if cc, ok := c.(CacheCtx); ok {
+		return cc.GetCtx(ctx, key, value)
+	}
+	return c.Get(key, value)
+}
+
+func cacheSet(ctx context.Context, c Cache, key string, value interface{}, ttl time.Duration) {
+	if cc, ok := c.(CacheCtx); ok {
+		cc.SetCtx(ctx, key, value, ttl)
+		return
+	}
+	c.Set(key, value, ttl)
+}
+
+// EvictReason describes why an entry was removed from a cache
+type EvictReason int
+
+const (
+	//EvictExpired is used for entries removed because their ttl passed
+	EvictExpired EvictReason = iota
+	//EvictCapacity is used for entries removed to make room for new ones
+	EvictCapacity
+	//EvictInvalidated is used for entries removed explicitly
+	EvictInvalidated
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictExpired:
+		return "expired"
+	case EvictCapacity:
+		return "capacity"
+	case EvictInvalidated:
+		return "invalidated"
+	}
+	return "unknown"
+}
+
+// EvictionNotifier is implemented by caches which can report evicted entries.
+// This allows applications to keep derived state (e.g. per user session registries) consistent with the token cache.
+type EvictionNotifier interface {
+	//OnEvict registers a function called whenever an entry is removed from the cache.
+	//It replaces a previously registered function.
+	OnEvict(func(key string, reason EvictReason))
+}
+
+// Invalidate evicts the cached token context of a token, forcing it to be validated against Keystone again.
+// This requires the TokenCache (or the LoadingCache) to implement Deleter.
+func (a *Auth) Invalidate(authToken string) error {
+	d, _ := a.cache().(Deleter)
+	if d == nil {
+		return errors.New("Token cache doesn't support deleting entries")
+	}
+	d.Delete(a.cacheKey(authToken))
+	return nil
+}
+
+// FlushCache evicts all cached token contexts, e.g. after a revocation event affecting many tokens.
+// This requires the TokenCache (or the LoadingCache) to implement Flusher.
+func (a *Auth) FlushCache() error {
+	f, _ := a.cache().(Flusher)
+	if f == nil {
+		return errors.New("Token cache doesn't support flushing")
+	}
+	f.Flush()
+	return nil
+}
+
+// cache returns the configured token cache, nil if there is none
+func (a *Auth) cache() interface{} {
+	if a.LoadingCache != nil {
+		return a.LoadingCache
+	}
+	if a.TokenCache != nil {
+		return a.TokenCache
+	}
+	return nil
+}
+
+// Deleter is implemented by caches supporting explicit removal of entries
+type Deleter interface {
+	//Delete removes the entry for key from the cache
+	Delete(key string)
+}
+
+// Flusher is implemented by caches supporting removal of all entries
+type Flusher interface {
+	//Flush removes all entries from the cache
+	Flush()
+}
+
+// CacheStats describes the use of the token cache
+type CacheStats struct {
+	//Number of token lookups answered from the cache
+	Hits uint64
+	//Number of token lookups which had to be validated against Keystone
+	Misses uint64
+	//Number of entries in the cache, -1 if the cache doesn't report its size
+	Entries int
+}
+
+// CacheStats returns the hit and miss counts of the token cache.
+// Entries is reported by caches implementing Len() int, like InMemoryCache.
+func (a *Auth) CacheStats() CacheStats {
+	s := CacheStats{
+		Hits:    a.stats.cacheHits.Load(),
+		Misses:  a.stats.cacheMisses.Load(),
+		Entries: -1,
+	}
+	c := a.cache()
+	if l, ok := c.(*loadingCache); ok {
+		c = l.cache
+	}
+	if l, ok := c.(interface{ Len() int }); ok {
+		s.Entries = l.Len()
+	}
+	return s
+}
+
+func (a *Auth) cacheLookup(hit bool) {
+	if hit {
+		a.stats.cacheHits.Add(1)
+	} else {
+		a.stats.cacheMisses.Add(1)
+	}
+	if a.Metrics != nil {
+		a.Metrics.CacheLookup(hit)
+	}
+}
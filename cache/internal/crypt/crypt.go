@@ -0,0 +1,81 @@
+// Package crypt protects entries of the cache implementations storing tokens in shared backends.
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// ErrInvalidPayload is returned for cache entries failing authentication or decryption
+var ErrInvalidPayload = errors.New("Invalid cache payload")
+
+// MAC returns the HMAC-SHA256 of data
+func MAC(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// Derive returns a 32 byte key for the given purpose from the secret
+func Derive(secret, purpose string) []byte {
+	return MAC([]byte(secret), []byte(purpose))
+}
+
+// Key hashes a cache key, so tokens are never stored verbatim. The hash is keyed with keyKey if it is set.
+func Key(prefix string, keyKey []byte, key string) string {
+	if keyKey == nil {
+		sum := sha256.Sum256([]byte(key))
+		return prefix + hex.EncodeToString(sum[:])
+	}
+	return prefix + hex.EncodeToString(MAC(keyKey, []byte(key)))
+}
+
+// NewAEAD returns AES-256-GCM with a key derived from the secret
+func NewAEAD(secret string) cipher.AEAD {
+	block, err := aes.NewCipher(Derive(secret, "encryption"))
+	if err != nil {
+		panic(err)
+	}
+	aead, _ := cipher.NewGCM(block)
+	return aead
+}
+
+// Seal encrypts and authenticates a payload, prefixing it with a random nonce
+func Seal(aead cipher.AEAD, b []byte) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	return aead.Seal(nonce, nonce, b, nil)
+}
+
+// Open verifies and decrypts a payload protected by Seal
+func Open(aead cipher.AEAD, b []byte) ([]byte, error) {
+	n := aead.NonceSize()
+	if len(b) < n {
+		return nil, ErrInvalidPayload
+	}
+	plain, err := aead.Open(nil, b[:n], b[n:], nil)
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+	return plain, nil
+}
+
+// SealMAC authenticates a payload by prefixing it with its MAC
+func SealMAC(key, b []byte) []byte {
+	return append(MAC(key, b), b...)
+}
+
+// OpenMAC verifies a payload protected by SealMAC
+func OpenMAC(key, b []byte) ([]byte, error) {
+	if len(b) < sha256.Size || !hmac.Equal(b[:sha256.Size], MAC(key, b[sha256.Size:])) {
+		return nil, ErrInvalidPayload
+	}
+	return b[sha256.Size:], nil
+}
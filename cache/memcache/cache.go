@@ -0,0 +1,131 @@
+// Package memcache provides a memcached backed cache implementation for https://github.com/databus23/keystone
+//
+// It allows reusing the memcached servers of deployments of the python keystonemiddleware (see FromConfig).
+// Entries are stored in a format of their own though, the cache can't be shared with the python middleware.
+package memcache
+
+import (
+	"context"
+	"crypto/cipher"
+	"encoding/json"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/databus23/keystone"
+	"github.com/databus23/keystone/cache/internal/crypt"
+)
+
+// SecurityStrategy protects cached payloads like the memcache_security_strategy option of keystonemiddleware
+type SecurityStrategy int
+
+const (
+	//None stores payloads as plain JSON
+	None SecurityStrategy = iota
+	//MAC authenticates payloads with HMAC-SHA256, so tampered entries are ignored
+	MAC
+	//Encrypt encrypts and authenticates payloads with AES-256-GCM
+	Encrypt
+)
+
+// ErrInvalidPayload is returned for cache entries failing authentication or decryption
+var ErrInvalidPayload = crypt.ErrInvalidPayload
+
+const keyPrefix = "keystone/"
+
+type memcacheCache struct {
+	client   *memcache.Client
+	strategy SecurityStrategy
+	keyKey   []byte
+	macKey   []byte
+	aead     cipher.AEAD
+}
+
+// New creates a new cache storing entries using client.
+//
+// Cache keys are hashed, so tokens are never stored verbatim in memcached. If strategy isn't None
+// the hashes are keyed with secretKey and payloads are protected with keys derived from it.
+// New panics if a strategy other than None is given without a secret key.
+func New(client *memcache.Client, strategy SecurityStrategy, secretKey string) keystone.Cache {
+	c := &memcacheCache{client: client, strategy: strategy}
+	if strategy == None {
+		return c
+	}
+	if secretKey == "" {
+		panic("memcache: security strategy requires a secret key")
+	}
+	c.keyKey = crypt.Derive(secretKey, "key")
+	c.macKey = crypt.Derive(secretKey, "mac")
+	if strategy == Encrypt {
+		c.aead = crypt.NewAEAD(secretKey)
+	}
+	return c
+}
+
+func (c *memcacheCache) Set(key string, x interface{}, ttl time.Duration) {
+	b, err := json.Marshal(x)
+	if err != nil {
+		return
+	}
+	//memcached has a resolution of seconds, round up so short lived entries aren't stored forever (0)
+	expiration := int32((ttl + time.Second - 1) / time.Second)
+	if expiration <= 0 {
+		return
+	}
+	if err := c.client.Set(&memcache.Item{Key: c.key(key), Value: c.seal(b), Expiration: expiration}); err != nil {
+		keystone.Log("Failed to store token in memcached: %v", err)
+	}
+}
+
+func (c *memcacheCache) Get(key string, x interface{}) bool {
+	item, err := c.client.Get(c.key(key))
+	if err != nil {
+		if err != memcache.ErrCacheMiss {
+			keystone.Log("Failed to get token from memcached: %v", err)
+		}
+		return false
+	}
+	b, err := c.open(item.Value)
+	if err != nil {
+		keystone.Log("Ignoring cached token: %v", err)
+		return false
+	}
+	return json.Unmarshal(b, x) == nil
+}
+
+func (c *memcacheCache) Delete(key string) {
+	if err := c.client.Delete(c.key(key)); err != nil && err != memcache.ErrCacheMiss {
+		keystone.Log("Failed to delete token from memcached: %v", err)
+	}
+}
+
+// Ping implements keystone.Pinger by checking all memcached servers
+func (c *memcacheCache) Ping(ctx context.Context) error {
+	return c.client.Ping()
+}
+
+// key hashes a cache key, memcached keys are limited to 250 characters
+func (c *memcacheCache) key(key string) string {
+	return crypt.Key(keyPrefix, c.keyKey, key)
+}
+
+// seal protects a payload according to the security strategy
+func (c *memcacheCache) seal(b []byte) []byte {
+	switch c.strategy {
+	case MAC:
+		return crypt.SealMAC(c.macKey, b)
+	case Encrypt:
+		return crypt.Seal(c.aead, b)
+	}
+	return b
+}
+
+// open verifies and decrypts a payload protected by seal
+func (c *memcacheCache) open(b []byte) ([]byte, error) {
+	switch c.strategy {
+	case MAC:
+		return crypt.OpenMAC(c.macKey, b)
+	case Encrypt:
+		return crypt.Open(c.aead, b)
+	}
+	return b, nil
+}
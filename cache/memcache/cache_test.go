@@ -0,0 +1,52 @@
+package memcache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestKeyHashing(t *testing.T) {
+	token := strings.Repeat("gAAAAA", 50)
+	for _, strategy := range []SecurityStrategy{None, MAC, Encrypt} {
+		c := New(nil, strategy, "secret").(*memcacheCache)
+		key := c.key(token)
+		if len(key) > 250 || strings.Contains(key, token) {
+			t.Errorf("Invalid memcached key for strategy %d: %s", strategy, key)
+		}
+		if key != c.key(token) {
+			t.Error("Expected stable key")
+		}
+	}
+	plain := New(nil, None, "").(*memcacheCache)
+	keyed := New(nil, MAC, "secret").(*memcacheCache)
+	if plain.key(token) == keyed.key(token) {
+		t.Error("Expected keys to depend on the secret")
+	}
+}
+
+func TestSecurityStrategies(t *testing.T) {
+	payload := []byte(`{"user":{"id":"u-1"}}`)
+	for _, strategy := range []SecurityStrategy{None, MAC, Encrypt} {
+		c := New(nil, strategy, "secret").(*memcacheCache)
+		sealed := c.seal(payload)
+		if strategy == Encrypt && bytes.Contains(sealed, []byte("u-1")) {
+			t.Error("Expected encrypted payload")
+		}
+		opened, err := c.open(sealed)
+		if err != nil || !bytes.Equal(opened, payload) {
+			t.Errorf("Strategy %d: expected %s, got %s (%v)", strategy, payload, opened, err)
+		}
+		if strategy == None {
+			continue
+		}
+		sealed[len(sealed)-2] ^= 1
+		if _, err := c.open(sealed); err != ErrInvalidPayload {
+			t.Errorf("Strategy %d: expected tampered payload to be rejected, got %v", strategy, err)
+		}
+		other := New(nil, strategy, "other").(*memcacheCache)
+		if _, err := other.open(c.seal(payload)); err != ErrInvalidPayload {
+			t.Errorf("Strategy %d: expected payload sealed with different secret to be rejected", strategy)
+		}
+	}
+}
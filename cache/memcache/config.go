@@ -0,0 +1,47 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/databus23/keystone"
+)
+
+// FromConfig creates a cache using the memcached_servers, memcache_security_strategy and
+// memcache_secret_key options of a keystonemiddleware configuration:
+//
+//	config, err := keystone.LoadConfig("/etc/nova/nova.conf")
+//	...
+//	auth, err := config.NewAuth()
+//	...
+//	if len(config.MemcachedServers) > 0 {
+//		auth.TokenCache, err = memcache.FromConfig(config)
+//	}
+func FromConfig(c *keystone.Config) (keystone.Cache, error) {
+	if len(c.MemcachedServers) == 0 {
+		return nil, errors.New("memcached_servers is required")
+	}
+	var strategy SecurityStrategy
+	switch s := strings.ToLower(c.MemcacheSecurityStrategy); s {
+	case "", "none":
+		strategy = None
+	case "mac":
+		strategy = MAC
+	case "encrypt":
+		strategy = Encrypt
+	default:
+		return nil, fmt.Errorf("invalid memcache_security_strategy %q", c.MemcacheSecurityStrategy)
+	}
+	if strategy != None && c.MemcacheSecretKey == "" {
+		return nil, errors.New("memcache_secret_key is required for memcache_security_strategy " + c.MemcacheSecurityStrategy)
+	}
+	//keystonemiddleware accepts inet:host:port and inet6:[host]:port
+	servers := make([]string, len(c.MemcachedServers))
+	for i, s := range c.MemcachedServers {
+		s = strings.TrimPrefix(s, "inet:")
+		servers[i] = strings.TrimPrefix(s, "inet6:")
+	}
+	return New(memcache.New(servers...), strategy, c.MemcacheSecretKey), nil
+}
@@ -0,0 +1,29 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/databus23/keystone"
+)
+
+func TestFromConfig(t *testing.T) {
+	cases := []struct {
+		config keystone.Config
+		valid  bool
+	}{
+		{keystone.Config{MemcachedServers: []string{"localhost:11211"}}, true},
+		{keystone.Config{MemcachedServers: []string{"inet:localhost:11211"}, MemcacheSecurityStrategy: "ENCRYPT", MemcacheSecretKey: "secret"}, true},
+		{keystone.Config{MemcachedServers: []string{"localhost:11211"}, MemcacheSecurityStrategy: "MAC"}, false},
+		{keystone.Config{MemcachedServers: []string{"localhost:11211"}, MemcacheSecurityStrategy: "rot13", MemcacheSecretKey: "secret"}, false},
+		{keystone.Config{}, false},
+	}
+	for _, c := range cases {
+		cache, err := FromConfig(&c.config)
+		if c.valid && (err != nil || cache == nil) {
+			t.Errorf("%+v: expected cache, got %v", c.config, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("%+v: expected error", c.config)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/databus23/keystone/cache/memcache
+
+go 1.22
+
+require (
+	github.com/bradfitz/gomemcache v0.0.0-20230905024940-24af94b03874
+	github.com/databus23/keystone v0.1.0
+)
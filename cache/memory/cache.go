@@ -3,19 +3,28 @@ package memory
 
 import (
 	"encoding/json"
+	"sync"
 	"time"
 
-	"github.com/pmylund/go-cache"
 	"github.com/databus23/keystone"
+	"github.com/patrickmn/go-cache"
 )
 
 type memoryCache struct {
 	*cache.Cache
+
+	mu           sync.RWMutex
+	onEvict      func(string, keystone.EvictReason)
+	invalidating sync.Map
 }
 
-//New creates a new cache.
+// New creates a new cache.
+//
+// The returned cache also implements keystone.Deleter, keystone.Flusher and keystone.EvictionNotifier.
 func New(cleanupInterval time.Duration) keystone.Cache {
-	return &memoryCache{cache.New(5*time.Minute, cleanupInterval)}
+	m := &memoryCache{Cache: cache.New(5*time.Minute, cleanupInterval)}
+	m.Cache.OnEvicted(m.evicted)
+	return m
 }
 
 func (m *memoryCache) Set(k string, x interface{}, ttl time.Duration) {
@@ -29,3 +38,35 @@ func (m *memoryCache) Get(k string, x interface{}) bool {
 	}
 	return false
 }
+
+func (m *memoryCache) Delete(k string) {
+	m.invalidating.Store(k, true)
+	defer m.invalidating.Delete(k)
+	m.Cache.Delete(k)
+}
+
+func (m *memoryCache) Flush() {
+	for k := range m.Cache.Items() {
+		m.Delete(k)
+	}
+}
+
+func (m *memoryCache) OnEvict(f func(string, keystone.EvictReason)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onEvict = f
+}
+
+func (m *memoryCache) evicted(k string, _ interface{}) {
+	m.mu.RLock()
+	f := m.onEvict
+	m.mu.RUnlock()
+	if f == nil {
+		return
+	}
+	reason := keystone.EvictExpired
+	if _, ok := m.invalidating.Load(k); ok {
+		reason = keystone.EvictInvalidated
+	}
+	f(k, reason)
+}
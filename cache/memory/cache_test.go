@@ -3,6 +3,8 @@ package memory
 import (
 	"testing"
 	"time"
+
+	"github.com/databus23/keystone"
 )
 
 func TestCache(t *testing.T) {
@@ -15,3 +17,33 @@ func TestCache(t *testing.T) {
 	}
 
 }
+
+func TestEvictionHook(t *testing.T) {
+	c := New(10 * time.Millisecond)
+	evicted := make(chan string, 2)
+	c.(keystone.EvictionNotifier).OnEvict(func(key string, reason keystone.EvictReason) {
+		evicted <- key + ":" + reason.String()
+	})
+
+	c.Set("delete", "blafasel", 1*time.Minute)
+	c.(keystone.Deleter).Delete("delete")
+	if e := <-evicted; e != "delete:invalidated" {
+		t.Errorf("Expected %q, got %q", "delete:invalidated", e)
+	}
+
+	c.Set("flush", "blafasel", 1*time.Minute)
+	c.(keystone.Flusher).Flush()
+	if e := <-evicted; e != "flush:invalidated" {
+		t.Errorf("Expected %q, got %q", "flush:invalidated", e)
+	}
+
+	c.Set("expire", "blafasel", 5*time.Millisecond)
+	select {
+	case e := <-evicted:
+		if e != "expire:expired" {
+			t.Errorf("Expected %q, got %q", "expire:expired", e)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("Expired entry was not evicted")
+	}
+}
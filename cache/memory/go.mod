@@ -0,0 +1,8 @@
+module github.com/databus23/keystone/cache/memory
+
+go 1.22
+
+require (
+	github.com/databus23/keystone v0.1.0
+	github.com/patrickmn/go-cache v2.1.0+incompatible
+)
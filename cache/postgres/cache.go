@@ -2,6 +2,7 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -19,6 +20,8 @@ type pgCache struct {
 
 // New creates a new cache.
 //
+// The returned cache implements keystone.CacheCtx and keystone.Pinger.
+//
 // The table parameter defaults to token_cache and must point to an existing datbase table conforming to the following schema:
 //  key text PRIMARY KEY,
 //  value text NOT NULL,
@@ -37,8 +40,13 @@ func New(db *sql.DB, cleanupInterval time.Duration, table string) keystone.Cache
 }
 
 func (s *pgCache) Set(key string, x interface{}, ttl time.Duration) {
+	s.SetCtx(context.Background(), key, x, ttl)
+}
+
+// SetCtx stores a value, aborting the database transaction if ctx is done
+func (s *pgCache) SetCtx(ctx context.Context, key string, x interface{}, ttl time.Duration) {
 	if b, err := json.Marshal(x); err == nil {
-		tx, err := s.db.Begin()
+		tx, err := s.db.BeginTx(ctx, nil)
 		if err != nil {
 			return
 		}
@@ -50,11 +58,11 @@ func (s *pgCache) Set(key string, x interface{}, ttl time.Duration) {
 			}
 		}()
 
-		if _, err = tx.Exec(fmt.Sprintf(`DELETE FROM "%s" WHERE key=$1`, s.table), key); err != nil {
+		if _, err = tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM "%s" WHERE key=$1`, s.table), key); err != nil {
 			keystone.Log("Failed to delete: %v", err)
 			return
 		}
-		if _, err = tx.Exec(fmt.Sprintf(`INSERT INTO "%s" (key,value,valid_until) VALUES ($1,$2,$3)`, s.table), key, string(b), time.Now().Add(ttl)); err != nil {
+		if _, err = tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO "%s" (key,value,valid_until) VALUES ($1,$2,$3)`, s.table), key, string(b), time.Now().Add(ttl)); err != nil {
 			keystone.Log("Failed to insert: %v", err)
 			return
 		}
@@ -62,8 +70,13 @@ func (s *pgCache) Set(key string, x interface{}, ttl time.Duration) {
 }
 
 func (s *pgCache) Get(k string, x interface{}) bool {
+	return s.GetCtx(context.Background(), k, x)
+}
+
+// GetCtx retrieves a value, giving up if ctx is done
+func (s *pgCache) GetCtx(ctx context.Context, k string, x interface{}) bool {
 	var data string
-	if err := s.db.QueryRow(fmt.Sprintf(`SELECT value FROM "%s" WHERE key=$1 AND now() < valid_until`, s.table), k).Scan(&data); err != nil {
+	if err := s.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT value FROM "%s" WHERE key=$1 AND now() < valid_until`, s.table), k).Scan(&data); err != nil {
 		return false
 	}
 	if json.Unmarshal([]byte(data), x) != nil {
@@ -72,6 +85,11 @@ func (s *pgCache) Get(k string, x interface{}) bool {
 	return true
 }
 
+// Ping implements keystone.Pinger
+func (s *pgCache) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
 func (s *pgCache) deleteExpired() {
 	s.db.Exec(fmt.Sprintf(`DELETE FROM "%s" WHERE valid_until < now()`, s.table))
 }
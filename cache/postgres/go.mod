@@ -0,0 +1,8 @@
+module github.com/databus23/keystone/cache/postgres
+
+go 1.22
+
+require (
+	github.com/databus23/keystone v0.1.0
+	github.com/lib/pq v1.10.9
+)
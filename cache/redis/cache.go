@@ -0,0 +1,155 @@
+// Package redis provides a Redis backed cache implementation for https://github.com/databus23/keystone
+//
+//	import rediscache "github.com/databus23/keystone/cache/redis"
+//
+//	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	auth.TokenCache = rediscache.New(client, rediscache.Options{Codec: rediscache.MsgPack, SecretKey: secret})
+package redis
+
+import (
+	"context"
+	"crypto/cipher"
+	"time"
+
+	"github.com/databus23/keystone"
+	"github.com/databus23/keystone/cache/internal/crypt"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrInvalidPayload is returned for cache entries failing decryption
+var ErrInvalidPayload = crypt.ErrInvalidPayload
+
+const defaultPrefix = "keystone/"
+
+// Options configures a Redis cache
+type Options struct {
+	//Prefix of all keys stored by the cache, defaults to "keystone/"
+	Prefix string
+	//Serialization of cached entries, defaults to JSON
+	Codec Codec
+	//If set, entries are encrypted and authenticated with AES-256-GCM and keys are hashed with HMAC-SHA256
+	//using keys derived from SecretKey. Otherwise keys are hashed with SHA-256, so tokens are never stored verbatim.
+	SecretKey string
+}
+
+type redisCache struct {
+	client redis.UniversalClient
+	prefix string
+	codec  Codec
+	keyKey []byte
+	aead   cipher.AEAD
+}
+
+// New creates a new cache storing entries using client, which may be a single node, sentinel or cluster client.
+//
+// The returned cache implements keystone.CacheCtx, keystone.Deleter, keystone.Flusher and keystone.Pinger.
+func New(client redis.UniversalClient, opts Options) keystone.Cache {
+	c := &redisCache{client: client, prefix: opts.Prefix, codec: opts.Codec}
+	if c.prefix == "" {
+		c.prefix = defaultPrefix
+	}
+	if c.codec == nil {
+		c.codec = JSON
+	}
+	if opts.SecretKey != "" {
+		c.keyKey = crypt.Derive(opts.SecretKey, "key")
+		c.aead = crypt.NewAEAD(opts.SecretKey)
+	}
+	return c
+}
+
+func (c *redisCache) Set(key string, x interface{}, ttl time.Duration) {
+	c.SetCtx(context.Background(), key, x, ttl)
+}
+
+// SetCtx stores a value, giving up if ctx is done
+func (c *redisCache) SetCtx(ctx context.Context, key string, x interface{}, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	b, err := c.codec.Marshal(x)
+	if err != nil {
+		keystone.Log("Failed to encode token for redis: %v", err)
+		return
+	}
+	if err := c.client.Set(ctx, c.key(key), c.seal(b), ttl).Err(); err != nil {
+		keystone.Log("Failed to store token in redis: %v", err)
+	}
+}
+
+func (c *redisCache) Get(key string, x interface{}) bool {
+	return c.GetCtx(context.Background(), key, x)
+}
+
+// GetCtx retrieves a value, giving up if ctx is done
+func (c *redisCache) GetCtx(ctx context.Context, key string, x interface{}) bool {
+	b, err := c.client.Get(ctx, c.key(key)).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			keystone.Log("Failed to get token from redis: %v", err)
+		}
+		return false
+	}
+	if b, err = c.open(b); err != nil {
+		keystone.Log("Ignoring cached token: %v", err)
+		return false
+	}
+	return c.codec.Unmarshal(b, x) == nil
+}
+
+func (c *redisCache) Delete(key string) {
+	if err := c.client.Del(context.Background(), c.key(key)).Err(); err != nil {
+		keystone.Log("Failed to delete token from redis: %v", err)
+	}
+}
+
+// Ping implements keystone.Pinger
+func (c *redisCache) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+// Flush removes all keys with the cache's prefix
+func (c *redisCache) Flush() {
+	ctx := context.Background()
+	flush := func(ctx context.Context, client redis.UniversalClient) error {
+		iter := client.Scan(ctx, 0, c.prefix+"*", 100).Iterator()
+		for iter.Next(ctx) {
+			if err := client.Del(ctx, iter.Val()).Err(); err != nil {
+				return err
+			}
+		}
+		return iter.Err()
+	}
+	var err error
+	if cluster, ok := c.client.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return flush(ctx, node)
+		})
+	} else {
+		err = flush(ctx, c.client)
+	}
+	if err != nil {
+		keystone.Log("Failed to flush redis cache: %v", err)
+	}
+}
+
+// key hashes a cache key, the middleware may use raw tokens as keys (see keystone.Auth.RawCacheKeys)
+func (c *redisCache) key(key string) string {
+	return crypt.Key(c.prefix, c.keyKey, key)
+}
+
+// seal encrypts a payload if a secret key is configured
+func (c *redisCache) seal(b []byte) []byte {
+	if c.aead == nil {
+		return b
+	}
+	return crypt.Seal(c.aead, b)
+}
+
+// open decrypts a payload protected by seal
+func (c *redisCache) open(b []byte) ([]byte, error) {
+	if c.aead == nil {
+		return b, nil
+	}
+	return crypt.Open(c.aead, b)
+}
@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/databus23/keystone"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCache(t *testing.T) {
+	var validations int
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		validations++
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z", "user": {"id": "u-secret", "domain": {"id": "default"}},
+			"project": {"id": "p-1", "domain": {"id": "default"}}, "roles": [{"id": "r-1", "name": "member"}]}}`)
+	}))
+	defer idServer.Close()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	for _, opts := range []Options{
+		{},
+		{Codec: MsgPack},
+		{Prefix: "svc/", Codec: MsgPack, SecretKey: "secret"},
+	} {
+		server.FlushAll()
+		validations = 0
+		cache := New(client, opts)
+		auth := keystone.New(idServer.URL)
+		auth.RawCacheKeys = true
+		auth.TokenCache = cache
+		for i := 0; i < 2; i++ {
+			token, err := auth.Validate("my-token")
+			if err != nil {
+				t.Fatalf("%+v: %v", opts, err)
+			}
+			if token.User.ID != "u-secret" || token.Project.ID != "p-1" || !keystone.HasRole("member")(token) {
+				t.Errorf("%+v: unexpected token %+v", opts, token)
+			}
+		}
+		if validations != 1 {
+			t.Errorf("%+v: expected cached token, got %d validations", opts, validations)
+		}
+
+		keys := server.Keys()
+		if len(keys) != 1 {
+			t.Fatalf("%+v: expected one key, got %v", opts, keys)
+		}
+		prefix := opts.Prefix
+		if prefix == "" {
+			prefix = defaultPrefix
+		}
+		if !strings.HasPrefix(keys[0], prefix) || strings.Contains(keys[0], "my-token") {
+			t.Errorf("%+v: unexpected key %s", opts, keys[0])
+		}
+		if ttl := server.TTL(keys[0]); ttl <= 0 || ttl > auth.CacheTime {
+			t.Errorf("%+v: unexpected ttl %s", opts, ttl)
+		}
+		value, _ := server.Get(keys[0])
+		if encrypted := opts.SecretKey != ""; encrypted == strings.Contains(value, "u-secret") {
+			t.Errorf("%+v: expected payload to be encrypted: %v", opts, encrypted)
+		}
+
+		cache.(keystone.Flusher).Flush()
+		if keys := server.Keys(); len(keys) != 0 {
+			t.Errorf("%+v: expected flush to remove all keys, got %v", opts, keys)
+		}
+	}
+}
+
+func TestInvalidPayload(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	c := New(client, Options{SecretKey: "secret"})
+	c.Set("k", map[string]string{"a": "b"}, time.Minute)
+	other := New(client, Options{SecretKey: "other"}).(*redisCache)
+	sealed, _ := server.Get(c.(*redisCache).key("k"))
+	server.Set(other.key("k"), sealed)
+	var v map[string]string
+	if other.Get("k", &v) {
+		t.Error("Expected payload sealed with a different secret to be ignored")
+	}
+	if !c.Get("k", &v) || v["a"] != "b" {
+		t.Errorf("Expected cached value, got %v", v)
+	}
+	c.(keystone.Deleter).Delete("k")
+	if c.Get("k", &v) {
+		t.Error("Expected deleted entry to be gone")
+	}
+}
@@ -0,0 +1,44 @@
+package keystone
+
+import (
+	"net/http"
+	"strings"
+)
+
+// defaultCacheControl is the default of Auth.CacheControl
+const defaultCacheControl = "private"
+
+// cacheHeaders returns a function marking responses influenced by the identity of the request as such
+// for shared caches. It is applied when the response header is written, so headers set by the handler win.
+// nil is returned if DisableCacheHeaders is set.
+func (h *handler) cacheHeaders(req *http.Request, route *Route) func(http.Header) {
+	if h.DisableCacheHeaders {
+		return nil
+	}
+	rejecting := h.RejectUnauthenticated || route != nil && route.Access == TokenRequired
+	private := req.Header.Get("X-Auth-Token") != "" || rejecting
+	cacheControl := h.CacheControl
+	if cacheControl == "" {
+		cacheControl = defaultCacheControl
+	}
+	return func(header http.Header) {
+		if !varies(header, "X-Auth-Token") {
+			header.Add("Vary", "X-Auth-Token")
+		}
+		if private && header.Get("Cache-Control") == "" {
+			header.Set("Cache-Control", cacheControl)
+		}
+	}
+}
+
+// varies reports whether the Vary header already lists the header
+func varies(header http.Header, name string) bool {
+	for _, v := range header.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if field = strings.TrimSpace(field); field == "*" || strings.EqualFold(field, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
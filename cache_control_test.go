@@ -0,0 +1,71 @@
+package keystone
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCacheHeaders(t *testing.T) {
+	idServer := identityMock(200, validTokenBody)
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.Routes = []Route{
+		{Pattern: "/public", Access: Anonymous},
+		{Pattern: "/required", Access: TokenRequired},
+	}
+	h := a.Handler(okHandler)
+
+	for _, tc := range []struct {
+		path, token, vary, cacheControl string
+	}{
+		{"/public", "1234", "", ""},
+		{"/optional", "", "X-Auth-Token", ""},
+		{"/optional", "1234", "X-Auth-Token", "private"},
+		{"/required", "", "X-Auth-Token", "private"},
+	} {
+		req := httptest.NewRequest("GET", tc.path, nil)
+		if tc.token != "" {
+			req.Header.Set("X-Auth-Token", tc.token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if v := rec.Header().Get("Vary"); v != tc.vary {
+			t.Errorf("Expected Vary %q for %s with token %q, got %q", tc.vary, tc.path, tc.token, v)
+		}
+		if v := rec.Header().Get("Cache-Control"); v != tc.cacheControl {
+			t.Errorf("Expected Cache-Control %q for %s with token %q, got %q", tc.cacheControl, tc.path, tc.token, v)
+		}
+	}
+
+	a.CacheControl = "no-store"
+	req := httptest.NewRequest("GET", "/optional", nil)
+	req.Header.Set("X-Auth-Token", "1234")
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Vary", "Accept-Encoding, x-auth-token")
+	h.ServeHTTP(rec, req)
+	if v := rec.Header().Values("Vary"); len(v) != 1 {
+		t.Errorf("Expected Vary not to be duplicated, got %q", v)
+	}
+	if v := rec.Header().Get("Cache-Control"); v != "no-store" {
+		t.Errorf("Expected configured Cache-Control, got %q", v)
+	}
+
+	a.DisableCacheHeaders = true
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if len(rec.Header()) != 1 {
+		t.Errorf("Expected no cache headers, got %v", rec.Header())
+	}
+
+	override := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=60")
+	}))
+	a.DisableCacheHeaders = false
+	rec = httptest.NewRecorder()
+	override.ServeHTTP(rec, req)
+	if v := rec.Header().Get("Cache-Control"); v != "public, max-age=60" {
+		t.Errorf("Expected handler to override Cache-Control, got %q", v)
+	}
+}
@@ -0,0 +1,98 @@
+package keystone
+
+import (
+	"context"
+	"time"
+)
+
+// cacheFormat is the version of the payloads written to token caches.
+// It has to be increased on incompatible changes of the payloads (e.g. the Token struct),
+// so entries written by older versions are ignored instead of being misinterpreted.
+// Adding fields is compatible unless token contexts lacking them would be handled wrongly.
+//
+//	1: initial version
+//	2: token contexts carry audit IDs, which are required for revocation checks
+const cacheFormat = 2
+
+// payloadHeader tags cached payloads with their type and format version
+type payloadHeader struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+}
+
+func newPayloadHeader(typ string) payloadHeader {
+	return payloadHeader{Type: typ, Version: cacheFormat}
+}
+
+// check reports if a payload read from the cache has the expected type and version.
+// Entries of older versions are expected after upgrades and silently ignored. Other mismatches are logged,
+// they indicate a cache shared with other applications or newer versions.
+func (p payloadHeader) check(key, typ string) bool {
+	if p.Type == typ && p.Version == cacheFormat {
+		return true
+	}
+	if p.Type == typ && p.Version < cacheFormat {
+		return false
+	}
+	Log("WARNING: Ignoring cache entry %s of type %q version %d, expected type %q version %d",
+		RedactToken(key), p.Type, p.Version, typ, cacheFormat)
+	return false
+}
+
+// cachedToken is the payload of the token cache.
+// Tokens known to be invalid are cached with the error returned by Keystone, see Auth.InvalidCacheTime.
+type cachedToken struct {
+	payloadHeader
+	Token Token  `json:"token"`
+	Error *Error `json:"error,omitempty"`
+	//Time after which the token has to be revalidated, see Auth.StaleCacheTime
+	StaleAt time.Time `json:"stale_at,omitempty"`
+	//Time after which the token is revalidated in the background, see Auth.RefreshAhead
+	RefreshAt time.Time `json:"refresh_at,omitempty"`
+}
+
+// entryState is the freshness of a cached token
+type entryState int
+
+const (
+	entryFresh entryState = iota
+	//the token is served, but should be revalidated in the background
+	entryRefresh
+	//the token has to be revalidated and may only be served if Keystone is unavailable
+	entryStale
+)
+
+// getCachedToken reads a valid token context from the cache, tolerating clock skew of up to skew.
+// For tokens cached as invalid it returns the cached error.
+func getCachedToken(ctx context.Context, c Cache, key string, skew time.Duration) (token *Token, state entryState, ok bool, err error) {
+	var entry cachedToken
+	if !cacheGet(ctx, c, key, &entry) || !entry.check(key, "token") {
+		return nil, entryFresh, false, nil
+	}
+	if entry.Error != nil {
+		return nil, entryFresh, true, entry.Error
+	}
+	if !entry.Token.ValidWithSkew(skew) {
+		return nil, entryFresh, false, nil
+	}
+	now := time.Now()
+	switch {
+	case !entry.StaleAt.IsZero() && now.After(entry.StaleAt):
+		state = entryStale
+	case !entry.RefreshAt.IsZero() && now.After(entry.RefreshAt):
+		state = entryRefresh
+	}
+	return &entry.Token, state, true, nil
+}
+
+func newCachedToken(t *Token) cachedToken {
+	return cachedToken{payloadHeader: newPayloadHeader("token"), Token: *t}
+}
+
+func newCachedError(err *Error) cachedToken {
+	return cachedToken{payloadHeader: newPayloadHeader("token"), Error: err}
+}
+
+func newLoadedToken(t *Token, ttl time.Duration) loadedToken {
+	return loadedToken{payloadHeader: newPayloadHeader("loaded_token"), Token: *t, Expires: time.Now().Add(ttl)}
+}
@@ -0,0 +1,68 @@
+package keystone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachedTokenMismatch(t *testing.T) {
+	valid := Token{ExpiresAt: time.Now().Add(time.Minute), IssuedAt: time.Now()}
+	future := newCachedToken(&valid)
+	future.Version = cacheFormat + 1
+	old := newCachedToken(&valid)
+	old.Version = cacheFormat - 1
+
+	cache := cacheMock{}
+	cache.Set("current", newCachedToken(&valid), time.Minute)
+	cache.Set("legacy", valid, time.Minute)
+	cache.Set("future", future, time.Minute)
+	cache.Set("old", old, time.Minute)
+	cache.Set("string", "not a token", time.Minute)
+	cache["garbage"] = json.RawMessage(`{"type": 42}`)
+
+	var warnings []string
+	defer func(log func(string, ...interface{})) { Log = log }(Log)
+	Log = func(format string, a ...interface{}) { warnings = append(warnings, fmt.Sprintf(format, a...)) }
+
+	if _, _, ok, _ := getCachedToken(context.Background(), &cache, "current", 0); !ok {
+		t.Error("Expected current payload to be found")
+	}
+	for _, key := range []string{"legacy", "future", "old", "string", "garbage"} {
+		if token, _, ok, _ := getCachedToken(context.Background(), &cache, key, 0); ok {
+			t.Errorf("Expected mismatched payload %s to be a miss, got %+v", key, token)
+		}
+	}
+	if len(warnings) != 2 {
+		t.Errorf("Expected warnings for legacy and future payloads, got %v", warnings)
+	}
+}
+
+func TestInvalidTokenCache(t *testing.T) {
+	var calls int32
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error": {"code": 404, "message": "Could not find token", "title": "Not Found"}}`)
+	}))
+	defer idServer.Close()
+
+	cache := cacheMock{}
+	a := Auth{Endpoint: idServer.URL, TokenCache: &cache, InvalidCacheTime: time.Minute}
+	a.Handler(okHandler)
+	for i := 0; i < 3; i++ {
+		_, err := a.Validate("1234")
+		if kerr, ok := err.(*Error); !ok || kerr.StatusCode != http.StatusNotFound || kerr.Message != "Could not find token" {
+			t.Errorf("Expected cached keystone error, got %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected invalid token to be validated once, got %d calls", n)
+	}
+}
@@ -0,0 +1,109 @@
+package keystone
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type ctxKey struct{}
+
+type ctxCacheMock struct {
+	cacheMock
+	contexts []context.Context
+}
+
+func (c *ctxCacheMock) GetCtx(ctx context.Context, k string, v interface{}) bool {
+	c.contexts = append(c.contexts, ctx)
+	return c.cacheMock.Get(k, v)
+}
+
+func (c *ctxCacheMock) SetCtx(ctx context.Context, k string, v interface{}, ttl time.Duration) {
+	c.contexts = append(c.contexts, ctx)
+	c.cacheMock.Set(k, v, ttl)
+}
+
+func TestCacheCtx(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z"}}`)
+	defer idServer.Close()
+
+	cache := &ctxCacheMock{cacheMock: cacheMock{}}
+	a := New(idServer.URL)
+	a.TokenCache = cache
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+	if _, err := a.ValidateContext(ctx, "1234"); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.contexts) != 2 {
+		t.Fatalf("Expected GetCtx and SetCtx to be called, got %d calls", len(cache.contexts))
+	}
+	for _, c := range cache.contexts {
+		if c.Value(ctxKey{}) != "request" {
+			t.Error("Cache wasn't passed the request context")
+		}
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	cache := cacheMock{}
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z"}}`)
+	defer idServer.Close()
+
+	keys := map[string]bool{}
+	for _, a := range []*Auth{
+		{Endpoint: idServer.URL, TokenCache: &cache},
+		{Endpoint: idServer.URL, TokenCache: &cache, CacheKeySecret: "secret"},
+		{Endpoint: idServer.URL, TokenCache: &cache, RawCacheKeys: true},
+	} {
+		a.Handler(okHandler)
+		if _, err := a.Validate("1234"); err != nil {
+			t.Fatal(err)
+		}
+		key := a.cacheKey("1234")
+		if _, ok := cache[key]; !ok {
+			t.Errorf("Expected token to be cached under %s", key)
+		}
+		keys[key] = true
+	}
+	if len(keys) != 3 || !keys["1234"] {
+		t.Errorf("Expected distinct hashed, keyed and raw cache keys, got %v", keys)
+	}
+}
+
+func TestFlushCache(t *testing.T) {
+	idServer := identityMock(200, validTokenBody)
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	if err := a.FlushCache(); err == nil {
+		t.Error("Expected FlushCache without cache to fail")
+	}
+	if s := a.CacheStats(); s.Entries != -1 {
+		t.Errorf("Expected unknown number of entries, got %d", s.Entries)
+	}
+	cache := NewInMemoryCache(10)
+	for _, configure := range []func(){
+		func() { a.TokenCache = cache },
+		func() { a.TokenCache, a.LoadingCache = nil, NewLoadingCache(cache, 0) },
+	} {
+		configure()
+		for _, token := range []string{"1", "2", "1"} {
+			if _, err := a.Validate(token); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if s := a.CacheStats(); s.Entries != 2 {
+			t.Fatalf("Expected 2 cached entries, got %d", s.Entries)
+		}
+		if err := a.FlushCache(); err != nil {
+			t.Fatal(err)
+		}
+		if s := a.CacheStats(); s.Entries != 0 {
+			t.Errorf("Expected cache to be flushed, got %d entries", s.Entries)
+		}
+	}
+	if s := a.CacheStats(); s.Hits != 2 || s.Misses != 4 {
+		t.Errorf("Expected 2 hits and 4 misses, got %+v", s)
+	}
+}
@@ -0,0 +1,75 @@
+package keystone
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// cacheLookup is the result of reading a token context from the TokenCache
+type cacheLookup struct {
+	token *Token
+	state entryState
+	ok    bool
+	err   error
+}
+
+// getCached reads the token context of key from the TokenCache. If the lookup takes longer than
+// Auth.CacheTimeout it returns a miss together with a channel delivering the result of the pending lookup.
+func (a *Auth) getCached(ctx context.Context, key string) (cacheLookup, <-chan cacheLookup) {
+	lookup := func(ctx context.Context) (res cacheLookup) {
+		res.token, res.state, res.ok, res.err = getCachedToken(ctx, a.TokenCache, key, a.ClockSkew)
+		return res
+	}
+	if a.CacheTimeout <= 0 {
+		return lookup(ctx), nil
+	}
+	lookupCtx, cancel := ctx, context.CancelFunc(func() {})
+	if !a.CacheTimeoutRace {
+		//caches implementing CacheCtx give up once the lookup is abandoned
+		lookupCtx, cancel = context.WithTimeout(ctx, a.CacheTimeout)
+	}
+	pending := make(chan cacheLookup, 1)
+	go func() {
+		defer cancel()
+		pending <- lookup(lookupCtx)
+	}()
+	timer := time.NewTimer(a.CacheTimeout)
+	defer timer.Stop()
+	select {
+	case res := <-pending:
+		return res, nil
+	case <-timer.C:
+		return cacheLookup{}, pending
+	}
+}
+
+// raceCache validates a token against Keystone while waiting for a slow cache lookup.
+// A cache hit is used if it arrives before Keystone's answer.
+func (a *Auth) raceCache(ctx context.Context, key, authToken string, pending <-chan cacheLookup) (*Token, bool, error) {
+	type result struct {
+		token *Token
+		ttl   time.Duration
+		err   error
+	}
+	loaded := make(chan result, 1)
+	go func() {
+		token, ttl, err := a.loadShared(ctx, a.Endpoint, authToken)
+		loaded <- result{token, ttl, err}
+	}()
+
+	select {
+	case res := <-pending:
+		if res.ok && res.state != entryStale {
+			a.cacheLookup(true)
+			a.log(ctx, slog.LevelDebug, "Token cache hit", "token", RedactToken(authToken))
+			return res.token, true, res.err
+		}
+	case r := <-loaded:
+		a.cacheLookup(false)
+		return a.loaded(ctx, authToken, nil, nil, r.token, r.ttl, r.err)
+	}
+	a.cacheLookup(false)
+	r := <-loaded
+	return a.loaded(ctx, authToken, nil, nil, r.token, r.ttl, r.err)
+}
@@ -0,0 +1,102 @@
+package keystone
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// slowCache answers lookups after a delay unless the context is done first
+type slowCache struct {
+	ttlCache
+	delay     time.Duration
+	token     *Token
+	cancelled chan struct{}
+}
+
+func (c *slowCache) GetCtx(ctx context.Context, key string, value interface{}) bool {
+	select {
+	case <-time.After(c.delay):
+	case <-ctx.Done():
+		close(c.cancelled)
+		return false
+	}
+	if c.token == nil {
+		return false
+	}
+	*value.(*cachedToken) = newCachedToken(c.token)
+	return true
+}
+
+func (c *slowCache) SetCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+	c.ttlCache.Set(key, value, ttl)
+}
+
+func TestCacheTimeout(t *testing.T) {
+	idServer := identityMock(200, validTokenBody)
+	defer idServer.Close()
+
+	cache := &slowCache{ttlCache: ttlCache{}, delay: time.Minute, cancelled: make(chan struct{})}
+	a := New(idServer.URL)
+	a.TokenCache = cache
+	a.CacheTimeout = 10 * time.Millisecond
+
+	start := time.Now()
+	token, err := a.Validate("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.User.ID != "u" || time.Since(start) > 10*time.Second {
+		t.Errorf("Expected token to be validated against Keystone after the timeout, got %+v after %s", token, time.Since(start))
+	}
+	select {
+	case <-cache.cancelled:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected abandoned cache lookup to be cancelled")
+	}
+	if _, found := cache.ttlCache[hashToken("1234")]; !found {
+		t.Error("Expected token validated against Keystone to be cached")
+	}
+	if s := a.Stats(); s.CacheTimeouts != 1 {
+		t.Errorf("Expected 1 cache timeout, got %d", s.CacheTimeouts)
+	}
+}
+
+func TestCacheTimeoutRace(t *testing.T) {
+	keystoneDelay := make(chan time.Duration, 1)
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(<-keystoneDelay)
+		io.WriteString(w, validTokenBody)
+	}))
+	defer idServer.Close()
+
+	cachedToken := &Token{ExpiresAt: time.Now().Add(time.Hour)}
+	cachedToken.User.ID = "cached"
+	cache := &slowCache{ttlCache: ttlCache{}, token: cachedToken}
+	a := New(idServer.URL)
+	a.TokenCache = cache
+	a.CacheTimeout = 10 * time.Millisecond
+	a.CacheTimeoutRace = true
+
+	for _, c := range []struct {
+		cacheDelay, keystoneDelay time.Duration
+		user                      string
+		cached                    bool
+	}{
+		{50 * time.Millisecond, 500 * time.Millisecond, "cached", true},
+		{500 * time.Millisecond, 0, "u", false},
+	} {
+		cache.delay = c.cacheDelay
+		keystoneDelay <- c.keystoneDelay
+		token, cached, err := a.validateToken(context.Background(), "1234")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.User.ID != c.user || cached != c.cached {
+			t.Errorf("Expected user %s (cached: %v), got %s (cached: %v)", c.user, c.cached, token.User.ID, cached)
+		}
+	}
+}
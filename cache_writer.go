@@ -0,0 +1,133 @@
+package keystone
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// coalesceWindow is the period in which cache writes for the same key are coalesced
+const coalesceWindow = 2 * time.Second
+
+// cacheWriter coalesces concurrent cache writes for the same key.
+// When several goroutines finish validating the same token at about the same time only
+// one of them writes to the cache and an entry is never replaced by one of the same content expiring earlier.
+// Writes changing the content, e.g. caching the error of a token revoked meanwhile, are never skipped.
+type cacheWriter struct {
+	mu        sync.Mutex
+	entries   map[string]*cacheWrite
+	lastSweep time.Time
+}
+
+type cacheWrite struct {
+	sync.Mutex
+	written time.Time
+	until   time.Time
+	//hash of the content written (see contentOf), zero if it couldn't be serialized
+	sum [sha256.Size]byte
+}
+
+func (w *cacheWriter) set(ctx context.Context, c Cache, key string, value interface{}, ttl time.Duration) {
+	var sum [sha256.Size]byte
+	if b, err := json.Marshal(contentOf(value)); err == nil {
+		sum = sha256.Sum256(b)
+	}
+
+	w.mu.Lock()
+	if w.entries == nil {
+		w.entries = make(map[string]*cacheWrite)
+	}
+	w.sweep(time.Now())
+	entry, ok := w.entries[key]
+	if !ok {
+		entry = &cacheWrite{}
+		w.entries[key] = entry
+	}
+	w.mu.Unlock()
+
+	entry.Lock()
+	defer entry.Unlock()
+	//taken after acquiring the lock, so writes of the same ttl are ordered by their expiry
+	now := time.Now()
+	until := now.Add(ttl)
+	if sum != [sha256.Size]byte{} && sum == entry.sum && now.Sub(entry.written) < coalesceWindow &&
+		until.Before(entry.until.Add(coalesceWindow)) {
+		//the same content living (about) as long or longer has just been written
+		return
+	}
+	cacheSet(ctx, c, key, value, ttl)
+	entry.written = time.Now()
+	entry.until = until
+	entry.sum = sum
+}
+
+// contentOf returns the part of a cached value compared for coalescing writes. The freshness bookkeeping of
+// cached tokens differs between writes of the same token context and is ignored.
+func contentOf(value interface{}) interface{} {
+	if entry, ok := value.(cachedToken); ok {
+		entry.StaleAt, entry.RefreshAt = time.Time{}, time.Time{}
+		return entry
+	}
+	return value
+}
+
+// sweep removes bookkeeping for writes outside of the coalescing window. Must be called with w.mu held.
+func (w *cacheWriter) sweep(now time.Time) {
+	if now.Sub(w.lastSweep) < coalesceWindow {
+		return
+	}
+	w.lastSweep = now
+	for key, entry := range w.entries {
+		//skip entries which are currently being written
+		if !entry.TryLock() {
+			continue
+		}
+		if now.Sub(entry.written) >= coalesceWindow {
+			delete(w.entries, key)
+		}
+		entry.Unlock()
+	}
+}
+
+// cacheWriteQueue is the number of pending asynchronous cache writes per worker, see Auth.AsyncCacheWrites
+const cacheWriteQueue = 100
+
+type cacheJob struct {
+	ctx   context.Context
+	key   string
+	value interface{}
+	ttl   time.Duration
+}
+
+// asyncCacheWriter performs cache writes in a bounded pool of workers
+type asyncCacheWriter struct {
+	start sync.Once
+	jobs  chan cacheJob
+}
+
+// writeCache stores a value in the TokenCache, asynchronously if Auth.AsyncCacheWrites is set
+func (a *Auth) writeCache(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+	if a.AsyncCacheWrites <= 0 {
+		a.cacheWriter.set(ctx, a.TokenCache, key, value, ttl)
+		return
+	}
+	a.asyncWriter.start.Do(func() {
+		a.asyncWriter.jobs = make(chan cacheJob, a.AsyncCacheWrites*cacheWriteQueue)
+		for i := 0; i < a.AsyncCacheWrites; i++ {
+			go func() {
+				for job := range a.asyncWriter.jobs {
+					a.cacheWriter.set(job.ctx, a.TokenCache, job.key, job.value, job.ttl)
+				}
+			}()
+		}
+	})
+	//the write outlives the request
+	job := cacheJob{ctx: context.WithoutCancel(ctx), key: key, value: value, ttl: ttl}
+	select {
+	case a.asyncWriter.jobs <- job:
+	default:
+		a.stats.droppedCacheWrites.Add(1)
+	}
+}
@@ -0,0 +1,138 @@
+package keystone
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type countingCache struct {
+	sync.Mutex
+	sets int
+	ttl  time.Duration
+}
+
+func (c *countingCache) Get(k string, v interface{}) bool { return false }
+
+func (c *countingCache) Set(k string, v interface{}, ttl time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.sets++
+	c.ttl = ttl
+}
+
+func TestCacheWriteCoalescing(t *testing.T) {
+	var w cacheWriter
+	cache := &countingCache{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.set(context.Background(), cache, "1234", "value", 5*time.Minute)
+		}()
+	}
+	wg.Wait()
+	if cache.sets != 1 {
+		t.Fatalf("Expected 1 cache write, got %d", cache.sets)
+	}
+
+	w.set(context.Background(), cache, "1234", "value", 5*time.Minute+time.Second)
+	if cache.sets != 1 {
+		t.Fatalf("Expected entry living about as long to be coalesced, got %d writes", cache.sets)
+	}
+
+	w.set(context.Background(), cache, "1234", "value", 1*time.Minute)
+	if cache.sets != 1 || cache.ttl != 5*time.Minute {
+		t.Fatalf("Entry shouldn't be replaced by one expiring earlier. writes: %d, ttl: %s", cache.sets, cache.ttl)
+	}
+
+	w.set(context.Background(), cache, "1234", "value", 10*time.Minute)
+	if cache.sets != 2 || cache.ttl != 10*time.Minute {
+		t.Fatalf("Longer lived entry should replace shorter one. writes: %d, ttl: %s", cache.sets, cache.ttl)
+	}
+
+	w.set(context.Background(), cache, "1234", "changed", 1*time.Minute)
+	if cache.sets != 3 {
+		t.Fatalf("Changed value should be written, got %d writes", cache.sets)
+	}
+
+	w.set(context.Background(), cache, "5678", "value", 1*time.Minute)
+	if cache.sets != 4 {
+		t.Fatalf("Expected write for different key, got %d writes", cache.sets)
+	}
+}
+
+func TestCacheWriteChangedValue(t *testing.T) {
+	var w cacheWriter
+	cache := &countingCache{}
+	token := &Token{ExpiresAt: time.Now().Add(time.Hour)}
+	w.set(context.Background(), cache, "1234", newCachedToken(token), 5*time.Minute)
+	//bookkeeping of the entry doesn't make the token context differ
+	entry := newCachedToken(token)
+	entry.StaleAt = time.Now().Add(5 * time.Minute)
+	w.set(context.Background(), cache, "1234", entry, 5*time.Minute)
+	if cache.sets != 1 {
+		t.Fatalf("Expected unchanged token context to be coalesced, got %d writes", cache.sets)
+	}
+	token.Roles = append(token.Roles, struct {
+		ID   string
+		Name string
+	}{"r-1", "admin"})
+	w.set(context.Background(), cache, "1234", newCachedToken(token), 5*time.Minute)
+	if cache.sets != 2 {
+		t.Fatalf("Expected changed roles to be written, got %d writes", cache.sets)
+	}
+	w.set(context.Background(), cache, "1234", newCachedError(&Error{StatusCode: 404}), 5*time.Minute)
+	if cache.sets != 3 {
+		t.Fatalf("Expected revoked token to be written, got %d writes", cache.sets)
+	}
+}
+
+type blockingCache struct {
+	countingCache
+	release chan struct{}
+}
+
+func (c *blockingCache) Set(k string, v interface{}, ttl time.Duration) {
+	<-c.release
+	c.countingCache.Set(k, v, ttl)
+}
+
+func TestAsyncCacheWrites(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z"}}`)
+	defer idServer.Close()
+
+	cache := &blockingCache{release: make(chan struct{})}
+	a := Auth{Endpoint: idServer.URL, TokenCache: cache, AsyncCacheWrites: 1}
+	a.Handler(okHandler)
+
+	tokens := cacheWriteQueue + 50
+	for i := 0; i < tokens; i++ {
+		if _, err := a.Validate(strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dropped := a.Stats().DroppedCacheWrites
+	if dropped < 49 || dropped > 50 {
+		t.Errorf("Expected writes exceeding the queue to be dropped, got %d dropped writes", dropped)
+	}
+	close(cache.release)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		cache.Lock()
+		sets := cache.sets
+		cache.Unlock()
+		if uint64(sets) == uint64(tokens)-dropped {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d cache writes, got %d", uint64(tokens)-dropped, sets)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,29 @@
+package keystone
+
+// catalogHeader carries the JSON encoded service catalog, see Auth.IncludeServiceCatalog
+const catalogHeader = "X-Service-Catalog"
+
+// CatalogEntry is a service of the service catalog
+type CatalogEntry struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	Name      string            `json:"name"`
+	Endpoints []CatalogEndpoint `json:"endpoints"`
+}
+
+// CatalogEndpoint is an endpoint of a service in the service catalog
+type CatalogEndpoint struct {
+	ID        string `json:"id"`
+	Interface string `json:"interface"`
+	Region    string `json:"region"`
+	RegionID  string `json:"region_id"`
+	URL       string `json:"url"`
+}
+
+// validationURL returns the url for validating tokens against endpoint
+func (a *Auth) validationURL(endpoint string) string {
+	if a.IncludeServiceCatalog {
+		return endpoint + "/auth/tokens"
+	}
+	return endpoint + "/auth/tokens?nocatalog"
+}
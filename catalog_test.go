@@ -0,0 +1,45 @@
+package keystone
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIncludeServiceCatalog(t *testing.T) {
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, nocatalog := r.URL.Query()["nocatalog"]; nocatalog {
+			io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z"}}`)
+			return
+		}
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "catalog": [
+			{"id": "s-1", "type": "compute", "name": "nova", "endpoints": [
+				{"id": "e-1", "interface": "public", "region": "eu", "region_id": "eu", "url": "https://nova.example.com"}
+			]}
+		]}}`)
+	}))
+	defer idServer.Close()
+
+	var headers http.Header
+	a := New(idServer.URL)
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Auth-Token", "1234")
+	req.Header.Set(catalogHeader, "spoofed")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if v := headers.Get(catalogHeader); v != "" {
+		t.Errorf("Expected no catalog header by default, got %q", v)
+	}
+
+	a.IncludeServiceCatalog = true
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Auth-Token", "1234")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	expected := `[{"id":"s-1","type":"compute","name":"nova","endpoints":[{"id":"e-1","interface":"public","region":"eu","region_id":"eu","url":"https://nova.example.com"}]}]`
+	if v := headers.Get(catalogHeader); v != expected {
+		t.Errorf("Expected catalog header\n%s\ngot\n%s", expected, v)
+	}
+}
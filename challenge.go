@@ -0,0 +1,65 @@
+package keystone
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Challenge describes the WWW-Authenticate header sent along with 401 responses.
+// Different clients (Swift clients, OpenStack SDKs, browsers) expect different challenge formats.
+type Challenge struct {
+	//Authentication scheme, defaults to Keystone
+	Scheme string
+	//Optional realm parameter
+	Realm string
+	//Keystone url announced to clients via the uri parameter, defaults to Auth.Endpoint.
+	//Set to "-" to omit the parameter.
+	URI string
+	//Additional parameters
+	Params map[string]string
+}
+
+// String returns the value of the WWW-Authenticate header for the challenge
+func (c Challenge) String() string {
+	scheme := c.Scheme
+	if scheme == "" {
+		scheme = "Keystone"
+	}
+	params := []string{}
+	if c.Realm != "" {
+		params = append(params, "realm="+quote(c.Realm))
+	}
+	if c.URI != "" && c.URI != "-" {
+		params = append(params, "uri="+quote(c.URI))
+	}
+	keys := make([]string, 0, len(c.Params))
+	for k := range c.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		params = append(params, k+"="+quote(c.Params[k]))
+	}
+	if len(params) == 0 {
+		return scheme
+	}
+	return scheme + " " + strings.Join(params, ", ")
+}
+
+// SetChallenge sets the WWW-Authenticate header configured by Auth.Challenge on a response.
+// Handlers rejecting unauthenticated requests should call it before writing a 401 response.
+func (a *Auth) SetChallenge(w http.ResponseWriter) {
+	c := Challenge{}
+	if a.Challenge != nil {
+		c = *a.Challenge
+	}
+	if c.URI == "" {
+		c.URI = a.Endpoint
+	}
+	w.Header().Set("WWW-Authenticate", c.String())
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,25 @@
+package keystone
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChallenge(t *testing.T) {
+	cases := []struct {
+		challenge *Challenge
+		expected  string
+	}{
+		{nil, `Keystone uri="https://keystone:5000/v3"`},
+		{&Challenge{Scheme: "Basic", Realm: "swift", URI: "-"}, `Basic realm="swift"`},
+		{&Challenge{URI: "https://public:5000/v3", Params: map[string]string{"error": "invalid_token", "b": `"quoted"`}}, `Keystone uri="https://public:5000/v3", b="\"quoted\"", error="invalid_token"`},
+	}
+	for _, c := range cases {
+		a := Auth{Endpoint: "https://keystone:5000/v3", Challenge: c.challenge}
+		rec := httptest.NewRecorder()
+		a.SetChallenge(rec)
+		if v := rec.Header().Get("WWW-Authenticate"); v != c.expected {
+			t.Errorf("Expected %s, got %s", c.expected, v)
+		}
+	}
+}
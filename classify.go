@@ -0,0 +1,38 @@
+package keystone
+
+import "net/http"
+
+// Class is a category of requests, e.g. "interactive", "service", "health" or "webhook", see Auth.Classifier
+type Class string
+
+// ClassOptions configure how requests of a class are authenticated
+type ClassOptions struct {
+	//Identity requirements like those of a Route. Anonymous skips validation altogether.
+	Access Access
+	//For TokenRequired: the token needs to have any of these roles
+	Roles []string
+	//Identity headers passed on, overrides Auth.Headers if set
+	Headers HeaderSet
+	//Validation options for requests of the class, e.g. a shorter CacheTime.
+	//They replace options set by upstream middleware with WithValidationOptions.
+	Validation ValidationOptions
+}
+
+// classify applies the options of the request's class. It returns the route describing the class
+// or nil if the request doesn't belong to a configured class.
+func (a *Auth) classify(req *http.Request) (*http.Request, *Route) {
+	if a.Classifier == nil {
+		return req, nil
+	}
+	opts, ok := a.Classes[a.Classifier(req)]
+	if !ok {
+		return req, nil
+	}
+	return opts.apply(req)
+}
+
+// apply sets the validation options of the class on the request and returns the route describing the class
+func (opts *ClassOptions) apply(req *http.Request) (*http.Request, *Route) {
+	req = req.WithContext(WithValidationOptions(req.Context(), opts.Validation))
+	return req, &Route{Access: opts.Access, Roles: opts.Roles, Headers: opts.Headers}
+}
@@ -0,0 +1,54 @@
+package keystone
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type ttlCache map[string]time.Duration
+
+func (c ttlCache) Set(key string, _ interface{}, ttl time.Duration) { c[key] = ttl }
+func (c ttlCache) Get(string, interface{}) bool                     { return false }
+
+func TestClassifier(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u-1"}}}`)
+	defer idServer.Close()
+
+	cache := ttlCache{}
+	a := New(idServer.URL)
+	a.TokenCache = cache
+	a.Routes = []Route{{Pattern: "/**", Access: TokenRequired}}
+	a.Classifier = func(r *http.Request) Class { return Class(r.Header.Get("X-Class")) }
+	a.Classes = map[Class]ClassOptions{
+		"health":      {Access: Anonymous},
+		"interactive": {Validation: ValidationOptions{CacheTime: time.Minute}},
+	}
+	h := a.Handler(okHandler)
+
+	for _, tc := range []struct {
+		class, token string
+		status       int
+	}{
+		{"health", "", 200},
+		{"interactive", "", 200},
+		{"interactive", "1234", 200},
+		//unconfigured classes are matched against the routes
+		{"other", "", 401},
+	} {
+		req := httptest.NewRequest("GET", "/foo", nil)
+		req.Header.Set("X-Class", tc.class)
+		if tc.token != "" {
+			req.Header.Set("X-Auth-Token", tc.token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("Expected status %d for class %s, got %d", tc.status, tc.class, rec.Code)
+		}
+	}
+	if ttl := cache[hashToken("1234")]; ttl != time.Minute {
+		t.Errorf("Expected token to be cached for the class' CacheTime, got %s", ttl)
+	}
+}
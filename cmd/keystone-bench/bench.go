@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/databus23/keystone"
+)
+
+type benchConfig struct {
+	//Requests per second sent through the middleware
+	RPS      int
+	Duration time.Duration
+	//Number of workers sending requests, requests are dropped if all workers are busy
+	Concurrency int
+	//Tokens sent with the requests, each request picks one at random
+	Tokens []string
+}
+
+type benchResult struct {
+	Requests uint64
+	//Requests not sent because all workers were busy
+	Dropped   uint64
+	Statuses  map[int]uint64
+	Latencies []time.Duration
+	//Number of requests sent to Keystone
+	KeystoneCalls uint64
+	Cache         keystone.CacheStats
+	Elapsed       time.Duration
+}
+
+// countingTransport counts the requests sent to Keystone
+type countingTransport struct {
+	base  http.RoundTripper
+	calls atomic.Uint64
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls.Add(1)
+	return t.base.RoundTrip(req)
+}
+
+// instrument counts the requests auth sends to Keystone
+func instrument(auth *keystone.Auth) *countingTransport {
+	client := auth.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &countingTransport{base: base}
+	c := *client
+	c.Transport = t
+	auth.Client = &c
+	return t
+}
+
+// runBench drives requests through the middleware at the configured rate and collects the results
+func runBench(auth *keystone.Auth, cfg benchConfig) *benchResult {
+	transport := instrument(auth)
+	handler := auth.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Identity-Status") != "Confirmed" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+
+	res := &benchResult{Statuses: map[int]uint64{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	work := make(chan string)
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for token := range work {
+				req := httptest.NewRequest("GET", "/", nil)
+				req.Header.Set("X-Auth-Token", token)
+				rec := httptest.NewRecorder()
+				start := time.Now()
+				handler.ServeHTTP(rec, req)
+				latency := time.Since(start)
+				mu.Lock()
+				res.Statuses[rec.Code]++
+				res.Latencies = append(res.Latencies, latency)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(time.Second / time.Duration(cfg.RPS))
+	deadline := time.After(cfg.Duration)
+loop:
+	for {
+		select {
+		case <-deadline:
+			break loop
+		case <-ticker.C:
+			select {
+			case work <- cfg.Tokens[rand.Intn(len(cfg.Tokens))]:
+				res.Requests++
+			default:
+				res.Dropped++
+			}
+		}
+	}
+	ticker.Stop()
+	close(work)
+	wg.Wait()
+
+	res.Elapsed = time.Since(start)
+	res.KeystoneCalls = transport.calls.Load()
+	res.Cache = auth.CacheStats()
+	sort.Slice(res.Latencies, func(i, j int) bool { return res.Latencies[i] < res.Latencies[j] })
+	return res
+}
+
+// percentile returns the p-th percentile of the sorted latencies
+func (r *benchResult) percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(r.Latencies)-1) * p / 100)
+	return r.Latencies[i]
+}
+
+func (r *benchResult) report(w io.Writer) {
+	fmt.Fprintf(w, "requests:        %d in %s (%.1f/s), %d dropped\n",
+		r.Requests, r.Elapsed.Round(time.Millisecond), float64(r.Requests)/r.Elapsed.Seconds(), r.Dropped)
+	codes := make([]int, 0, len(r.Statuses))
+	for code := range r.Statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "status %d:      %d\n", code, r.Statuses[code])
+	}
+	fmt.Fprintf(w, "latency:         p50 %s  p90 %s  p99 %s  max %s\n",
+		r.percentile(50), r.percentile(90), r.percentile(99), r.percentile(100))
+	hitRate := 0.0
+	if lookups := r.Cache.Hits + r.Cache.Misses; lookups > 0 {
+		hitRate = 100 * float64(r.Cache.Hits) / float64(lookups)
+	}
+	fmt.Fprintf(w, "cache:           %d hits, %d misses (%.1f%% hit rate), %d entries\n",
+		r.Cache.Hits, r.Cache.Misses, hitRate, r.Cache.Entries)
+	fmt.Fprintf(w, "keystone calls:  %d (%.1f/s)\n", r.KeystoneCalls, float64(r.KeystoneCalls)/r.Elapsed.Seconds())
+}
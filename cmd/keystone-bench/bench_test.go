@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/databus23/keystone"
+)
+
+func TestRunBench(t *testing.T) {
+	mock := newMockKeystone(0)
+	defer mock.Close()
+
+	auth := keystone.New(mock.URL)
+	auth.TokenCache = keystone.NewInMemoryCache(10)
+	res := runBench(auth, benchConfig{RPS: 500, Duration: 200 * time.Millisecond, Concurrency: 5, Tokens: generateTokens(3)})
+
+	if res.Requests == 0 || uint64(len(res.Latencies)) != res.Requests {
+		t.Fatalf("Expected a latency for each of the %d requests, got %d", res.Requests, len(res.Latencies))
+	}
+	if res.Statuses[200] != res.Requests {
+		t.Errorf("Expected all requests to be confirmed, got %v", res.Statuses)
+	}
+	if res.KeystoneCalls == 0 || res.KeystoneCalls > 3 {
+		t.Errorf("Expected at most one Keystone call per token, got %d", res.KeystoneCalls)
+	}
+	if res.Cache.Hits+res.Cache.Misses != res.Requests || res.Cache.Entries != 3 {
+		t.Errorf("Unexpected cache stats %+v", res.Cache)
+	}
+	if res.percentile(50) > res.percentile(100) {
+		t.Error("Expected percentiles to be ordered")
+	}
+
+	var out strings.Builder
+	res.report(&out)
+	for _, want := range []string{"latency:", "hit rate", "keystone calls:"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected report to contain %q, got\n%s", want, out.String())
+		}
+	}
+}
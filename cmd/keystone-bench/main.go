@@ -0,0 +1,92 @@
+// Command keystone-bench drives requests through the keystone middleware to help sizing token caches
+// and Keystone capacity before production rollouts.
+//
+// Without -keystone an in-process mock Keystone accepting any token is used and -tokens distinct tokens are generated:
+//
+//	keystone-bench -rps 500 -duration 30s -tokens 1000 -cache-size 500 -mock-latency 50ms
+//
+// Against a real Keystone the tokens are read from a file, one per line:
+//
+//	keystone-bench -keystone https://keystone.example.com:5000/v3 -token-file tokens.txt -rps 50
+//
+// It reports the latency percentiles of the middleware, the cache hit rate and the number of requests sent to Keystone.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/databus23/keystone"
+)
+
+func main() {
+	endpoint := flag.String("keystone", "", "Keystone v3 endpoint, an in-process mock is used if empty")
+	tokenFile := flag.String("token-file", "", "File with tokens to send, one per line (required with -keystone)")
+	tokens := flag.Int("tokens", 100, "Number of distinct tokens sent to the mock Keystone")
+	mockLatency := flag.Duration("mock-latency", 20*time.Millisecond, "Response time of the mock Keystone")
+	rps := flag.Int("rps", 100, "Requests per second")
+	duration := flag.Duration("duration", 10*time.Second, "Duration of the benchmark")
+	concurrency := flag.Int("concurrency", 50, "Maximum number of concurrent requests")
+	cacheSize := flag.Int("cache-size", 1000, "Size of the in-memory token cache, 0 disables caching")
+	cacheTime := flag.Duration("cache-time", 5*time.Minute, "How long to cache validated tokens")
+	flag.Parse()
+
+	if *rps <= 0 || *concurrency <= 0 {
+		log.Fatal("-rps and -concurrency must be positive")
+	}
+	cfg := benchConfig{RPS: *rps, Duration: *duration, Concurrency: *concurrency}
+	if *endpoint == "" {
+		mock := newMockKeystone(*mockLatency)
+		defer mock.Close()
+		*endpoint = mock.URL
+		cfg.Tokens = generateTokens(*tokens)
+	} else {
+		if *tokenFile == "" {
+			log.Fatal("-token-file is required with -keystone")
+		}
+		var err error
+		if cfg.Tokens, err = readTokens(*tokenFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(cfg.Tokens) == 0 {
+		log.Fatal("No tokens to send")
+	}
+
+	auth := keystone.New(*endpoint)
+	auth.CacheTime = *cacheTime
+	if *cacheSize > 0 {
+		auth.TokenCache = keystone.NewInMemoryCache(*cacheSize)
+	}
+	log.Printf("Sending %d requests/s with %d tokens to %s for %s", cfg.RPS, len(cfg.Tokens), *endpoint, cfg.Duration)
+	runBench(auth, cfg).report(os.Stdout)
+}
+
+func generateTokens(n int) []string {
+	tokens := make([]string, n)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("bench-token-%d", i)
+	}
+	return tokens
+}
+
+func readTokens(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var tokens []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if token := strings.TrimSpace(scanner.Text()); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, scanner.Err()
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// newMockKeystone returns a Keystone answering every token validation with a valid project scoped token
+// after the given latency.
+func newMockKeystone(latency time.Duration) *httptest.Server {
+	expires := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/auth/tokens" || r.Method != http.MethodGet {
+			http.NotFound(w, r)
+			return
+		}
+		time.Sleep(latency)
+		token := r.Header.Get("X-Subject-Token")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"token": {"expires_at": %q, "user": {"id": "u-%s", "name": "bench", "domain": {"id": "default", "name": "Default"}}, `+
+			`"project": {"id": "p-1", "name": "bench", "domain": {"id": "default", "name": "Default"}}, "roles": [{"id": "r-1", "name": "member"}]}}`,
+			expires, token)
+	}))
+}
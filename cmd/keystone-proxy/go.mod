@@ -0,0 +1,13 @@
+module github.com/databus23/keystone/cmd/keystone-proxy
+
+go 1.25.0
+
+require (
+	github.com/databus23/keystone v0.1.0
+	golang.org/x/crypto v0.54.0
+)
+
+require (
+	golang.org/x/net v0.56.0 // indirect
+	golang.org/x/text v0.40.0 // indirect
+)
@@ -0,0 +1,91 @@
+// Command keystone-proxy is a reverse proxy authenticating requests against Openstack Keystone.
+//
+// Incoming requests are validated using the keystone middleware and forwarded to the upstream
+// together with the identity headers described in https://godoc.org/github.com/databus23/keystone.
+//
+//	keystone-proxy -keystone https://keystone.example.com:5000/v3 -upstream http://localhost:8080
+//
+// Requests can be routed to different upstreams based on the token's identity, e.g. for tenant sharded backends:
+//
+//	keystone-proxy ... -route project-prefix:p-eu=http://eu-backend:8080 -route role:admin=http://admin:8080
+//
+// Tokens can be validated with the credentials of a service user instead of the tokens themselves.
+// The password is read from the KEYSTONE_SERVICE_PASSWORD environment variable:
+//
+//	KEYSTONE_SERVICE_PASSWORD=... keystone-proxy ... -service-user proxy -service-project service
+//
+// or with an application credential:
+//
+//	KEYSTONE_APPLICATION_CREDENTIAL_SECRET=... keystone-proxy ... -application-credential-id ...
+//
+// Small deployments can terminate TLS in the proxy using certificates obtained automatically from Let's Encrypt:
+//
+//	keystone-proxy ... -listen 0.0.0.0:443 -acme-domains auth.example.com -acme-email ops@example.com
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/databus23/keystone"
+)
+
+func main() {
+	listen := flag.String("listen", "0.0.0.0:3000", "Address to listen on")
+	endpoint := flag.String("keystone", "", "Keystone v3 endpoint used for validating tokens")
+	upstream := flag.String("upstream", "", "Upstream url requests are forwarded to")
+	preserveHost := flag.Bool("preserve-host", false, "Pass the original Host header to the upstream")
+	trustForwarded := flag.Bool("trust-forwarded", false, "Keep X-Forwarded-* headers sent by clients (only enable behind another trusted proxy)")
+	var routes routeFlags
+	flag.Var(&routes, "route", "Route requests by identity to a different upstream: <attribute>:<value>=<upstream url>.\n"+
+		"Attributes are project-prefix, domain and role. Can be given multiple times, the first matching route wins")
+	cacheTime := flag.Duration("cache-time", 5*time.Minute, "How long to cache validated tokens")
+	acmeDomains := flag.String("acme-domains", "", "Comma separated domains to obtain certificates for via ACME (Let's Encrypt) and serve https on -listen")
+	acmeCacheDir := flag.String("acme-cache-dir", "acme-certs", "Directory for storing ACME certificates and account keys")
+	acmeEmail := flag.String("acme-email", "", "Contact email for the ACME account")
+	acmeHTTPListen := flag.String("acme-http-listen", "0.0.0.0:80", "Address for answering ACME http-01 challenges and redirecting http to https")
+	var service serviceUserConfig
+	flag.StringVar(&service.User, "service-user", "", "Service user for authenticating validation requests (password from KEYSTONE_SERVICE_PASSWORD)")
+	flag.StringVar(&service.UserDomain, "service-user-domain", "Default", "Domain name of the service user")
+	flag.StringVar(&service.Project, "service-project", "", "Project name the service user's token is scoped to")
+	flag.StringVar(&service.ProjectDomain, "service-project-domain", "Default", "Domain name of the service project")
+	flag.StringVar(&service.ApplicationCredentialID, "application-credential-id", "", "Application credential for authenticating validation requests (secret from KEYSTONE_APPLICATION_CREDENTIAL_SECRET)")
+	flag.Parse()
+	service.Password = os.Getenv("KEYSTONE_SERVICE_PASSWORD")
+	service.ApplicationCredentialSecret = os.Getenv("KEYSTONE_APPLICATION_CREDENTIAL_SECRET")
+
+	if *endpoint == "" || *upstream == "" {
+		log.Fatal("-keystone and -upstream are required")
+	}
+	target, err := url.Parse(*upstream)
+	if err != nil {
+		log.Fatalf("Invalid upstream url: %s", err)
+	}
+
+	auth := keystone.New(*endpoint)
+	auth.CacheTime = *cacheTime
+	if err := service.configure(auth); err != nil {
+		log.Fatal(err)
+	}
+	opts := proxyOptions{PreserveHost: *preserveHost, TrustForwarded: *trustForwarded}
+	var handler http.Handler = newProxy(target, opts)
+	if len(routes) > 0 {
+		for _, r := range routes {
+			r.proxy = newProxy(r.upstream, opts)
+			log.Printf("Routing %s %s to %s", r.attribute, r.value, r.upstream)
+		}
+		handler = &router{routes: routes, fallback: handler}
+	}
+
+	if *acmeDomains != "" {
+		m := newCertManager(*acmeDomains, *acmeCacheDir, *acmeEmail)
+		log.Printf("Listening on %s (https for %s), forwarding to %s", *listen, *acmeDomains, target)
+		log.Fatal(listenAndServeACME(*listen, *acmeHTTPListen, m, auth.Handler(handler)))
+	}
+	log.Printf("Listening on %s, forwarding to %s", *listen, target)
+	log.Fatal(http.ListenAndServe(*listen, auth.Handler(handler)))
+}
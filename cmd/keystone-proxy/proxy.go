@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+type proxyOptions struct {
+	//Pass the Host header of the incoming request to the upstream instead of the upstream's host
+	PreserveHost bool
+	//Keep X-Forwarded-* headers sent by the client and append to them.
+	//If false they are discarded as they can't be trusted at the edge.
+	TrustForwarded bool
+}
+
+func newProxy(target *url.URL, opts proxyOptions) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			setForwardedHeaders(r.Out, r.In, opts.TrustForwarded)
+			if opts.PreserveHost {
+				r.Out.Host = r.In.Host
+			}
+		},
+	}
+}
+
+// setForwardedHeaders sets the X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host
+// headers of the outgoing request based on the incoming one.
+func setForwardedHeaders(out, in *http.Request, trusted bool) {
+	clientIP, _, err := net.SplitHostPort(in.RemoteAddr)
+	if err != nil {
+		clientIP = in.RemoteAddr
+	}
+
+	proto := "http"
+	if in.TLS != nil {
+		proto = "https"
+	}
+	host := in.Host
+
+	forwardedFor := []string{}
+	if trusted {
+		if prior := in.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+			forwardedFor = append(forwardedFor, strings.Join(prior, ", "))
+		}
+		if p := in.Header.Get("X-Forwarded-Proto"); p != "" {
+			proto = p
+		}
+		if h := in.Header.Get("X-Forwarded-Host"); h != "" {
+			host = h
+		}
+	}
+	if clientIP != "" {
+		forwardedFor = append(forwardedFor, clientIP)
+	}
+
+	out.Header.Del("X-Forwarded-For")
+	if len(forwardedFor) > 0 {
+		out.Header.Set("X-Forwarded-For", strings.Join(forwardedFor, ", "))
+	}
+	out.Header.Set("X-Forwarded-Proto", proto)
+	out.Header.Set("X-Forwarded-Host", host)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestForwardedHeaders(t *testing.T) {
+	var upstreamReq *http.Request
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamReq = r
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+
+	cases := []struct {
+		opts    proxyOptions
+		xff     string
+		proto   string
+		fwdHost string
+		host    string
+	}{
+		{proxyOptions{}, "10.0.0.1", "http", "api.example.com", target.Host},
+		{proxyOptions{PreserveHost: true}, "10.0.0.1", "http", "api.example.com", "api.example.com"},
+		{proxyOptions{TrustForwarded: true}, "1.2.3.4, 10.0.0.1", "https", "edge.example.com", target.Host},
+	}
+
+	for i, c := range cases {
+		req := httptest.NewRequest("GET", "http://api.example.com/foo", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", "1.2.3.4")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "edge.example.com")
+
+		newProxy(target, c.opts).ServeHTTP(httptest.NewRecorder(), req)
+		if upstreamReq == nil {
+			t.Fatalf("case %d: upstream wasn't called", i)
+		}
+		if v := upstreamReq.Header.Get("X-Forwarded-For"); v != c.xff {
+			t.Errorf("case %d: X-Forwarded-For: expected %q, got %q", i, c.xff, v)
+		}
+		if v := upstreamReq.Header.Get("X-Forwarded-Proto"); v != c.proto {
+			t.Errorf("case %d: X-Forwarded-Proto: expected %q, got %q", i, c.proto, v)
+		}
+		if v := upstreamReq.Header.Get("X-Forwarded-Host"); v != c.fwdHost {
+			t.Errorf("case %d: X-Forwarded-Host: expected %q, got %q", i, c.fwdHost, v)
+		}
+		if upstreamReq.Host != c.host {
+			t.Errorf("case %d: Host: expected %q, got %q", i, c.host, upstreamReq.Host)
+		}
+	}
+}
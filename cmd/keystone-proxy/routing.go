@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// route forwards requests whose identity matches to a dedicated upstream
+type route struct {
+	attribute string
+	value     string
+	upstream  *url.URL
+	proxy     http.Handler
+}
+
+// parseRoute parses a route given as <attribute>:<value>=<upstream url>.
+// Supported attributes are project-prefix, domain and role.
+func parseRoute(spec string) (*route, error) {
+	matcher, upstream, ok := strings.Cut(spec, "=")
+	if !ok {
+		return nil, fmt.Errorf("Invalid route %q, expected <attribute>:<value>=<upstream>", spec)
+	}
+	attribute, value, ok := strings.Cut(matcher, ":")
+	if !ok || value == "" {
+		return nil, fmt.Errorf("Invalid route %q, expected <attribute>:<value>=<upstream>", spec)
+	}
+	switch attribute {
+	case "project-prefix", "domain", "role":
+	default:
+		return nil, fmt.Errorf("Invalid route %q, unknown attribute %s", spec, attribute)
+	}
+	target, err := url.Parse(upstream)
+	if err != nil || target.Host == "" {
+		return nil, fmt.Errorf("Invalid upstream url in route %q", spec)
+	}
+	return &route{attribute: attribute, value: value, upstream: target}, nil
+}
+
+// matches checks the identity headers set by the keystone middleware against the route
+func (r *route) matches(h http.Header) bool {
+	if h.Get("X-Identity-Status") != "Confirmed" {
+		return false
+	}
+	switch r.attribute {
+	case "project-prefix":
+		project := h.Get("X-Project-Id")
+		return project != "" && strings.HasPrefix(project, r.value)
+	case "domain":
+		for _, header := range []string{"X-Project-Domain-Id", "X-Project-Domain-Name", "X-Domain-Id", "X-Domain-Name"} {
+			if h.Get(header) == r.value {
+				return true
+			}
+		}
+	case "role":
+		for _, role := range strings.Split(h.Get("X-Roles"), ",") {
+			if role == r.value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// router dispatches requests to the first matching route or the default upstream
+type router struct {
+	routes   []*route
+	fallback http.Handler
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	for _, r := range rt.routes {
+		if r.matches(req.Header) {
+			r.proxy.ServeHTTP(w, req)
+			return
+		}
+	}
+	rt.fallback.ServeHTTP(w, req)
+}
+
+// routeFlags collects repeated -route flags
+type routeFlags []*route
+
+func (f *routeFlags) String() string {
+	return fmt.Sprintf("%d routes", len(*f))
+}
+
+func (f *routeFlags) Set(spec string) error {
+	r, err := parseRoute(spec)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, r)
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func namedHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, name)
+	})
+}
+
+func TestRouter(t *testing.T) {
+	rt := &router{fallback: namedHandler("default")}
+	for _, spec := range []string{"project-prefix:p-eu=http://eu", "domain:d-admin=http://admin", "role:auditor=http://audit"} {
+		r, err := parseRoute(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.proxy = namedHandler(r.upstream.Host)
+		rt.routes = append(rt.routes, r)
+	}
+
+	cases := []struct {
+		headers  map[string]string
+		expected string
+	}{
+		{map[string]string{"X-Identity-Status": "Invalid", "X-Project-Id": "p-eu-1"}, "default"},
+		{map[string]string{"X-Identity-Status": "Confirmed", "X-Project-Id": "p-eu-1"}, "eu"},
+		{map[string]string{"X-Identity-Status": "Confirmed", "X-Project-Id": "p-us-1"}, "default"},
+		{map[string]string{"X-Identity-Status": "Confirmed", "X-Domain-Id": "d-admin"}, "admin"},
+		{map[string]string{"X-Identity-Status": "Confirmed", "X-Roles": "member,auditor"}, "audit"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, req)
+		if body := rec.Body.String(); body != c.expected {
+			t.Errorf("%v: expected %s, got %s", c.headers, c.expected, body)
+		}
+	}
+}
+
+func TestParseRoute(t *testing.T) {
+	for _, spec := range []string{"project-prefix=http://eu", "host:foo=http://eu", "role:admin=eu"} {
+		if _, err := parseRoute(spec); err == nil {
+			t.Errorf("Expected error for route %q", spec)
+		}
+	}
+}
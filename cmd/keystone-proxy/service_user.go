@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/databus23/keystone"
+)
+
+// serviceUserConfig describes the service user validating tokens, like the username/password
+// and application credential options of keystonemiddleware
+type serviceUserConfig struct {
+	User          string
+	UserDomain    string
+	Password      string
+	Project       string
+	ProjectDomain string
+
+	ApplicationCredentialID     string
+	ApplicationCredentialSecret string
+}
+
+// configure sets the service credentials of auth. It does nothing if no service user is given.
+func (c serviceUserConfig) configure(auth *keystone.Auth) error {
+	if c.ApplicationCredentialID != "" {
+		if c.User != "" {
+			return fmt.Errorf("Service user and application credential are mutually exclusive")
+		}
+		if c.ApplicationCredentialSecret == "" {
+			return fmt.Errorf("No secret given for application credential %s", c.ApplicationCredentialID)
+		}
+		auth.ServiceCredentials = keystone.ApplicationCredentials{
+			ID:     c.ApplicationCredentialID,
+			Secret: c.ApplicationCredentialSecret,
+		}
+		return nil
+	}
+	if c.User == "" {
+		return nil
+	}
+	if c.Password == "" {
+		return fmt.Errorf("No password given for service user %s", c.User)
+	}
+	auth.ServiceCredentials = keystone.PasswordCredentials{
+		Username:       c.User,
+		UserDomainName: c.UserDomain,
+		Password:       c.Password,
+	}
+	if c.Project != "" {
+		auth.ServiceScope = &keystone.Scope{ProjectName: c.Project, ProjectDomainName: c.ProjectDomain}
+	}
+	return nil
+}
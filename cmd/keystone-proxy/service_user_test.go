@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/databus23/keystone"
+)
+
+func TestServiceUserConfig(t *testing.T) {
+	auth := &keystone.Auth{}
+	if err := (serviceUserConfig{}).configure(auth); err != nil || auth.ServiceCredentials != nil {
+		t.Errorf("Expected no service credentials, got %v, %v", auth.ServiceCredentials, err)
+	}
+	if err := (serviceUserConfig{User: "proxy"}).configure(auth); err == nil {
+		t.Error("Expected service user without password to fail")
+	}
+
+	c := serviceUserConfig{User: "proxy", UserDomain: "Default", Password: "secret", Project: "service", ProjectDomain: "Default"}
+	if err := c.configure(auth); err != nil {
+		t.Fatal(err)
+	}
+	expected := keystone.PasswordCredentials{Username: "proxy", UserDomainName: "Default", Password: "secret"}
+	if auth.ServiceCredentials != expected {
+		t.Errorf("Expected credentials %+v, got %+v", expected, auth.ServiceCredentials)
+	}
+	if s := auth.ServiceScope; s == nil || s.ProjectName != "service" || s.ProjectDomainName != "Default" {
+		t.Errorf("Unexpected scope: %+v", s)
+	}
+}
+
+func TestApplicationCredentialConfig(t *testing.T) {
+	auth := &keystone.Auth{}
+	if err := (serviceUserConfig{ApplicationCredentialID: "ac-1"}).configure(auth); err == nil {
+		t.Error("Expected application credential without secret to fail")
+	}
+	if err := (serviceUserConfig{ApplicationCredentialID: "ac-1", ApplicationCredentialSecret: "s", User: "proxy"}).configure(auth); err == nil {
+		t.Error("Expected service user together with application credential to fail")
+	}
+	if err := (serviceUserConfig{ApplicationCredentialID: "ac-1", ApplicationCredentialSecret: "s"}).configure(auth); err != nil {
+		t.Fatal(err)
+	}
+	expected := keystone.ApplicationCredentials{ID: "ac-1", Secret: "s"}
+	if auth.ServiceCredentials != expected || auth.ServiceScope != nil {
+		t.Errorf("Expected credentials %+v without scope, got %+v, %+v", expected, auth.ServiceCredentials, auth.ServiceScope)
+	}
+}
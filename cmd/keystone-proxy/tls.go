@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto/tls"
+	"log"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newCertManager returns an ACME (e.g. Let's Encrypt) certificate manager obtaining certificates
+// for the given comma separated domains and storing them in cacheDir
+func newCertManager(domains, cacheDir, email string) *autocert.Manager {
+	var hosts []string
+	for _, d := range strings.Split(domains, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			hosts = append(hosts, d)
+		}
+	}
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(hosts...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      email,
+	}
+}
+
+// listenAndServeACME serves handler via https on listen using certificates managed by m.
+// ACME http-01 challenges are answered on httpListen, other plain http requests are redirected to https.
+func listenAndServeACME(listen, httpListen string, m *autocert.Manager, handler http.Handler) error {
+	go func() {
+		log.Fatal(http.ListenAndServe(httpListen, m.HTTPHandler(nil)))
+	}()
+	server := &http.Server{
+		Addr:      listen,
+		Handler:   handler,
+		TLSConfig: &tls.Config{GetCertificate: m.GetCertificate, NextProtos: []string{"h2", "http/1.1", "acme-tls/1"}},
+	}
+	return server.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCertManagerHostPolicy(t *testing.T) {
+	m := newCertManager("auth.example.com, api.example.com,", t.TempDir(), "")
+	for _, host := range []string{"auth.example.com", "api.example.com"} {
+		if err := m.HostPolicy(context.Background(), host); err != nil {
+			t.Errorf("Expected certificates for %s to be allowed: %v", host, err)
+		}
+	}
+	if err := m.HostPolicy(context.Background(), "evil.example.com"); err == nil {
+		t.Error("Expected certificates for other hosts to be rejected")
+	}
+}
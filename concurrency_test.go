@@ -0,0 +1,75 @@
+package keystone
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestConcurrentServers runs a single Auth behind multiple servers under load. Run with -race.
+func TestConcurrentServers(t *testing.T) {
+	var validations atomic.Int32
+	mock := &serviceUserMock{}
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			validations.Add(1)
+		}
+		mock.ServeHTTP(w, r)
+	}))
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.TokenCache = NewInMemoryCache(100)
+	a.ServiceCredentials = PasswordCredentials{UserID: "u-service", Password: "secret"}
+	a.MaxConcurrentValidations = 4
+
+	const servers, workers, requests, tokens = 3, 16, 50, 10
+	var urls []string
+	for i := 0; i < servers; i++ {
+		s := httptest.NewServer(a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Identity-Status") != "Confirmed" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		})))
+		defer s.Close()
+		urls = append(urls, s.URL)
+	}
+
+	var wg sync.WaitGroup
+	var failed atomic.Int32
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < requests; i++ {
+				req, _ := http.NewRequest("GET", urls[(w+i)%servers], nil)
+				req.Header.Set("X-Auth-Token", "token-"+strconv.Itoa((w*requests+i)%tokens))
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil || resp.StatusCode != http.StatusOK {
+					failed.Add(1)
+				}
+				if resp != nil {
+					resp.Body.Close()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if n := failed.Load(); n != 0 {
+		t.Errorf("%d requests failed", n)
+	}
+	if s := a.Stats(); s.Confirmed != workers*requests {
+		t.Errorf("Expected %d confirmed requests, got %d", workers*requests, s.Confirmed)
+	}
+	//concurrent validations of the same token are coalesced and then served from the shared cache
+	if n := validations.Load(); n != tokens {
+		t.Errorf("Expected %d validations against Keystone, got %d", tokens, n)
+	}
+	if mock.issued != 1 {
+		t.Errorf("Expected a single service token to be issued, got %d", mock.issued)
+	}
+}
@@ -0,0 +1,293 @@
+package keystone
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config contains the options of the [keystone_authtoken] section of OpenStack service configuration files
+// understood by the python keystonemiddleware. This allows services migrating from python to reuse their
+// existing configuration:
+//
+//	config, err := keystone.LoadConfig("/etc/nova/nova.conf")
+//	...
+//	auth, err := config.NewAuth()
+//
+// Options without an equivalent in this package are ignored. See the memcache package for memcached_servers.
+type Config struct {
+	//Keystone endpoint for validating tokens (auth_url). /v3 is appended if missing.
+	AuthURL string
+	//Keystone url announced to unauthenticated clients (www_authenticate_uri or the deprecated auth_uri)
+	WWWAuthenticateURI string
+
+	//Credentials of the service user (username, user_id, password, user_domain_name, user_domain_id)
+	Username       string
+	UserID         string
+	Password       string
+	UserDomainName string
+	UserDomainID   string
+	//Project scope of the service user (project_name, project_id, project_domain_name, project_domain_id)
+	ProjectName       string
+	ProjectID         string
+	ProjectDomainName string
+	ProjectDomainID   string
+	//Application credential of the service user, used instead of the password if set
+	//(application_credential_id, application_credential_name, application_credential_secret)
+	ApplicationCredentialID     string
+	ApplicationCredentialName   string
+	ApplicationCredentialSecret string
+
+	//CA bundle for verifying Keystone's certificate (cafile)
+	CAFile string
+	//Client certificate and key (certfile, keyfile)
+	CertFile string
+	KeyFile  string
+	//Don't verify Keystone's certificate (insecure)
+	Insecure bool
+	//Timeout of requests to Keystone (http_connect_timeout)
+	HTTPConnectTimeout time.Duration
+
+	//How long to cache tokens (token_cache_time)
+	TokenCacheTime time.Duration
+	//Pass on the service catalog (include_service_catalog). Unlike keystonemiddleware this defaults to false.
+	IncludeServiceCatalog bool
+
+	//Memcached servers for caching tokens (memcached_servers), see the memcache package
+	MemcachedServers []string
+	//memcache_security_strategy and memcache_secret_key
+	MemcacheSecurityStrategy string
+	MemcacheSecretKey        string
+}
+
+// ConfigSection is the section of configuration files read by LoadConfig
+const ConfigSection = "keystone_authtoken"
+
+// LoadConfig reads the [keystone_authtoken] section of an oslo.config style INI file
+func LoadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := ParseConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// ParseConfig parses the [keystone_authtoken] section of an oslo.config style INI file
+func ParseConfig(r io.Reader) (*Config, error) {
+	options := make(map[string]string)
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case line[0] == '[' && line[len(line)-1] == ']':
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != ConfigSection {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected option = value", n)
+		}
+		options[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return newConfig(func(option string) string { return options[option] })
+}
+
+// ConfigFromEnv returns a configuration based on the OS_* environment variables used by OpenStack clients,
+// e.g. OS_AUTH_URL, OS_USERNAME, OS_PASSWORD, OS_PROJECT_NAME or OS_APPLICATION_CREDENTIAL_ID.
+// Options without a common environment variable can be given as OS_<OPTION>, e.g. OS_TOKEN_CACHE_TIME.
+func ConfigFromEnv() (*Config, error) {
+	return newConfig(func(option string) string {
+		switch option {
+		case "cafile":
+			return os.Getenv("OS_CACERT")
+		case "certfile":
+			return os.Getenv("OS_CERT")
+		case "keyfile":
+			return os.Getenv("OS_KEY")
+		}
+		return os.Getenv("OS_" + strings.ToUpper(option))
+	})
+}
+
+// NewAuthFromEnv creates an Auth configured by the environment, see ConfigFromEnv
+func NewAuthFromEnv() (*Auth, error) {
+	c, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return c.NewAuth()
+}
+
+// newConfig builds a configuration from the values of keystone_authtoken options
+func newConfig(get func(option string) string) (*Config, error) {
+	c := &Config{
+		AuthURL:                     get("auth_url"),
+		WWWAuthenticateURI:          get("www_authenticate_uri"),
+		Username:                    get("username"),
+		UserID:                      get("user_id"),
+		Password:                    get("password"),
+		UserDomainName:              get("user_domain_name"),
+		UserDomainID:                get("user_domain_id"),
+		ProjectName:                 get("project_name"),
+		ProjectID:                   get("project_id"),
+		ProjectDomainName:           get("project_domain_name"),
+		ProjectDomainID:             get("project_domain_id"),
+		ApplicationCredentialID:     get("application_credential_id"),
+		ApplicationCredentialName:   get("application_credential_name"),
+		ApplicationCredentialSecret: get("application_credential_secret"),
+		CAFile:                      get("cafile"),
+		CertFile:                    get("certfile"),
+		KeyFile:                     get("keyfile"),
+		MemcacheSecurityStrategy:    get("memcache_security_strategy"),
+		MemcacheSecretKey:           get("memcache_secret_key"),
+	}
+	if c.WWWAuthenticateURI == "" {
+		c.WWWAuthenticateURI = get("auth_uri")
+	}
+	for _, s := range strings.Split(get("memcached_servers"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			c.MemcachedServers = append(c.MemcachedServers, s)
+		}
+	}
+	var err error
+	if c.Insecure, err = configBool(get, "insecure"); err != nil {
+		return nil, err
+	}
+	if c.IncludeServiceCatalog, err = configBool(get, "include_service_catalog"); err != nil {
+		return nil, err
+	}
+	if c.TokenCacheTime, err = configSeconds(get, "token_cache_time"); err != nil {
+		return nil, err
+	}
+	if c.HTTPConnectTimeout, err = configSeconds(get, "http_connect_timeout"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func configBool(get func(string) string, option string) (bool, error) {
+	//oslo.config accepts the same values
+	switch v := get(option); strings.ToLower(v) {
+	case "", "false", "0", "no", "off":
+		return false, nil
+	case "true", "1", "yes", "on":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid %s: %q", option, v)
+	}
+}
+
+func configSeconds(get func(string) string, option string) (time.Duration, error) {
+	v := get(option)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %q", option, v)
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+// NewAuth creates an Auth configured like keystonemiddleware would be with the configuration
+func (c *Config) NewAuth() (*Auth, error) {
+	if c.AuthURL == "" {
+		return nil, errors.New("auth_url is required")
+	}
+	endpoint := strings.TrimSuffix(c.AuthURL, "/")
+	if !strings.HasSuffix(endpoint, "/v3") {
+		endpoint += "/v3"
+	}
+	a := &Auth{
+		Endpoint:              endpoint,
+		CacheTime:             c.TokenCacheTime,
+		Timeout:               c.HTTPConnectTimeout,
+		IncludeServiceCatalog: c.IncludeServiceCatalog,
+	}
+	if c.WWWAuthenticateURI != "" {
+		a.Challenge = &Challenge{URI: c.WWWAuthenticateURI}
+	}
+
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	a.TLSConfig = tlsConfig
+
+	switch {
+	case c.ApplicationCredentialSecret != "":
+		a.ServiceCredentials = ApplicationCredentials{
+			ID:             c.ApplicationCredentialID,
+			Name:           c.ApplicationCredentialName,
+			UserID:         c.UserID,
+			Username:       c.Username,
+			UserDomainID:   c.UserDomainID,
+			UserDomainName: c.UserDomainName,
+			Secret:         c.ApplicationCredentialSecret,
+		}
+	case c.Password != "":
+		a.ServiceCredentials = PasswordCredentials{
+			UserID:         c.UserID,
+			Username:       c.Username,
+			UserDomainID:   c.UserDomainID,
+			UserDomainName: c.UserDomainName,
+			Password:       c.Password,
+		}
+		if c.ProjectName != "" || c.ProjectID != "" {
+			a.ServiceScope = &Scope{
+				ProjectID:         c.ProjectID,
+				ProjectName:       c.ProjectName,
+				ProjectDomainID:   c.ProjectDomainID,
+				ProjectDomainName: c.ProjectDomainName,
+			}
+		}
+	}
+	a.ensureDefaults()
+	return a, nil
+}
+
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	if c.CAFile == "" && c.CertFile == "" && !c.Insecure {
+		return nil, nil
+	}
+	config := &tls.Config{InsecureSkipVerify: c.Insecure}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
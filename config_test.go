@@ -0,0 +1,104 @@
+package keystone
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const novaConf = `
+[DEFAULT]
+debug = true
+password = not-this-one
+
+[keystone_authtoken]
+# the python middleware's options
+www_authenticate_uri = https://keystone.example.com:5000
+auth_url = https://keystone.example.com:5000/
+auth_type = password
+username = nova
+password = secret = with equals
+user_domain_name = Default
+project_name = service
+project_domain_name = Default
+token_cache_time = 600
+http_connect_timeout = 3
+insecure = True
+include_service_catalog = false
+memcached_servers = inet:mc-1:11211, inet:mc-2:11211
+memcache_security_strategy = ENCRYPT
+memcache_secret_key = key
+
+[oslo_policy]
+enforce_scope = true
+`
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nova.conf")
+	os.WriteFile(path, []byte(novaConf), 0600)
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Password != "secret = with equals" || c.TokenCacheTime != 10*time.Minute || !c.Insecure || len(c.MemcachedServers) != 2 || c.MemcacheSecurityStrategy != "ENCRYPT" {
+		t.Errorf("Unexpected config %+v", c)
+	}
+
+	a, err := c.NewAuth()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Endpoint != "https://keystone.example.com:5000/v3" {
+		t.Errorf("Expected /v3 endpoint, got %s", a.Endpoint)
+	}
+	if a.CacheTime != 10*time.Minute || a.Timeout != 3*time.Second || a.Client.Timeout != 3*time.Second {
+		t.Errorf("Unexpected timeouts %s, %s", a.CacheTime, a.Timeout)
+	}
+	if a.TLSConfig == nil || !a.TLSConfig.InsecureSkipVerify {
+		t.Error("Expected insecure TLS config")
+	}
+	if a.Challenge == nil || a.Challenge.URI != "https://keystone.example.com:5000" {
+		t.Errorf("Expected challenge uri, got %+v", a.Challenge)
+	}
+	creds, ok := a.ServiceCredentials.(PasswordCredentials)
+	if !ok || creds.Username != "nova" || creds.UserDomainName != "Default" || a.ServiceScope == nil || a.ServiceScope.ProjectName != "service" {
+		t.Errorf("Unexpected service credentials %+v, %+v", a.ServiceCredentials, a.ServiceScope)
+	}
+
+	for _, conf := range []string{
+		"[keystone_authtoken]\ntoken_cache_time = soon\n",
+		"[keystone_authtoken]\ninsecure = maybe\n",
+		"[keystone_authtoken]\nauth_url\n",
+	} {
+		if _, err := ParseConfig(strings.NewReader(conf)); err == nil {
+			t.Errorf("Expected error for %q", conf)
+		}
+	}
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.conf")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestNewAuthFromEnv(t *testing.T) {
+	t.Setenv("OS_AUTH_URL", "https://keystone.example.com/identity/v3")
+	t.Setenv("OS_APPLICATION_CREDENTIAL_ID", "ac-1")
+	t.Setenv("OS_APPLICATION_CREDENTIAL_SECRET", "secret")
+	t.Setenv("OS_PASSWORD", "ignored")
+	a, err := NewAuthFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Endpoint != "https://keystone.example.com/identity/v3" || a.CacheTime != 5*time.Minute {
+		t.Errorf("Unexpected endpoint %s or cache time %s", a.Endpoint, a.CacheTime)
+	}
+	if creds, ok := a.ServiceCredentials.(ApplicationCredentials); !ok || creds.ID != "ac-1" || a.ServiceScope != nil {
+		t.Errorf("Expected application credential, got %+v", a.ServiceCredentials)
+	}
+
+	t.Setenv("OS_AUTH_URL", "")
+	if _, err := NewAuthFromEnv(); err == nil {
+		t.Error("Expected error without OS_AUTH_URL")
+	}
+}
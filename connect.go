@@ -0,0 +1,40 @@
+package keystone
+
+import "net/http"
+
+// ConnectPolicy selects how CONNECT requests are handled when the middleware is used in a forward proxy
+type ConnectPolicy int
+
+const (
+	//ConnectRequireToken validates the token of CONNECT requests before the tunnel is established.
+	//Requests without a valid token are rejected with 407 Proxy Authentication Required (503 if Keystone
+	//is unavailable). No identity headers are injected, the token context is only available via
+	//TokenFromContext, and X-Auth-Token and X-Service-Token are removed so they don't leak into the tunnel.
+	//This is the default.
+	ConnectRequireToken ConnectPolicy = iota
+	//ConnectReject rejects CONNECT requests with 405 Method Not Allowed
+	ConnectReject
+	//ConnectPassThrough handles CONNECT requests like any other request
+	ConnectPassThrough
+)
+
+// serveConnect handles a CONNECT request according to Auth.Connect
+func (h *handler) serveConnect(w http.ResponseWriter, req *http.Request) {
+	if h.Connect == ConnectReject {
+		h.fail(w, req, http.StatusMethodNotAllowed, nil)
+		return
+	}
+	token, err := h.authenticate(w, req)
+	h.stats.count(token)
+	req.Header.Del("X-Auth-Token")
+	req.Header.Del("X-Service-Token")
+	if token == nil {
+		code := http.StatusProxyAuthRequired
+		if err != nil && IsUnavailable(err) {
+			code = http.StatusServiceUnavailable
+		}
+		h.fail(w, req, code, err)
+		return
+	}
+	h.handler.ServeHTTP(w, req.WithContext(withToken(req.Context(), token)))
+}
@@ -0,0 +1,55 @@
+package keystone
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConnect(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u-1"}}}`)
+	defer idServer.Close()
+
+	var headers http.Header
+	var token *Token
+	a := New(idServer.URL)
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		token, _ = TokenFromContext(r.Context())
+	}))
+	connect := func(authToken string) int {
+		headers, token = nil, nil
+		req := httptest.NewRequest("CONNECT", "http://upstream.example.com:443", nil)
+		if authToken != "" {
+			req.Header.Set("X-Auth-Token", authToken)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := connect("1234"); code != 200 {
+		t.Fatalf("Expected CONNECT with valid token to be accepted, got %d", code)
+	}
+	if token == nil || token.User.ID != "u-1" {
+		t.Errorf("Expected token context, got %+v", token)
+	}
+	for _, k := range []string{"X-Identity-Status", "X-User-Id", "X-Auth-Token"} {
+		if v := headers.Get(k); v != "" {
+			t.Errorf("Expected no %s header in tunnel request, got %q", k, v)
+		}
+	}
+	if code := connect(""); code != http.StatusProxyAuthRequired {
+		t.Errorf("Expected 407 without token, got %d", code)
+	}
+
+	a.Connect = ConnectReject
+	if code := connect("1234"); code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 with ConnectReject, got %d", code)
+	}
+
+	a.Connect = ConnectPassThrough
+	if code := connect(""); code != 200 || headers.Get("X-Identity-Status") != "Invalid" {
+		t.Errorf("Expected CONNECT to be passed through, got %d %v", code, headers)
+	}
+}
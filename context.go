@@ -0,0 +1,69 @@
+package keystone
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey int
+
+const (
+	tokenKey contextKey = iota
+	requestIDKey
+	serviceTokenKey
+	validationOptionsKey
+	clientIPKey
+	authTokenKey
+)
+
+func withToken(ctx context.Context, t *Token) context.Context {
+	return context.WithValue(ctx, tokenKey, t)
+}
+
+// NewContext returns a context carrying the token context, which can be retrieved with TokenFromContext.
+// This is useful for integrating other transports like gRPC (see the grpc package) with code relying
+// on TokenFromContext.
+func NewContext(ctx context.Context, t *Token) context.Context {
+	return withToken(ctx, t)
+}
+
+// TokenFromContext returns the token context of a request authenticated by Auth.Handler.
+// This gives in-process authorization access to the typed token instead of the X-* headers:
+//
+//	if token, ok := keystone.TokenFromContext(r.Context()); ok && keystone.HasRole("admin")(token) {
+//		...
+//	}
+func TokenFromContext(ctx context.Context) (*Token, bool) {
+	t, ok := ctx.Value(tokenKey).(*Token)
+	return t, ok && t != nil
+}
+
+// withAuthToken stores the token of a request whose X-Auth-Token header was removed, see Auth.RemoveAuthToken
+func withAuthToken(ctx context.Context, authToken string) context.Context {
+	return context.WithValue(ctx, authTokenKey, authToken)
+}
+
+// authTokenFromRequest returns the token of a request authenticated by Auth.Handler
+func authTokenFromRequest(req *http.Request) string {
+	if authToken, ok := req.Context().Value(authTokenKey).(string); ok {
+		return authToken
+	}
+	return req.Header.Get("X-Auth-Token")
+}
+
+// withRequestID stores the OpenStack request id of req (if any) in the context
+func withRequestID(ctx context.Context, req *http.Request) context.Context {
+	id := req.Header.Get("X-Openstack-Request-Id")
+	if id == "" {
+		id = req.Header.Get("X-Request-Id")
+	}
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
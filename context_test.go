@@ -0,0 +1,38 @@
+package keystone
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenFromContext(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z", "user": {"id": "u-1"}, "roles": [{"id": "1", "name": "member"}]}}`)
+	defer idServer.Close()
+
+	var token *Token
+	var found bool
+	h := (&Auth{Endpoint: idServer.URL}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found = TokenFromContext(r.Context())
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "/"))
+	if found {
+		t.Error("Expected no token for unauthenticated request")
+	}
+
+	req := newRequest("GET", "/")
+	req.Header.Set("X-Auth-Token", "1234")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if !found || token.User.ID != "u-1" || token.RoleNames()[0] != "member" {
+		t.Errorf("Unexpected token in context: %v, %+v", found, token)
+	}
+
+	if _, ok := TokenFromContext(withToken(context.Background(), nil)); ok {
+		t.Error("Expected nil token not to be found")
+	}
+	if t2, ok := TokenFromContext(NewContext(context.Background(), token)); !ok || t2 != token {
+		t.Error("Expected token stored with NewContext to be found")
+	}
+}
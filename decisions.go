@@ -0,0 +1,196 @@
+package keystone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Decision describes the authentication outcome of a request
+type Decision struct {
+	Time time.Time
+	//Hex encoded SHA-256 hash of the token, empty if the request had no token
+	TokenHash string
+	UserID    string
+	ProjectID string
+	//One of confirmed, invalid or missing
+	Outcome string
+	//Whether the token context was served from the cache
+	Cached bool
+	//Time spent validating the token
+	Latency time.Duration
+	Method  string
+	Path    string
+}
+
+// DecisionStream asynchronously delivers sampled authentication decisions in batches,
+// e.g. for piping them into Kafka or ClickHouse for security analytics.
+// Decisions are buffered and dropped if the buffer is full, so a slow Handler never blocks requests.
+// Call Close on shutdown to deliver the decisions still buffered.
+type DecisionStream struct {
+	//Called with batches of decisions from a single background goroutine
+	Handler func([]Decision)
+	//Fraction of decisions to deliver between 0 and 1. Defaults to 1 (all decisions) if 0.
+	SampleRate float64
+	//Maximum number of decisions per batch, defaults to 100
+	BatchSize int
+	//Maximum time a decision is held back before its batch is delivered, defaults to 1 second
+	FlushInterval time.Duration
+	//Number of decisions buffered while waiting for Handler, defaults to 1000
+	BufferSize int
+
+	start   sync.Once
+	events  chan Decision
+	dropped atomic.Uint64
+
+	flush     chan chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	//guards closed, held for reading while recording decisions
+	mu     sync.RWMutex
+	closed bool
+}
+
+// Dropped returns the number of decisions dropped because the buffer was full or the stream was closed
+func (s *DecisionStream) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Flush delivers all buffered decisions and returns once Handler returned
+func (s *DecisionStream) Flush() {
+	s.start.Do(s.run)
+	ack := make(chan struct{})
+	select {
+	case s.flush <- ack:
+		<-ack
+	case <-s.done:
+	}
+}
+
+// Close delivers all buffered decisions and stops the background goroutine once Handler returned.
+// Decisions recorded afterwards are dropped.
+func (s *DecisionStream) Close() {
+	s.start.Do(s.run)
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		close(s.stop)
+	})
+	<-s.done
+}
+
+func (s *DecisionStream) record(req *http.Request, authToken string, token *Token, cached bool, latency time.Duration) {
+	if s == nil || s.Handler == nil {
+		return
+	}
+	if s.SampleRate > 0 && s.SampleRate < 1 && rand.Float64() >= s.SampleRate {
+		return
+	}
+	s.start.Do(s.run)
+
+	d := Decision{
+		Time:    time.Now(),
+		Outcome: "missing",
+		Cached:  cached,
+		Latency: latency,
+		Method:  req.Method,
+		Path:    req.URL.Path,
+	}
+	if authToken != "" {
+		d.TokenHash = hashToken(authToken)
+		d.Outcome = "invalid"
+	}
+	if token != nil {
+		d.Outcome = "confirmed"
+		d.UserID = token.User.ID
+		if token.Project != nil {
+			d.ProjectID = token.Project.ID
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return
+	}
+	select {
+	case s.events <- d:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *DecisionStream) run() {
+	size := s.BufferSize
+	if size <= 0 {
+		size = 1000
+	}
+	s.events = make(chan Decision, size)
+	s.flush = make(chan chan struct{})
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.deliver()
+}
+
+func (s *DecisionStream) deliver() {
+	defer close(s.done)
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	interval := s.FlushInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	batch := make([]Decision, 0, batchSize)
+	send := func() {
+		if len(batch) > 0 {
+			s.Handler(batch)
+			batch = make([]Decision, 0, batchSize)
+		}
+	}
+	add := func(d Decision) {
+		batch = append(batch, d)
+		if len(batch) == batchSize {
+			send()
+		}
+	}
+	//drain delivers the decisions buffered when it is called, later ones are left for the next batch
+	drain := func() {
+		for n := len(s.events); n > 0; n-- {
+			add(<-s.events)
+		}
+		send()
+	}
+	for {
+		select {
+		case d := <-s.events:
+			add(d)
+		case <-ticker.C:
+			send()
+		case ack := <-s.flush:
+			drain()
+			close(ack)
+		case <-s.stop:
+			//no decisions are recorded anymore, the buffer is complete
+			drain()
+			return
+		}
+	}
+}
+
+// hashToken returns the hex encoded SHA-256 hash of a token
+func hashToken(authToken string) string {
+	sum := sha256.Sum256([]byte(authToken))
+	return hex.EncodeToString(sum[:])
+}
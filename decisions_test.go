@@ -0,0 +1,68 @@
+package keystone
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDecisionStream(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z", "user": {"id": "u-1"}}}`)
+	defer idServer.Close()
+
+	batches := make(chan []Decision, 1)
+	a := Auth{Endpoint: idServer.URL, Decisions: &DecisionStream{
+		Handler:       func(b []Decision) { batches <- b },
+		BatchSize:     2,
+		FlushInterval: time.Minute,
+	}}
+	h := a.Handler(okHandler)
+
+	h.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "/anonymous"))
+	req := newRequest("GET", "/foo")
+	req.Header.Set("X-Auth-Token", "1234")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case batch := <-batches:
+		if len(batch) != 2 {
+			t.Fatalf("Expected batch of 2 decisions, got %d", len(batch))
+		}
+		if batch[0].Outcome != "missing" || batch[0].TokenHash != "" {
+			t.Errorf("Unexpected decision for request without token: %+v", batch[0])
+		}
+		if d := batch[1]; d.Outcome != "confirmed" || d.UserID != "u-1" || d.TokenHash != hashToken("1234") || d.Path != "/foo" {
+			t.Errorf("Unexpected decision for authenticated request: %+v", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No batch delivered")
+	}
+}
+
+func TestDecisionStreamClose(t *testing.T) {
+	var delivered []Decision
+	s := &DecisionStream{
+		Handler:       func(b []Decision) { delivered = append(delivered, b...) },
+		BatchSize:     10,
+		FlushInterval: time.Minute,
+	}
+	req := newRequest("GET", "/")
+	s.record(req, "", nil, false, 0)
+	s.Flush()
+	if len(delivered) != 1 {
+		t.Fatalf("Expected flush to deliver 1 decision, got %d", len(delivered))
+	}
+
+	s.record(req, "", nil, false, 0)
+	s.record(req, "", nil, false, 0)
+	s.Close()
+	if len(delivered) != 3 {
+		t.Errorf("Expected close to deliver the partial batch, got %d decisions", len(delivered))
+	}
+	s.record(req, "", nil, false, 0)
+	s.Flush()
+	s.Close()
+	if len(delivered) != 3 || s.Dropped() != 1 {
+		t.Errorf("Expected decisions recorded after close to be dropped, got %d decisions, %d dropped", len(delivered), s.Dropped())
+	}
+}
@@ -0,0 +1,137 @@
+package keystone
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DecodeError is returned for token payloads which can't be decoded, e.g. because a proxy or a custom
+// Keystone implementation returns a timestamp in another format or a field with an unexpected type.
+type DecodeError struct {
+	//Path of the offending field in the response, e.g. "token.expires_at" or "token.roles.1.name".
+	//Empty if the field couldn't be determined or the response isn't valid JSON.
+	Path string
+	//Offending value as given in the response, truncated to 64 bytes
+	Value string
+	//Offset of a JSON syntax error in the response
+	Offset int64
+	//Underlying error of the json or time package
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	var syntax *json.SyntaxError
+	switch {
+	case errors.As(e.Err, &syntax):
+		return fmt.Sprintf("Invalid token payload at offset %d near %s: %v", e.Offset, e.Value, e.Err)
+	case e.Path != "":
+		return fmt.Sprintf("Invalid token payload at %s (%s): %v", e.Path, e.Value, e.Err)
+	}
+	return fmt.Sprintf("Invalid token payload: %v", e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// maxDecodeErrorValue limits the size of DecodeError.Value
+const maxDecodeErrorValue = 64
+
+// newDecodeError annotates an error decoding doc with the location of the offending value
+func newDecodeError(err error, doc []byte) *DecodeError {
+	e := &DecodeError{Err: err}
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	var parse *time.ParseError
+	switch {
+	case errors.As(err, &syntax):
+		e.Offset = syntax.Offset
+		start, end := max(0, int(syntax.Offset)-maxDecodeErrorValue/2), min(len(doc), int(syntax.Offset)+maxDecodeErrorValue/2)
+		e.Value = strconv.Quote(string(doc[start:end]))
+	case errors.As(err, &typ):
+		//Field is relative to the type implementing json.Unmarshaler (e.g. Project), so search the document
+		//for a matching path holding a value of the offending type
+		e.Path, e.Value = findValue(doc, func(path string, v interface{}) bool {
+			return typ.Field != "" && (path == typ.Field || strings.HasSuffix(path, "."+typ.Field)) &&
+				strings.HasPrefix(typ.Value, jsonType(v))
+		})
+	case errors.As(err, &parse):
+		e.Path, e.Value = findValue(doc, func(_ string, v interface{}) bool {
+			s, ok := v.(string)
+			return ok && s == parse.Value
+		})
+	}
+	if len(e.Value) > maxDecodeErrorValue {
+		e.Value = e.Value[:maxDecodeErrorValue] + "..."
+	}
+	return e
+}
+
+// findValue returns the path and JSON encoding of the first value in doc satisfying match
+func findValue(doc []byte, match func(path string, v interface{}) bool) (string, string) {
+	var root interface{}
+	if json.Unmarshal(doc, &root) != nil {
+		return "", ""
+	}
+	var walk func(path string, v interface{}) (string, interface{}, bool)
+	walk = func(path string, v interface{}) (string, interface{}, bool) {
+		if match(path, v) {
+			return path, v, true
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if p, found, ok := walk(joinPath(path, k), v[k]); ok {
+					return p, found, true
+				}
+			}
+		case []interface{}:
+			for i, elem := range v {
+				if p, found, ok := walk(joinPath(path, strconv.Itoa(i)), elem); ok {
+					return p, found, true
+				}
+			}
+		}
+		return "", nil, false
+	}
+	path, v, ok := walk("", root)
+	if !ok {
+		return "", ""
+	}
+	value, _ := json.Marshal(v)
+	return path, string(value)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// jsonType returns the name of the JSON type of a decoded value as used by json.UnmarshalTypeError
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	}
+	return "null"
+}
@@ -0,0 +1,93 @@
+package keystone
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeError(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		path   string
+		value  string
+		offset int64
+	}{
+		{
+			name:  "timestamp",
+			body:  `{"token": {"expires_at": "2120-10-08 08:40:33", "user": {"id": "u"}}}`,
+			path:  "token.expires_at",
+			value: `"2120-10-08 08:40:33"`,
+		},
+		{
+			name:  "user",
+			body:  `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u", "name": 42}}}`,
+			path:  "token.user.name",
+			value: "42",
+		},
+		{
+			name:  "nested",
+			body:  `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u", "domain": {"id": "d"}}, "project": {"id": "p", "domain": {"id": 7}}}}`,
+			path:  "token.project.domain.id",
+			value: "7",
+		},
+		{
+			name:  "array",
+			body:  `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u"}, "roles": [{"name": "a"}, {"name": true}]}}`,
+			path:  "token.roles.1.name",
+			value: "true",
+		},
+		{
+			name:   "syntax",
+			body:   `{"token": {"expires_at": "2120-10-08T08:40:33.100Z",}}`,
+			offset: 53,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			idServer := identityMock(200, c.body)
+			defer idServer.Close()
+
+			_, err := New(idServer.URL).Validate("token")
+			var derr *DecodeError
+			if !errors.As(err, &derr) {
+				t.Fatalf("Expected a DecodeError, got %v", err)
+			}
+			if derr.Path != c.path {
+				t.Errorf("Expected path %q, got %q", c.path, derr.Path)
+			}
+			if c.value != "" && derr.Value != c.value {
+				t.Errorf("Expected value %s, got %s", c.value, derr.Value)
+			}
+			if derr.Offset != c.offset {
+				t.Errorf("Expected offset %d, got %d", c.offset, derr.Offset)
+			}
+			if c.path != "" && !strings.Contains(err.Error(), c.path) {
+				t.Errorf("Expected path in error message, got %q", err)
+			}
+		})
+	}
+}
+
+func TestDecodeErrorUnwrap(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "yesterday", "user": {"id": "u"}}}`)
+	defer idServer.Close()
+
+	_, err := New(idServer.URL).Validate("token")
+	var parse *time.ParseError
+	if !errors.As(err, &parse) {
+		t.Errorf("Expected a time.ParseError, got %v", err)
+	}
+
+	idServer = identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u", "enabled": "yes"}}}`)
+	defer idServer.Close()
+
+	_, err = New(idServer.URL).Validate("token")
+	var typ *json.UnmarshalTypeError
+	if !errors.As(err, &typ) {
+		t.Errorf("Expected a json.UnmarshalTypeError, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package keystone
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrDisabled is returned for tokens whose user, project or domain is disabled if Auth.RejectDisabled is set
+var ErrDisabled = errors.New("disabled")
+
+// checkEnabled returns an error wrapping ErrDisabled if the user or the scope of the token are disabled
+func checkEnabled(t *Token) error {
+	if !t.User.Enabled {
+		return fmt.Errorf("User %s is %w", t.User.ID, ErrDisabled)
+	}
+	if !t.User.Domain.Enabled {
+		return fmt.Errorf("Domain %s of user %s is %w", t.User.Domain.ID, t.User.ID, ErrDisabled)
+	}
+	if p := t.Project; p != nil {
+		if !p.Enabled {
+			return fmt.Errorf("Project %s is %w", p.ID, ErrDisabled)
+		}
+		if !p.Domain.Enabled {
+			return fmt.Errorf("Domain %s is %w", p.Domain.ID, ErrDisabled)
+		}
+	}
+	if d := t.Domain; d != nil && !d.Enabled {
+		return fmt.Errorf("Domain %s is %w", d.ID, ErrDisabled)
+	}
+	return nil
+}
+
+// The enabled flags are optional in token payloads. Entities are considered enabled unless explicitly disabled.
+
+// UnmarshalJSON decodes a token, defaulting the enabled flags of the user and its domain to true
+func (t *Token) UnmarshalJSON(b []byte) error {
+	type token Token
+	v := token{}
+	v.User.Enabled = true
+	v.User.Domain.Enabled = true
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*t = Token(v)
+	return nil
+}
+
+// UnmarshalJSON decodes a project, defaulting the enabled flags to true
+func (p *Project) UnmarshalJSON(b []byte) error {
+	type project Project
+	v := project{Enabled: true, Domain: Domain{Enabled: true}}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*p = Project(v)
+	return nil
+}
+
+// UnmarshalJSON decodes a domain, defaulting the enabled flag to true
+func (d *Domain) UnmarshalJSON(b []byte) error {
+	type domain Domain
+	v := domain{Enabled: true}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*d = Domain(v)
+	return nil
+}
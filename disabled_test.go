@@ -0,0 +1,42 @@
+package keystone
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRejectDisabled(t *testing.T) {
+	cases := []struct {
+		user, userDomain, project, domain string
+		valid                             bool
+	}{
+		{``, ``, ``, ``, true},
+		{`"enabled": true,`, `"enabled": true,`, `"enabled": true,`, `"enabled": true,`, true},
+		{`"enabled": false,`, ``, ``, ``, false},
+		{``, `"enabled": false,`, ``, ``, false},
+		{``, ``, `"enabled": false,`, ``, false},
+		{``, ``, ``, `"enabled": false,`, false},
+	}
+	for _, c := range cases {
+		idServer := identityMock(200, fmt.Sprintf(`
+{
+  "token": {
+    "expires_at": "2120-10-09T15:09:12.355Z",
+    "issued_at": "2015-10-08T15:09:12.355Z",
+    "user": {%s "id": "u-1", "domain": {%s "id": "ud-1"}},
+    "project": {%s "id": "p-1", "domain": {%s "id": "d-1"}}
+  }
+}`, c.user, c.userDomain, c.project, c.domain))
+		a := Auth{Endpoint: idServer.URL, RejectDisabled: true}
+		a.Handler(okHandler)
+		_, err := a.Validate("1234")
+		if c.valid && err != nil {
+			t.Errorf("%+v: expected token to be valid, got %v", c, err)
+		}
+		if !c.valid && !errors.Is(err, ErrDisabled) {
+			t.Errorf("%+v: expected %v, got %v", c, ErrDisabled, err)
+		}
+		idServer.Close()
+	}
+}
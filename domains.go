@@ -0,0 +1,44 @@
+package keystone
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDomainNotAllowed is returned for tokens rejected by Auth.AllowedDomains or Auth.DeniedDomains
+var ErrDomainNotAllowed = errors.New("Domain not allowed")
+
+// checkDomains returns an error wrapping ErrDomainNotAllowed if the domain of the user or the scope
+// of the token isn't allowed
+func (a *Auth) checkDomains(t *Token) error {
+	domains := []Domain{{ID: t.User.Domain.ID, Name: t.User.Domain.Name}}
+	if p := t.Project; p != nil {
+		domains = append(domains, p.Domain)
+	}
+	if d := t.Domain; d != nil {
+		domains = append(domains, *d)
+	}
+	for _, d := range domains {
+		if len(a.AllowedDomains) > 0 && !matchDomain(d, a.AllowedDomains) || matchDomain(d, a.DeniedDomains) {
+			return fmt.Errorf("%w: %s", ErrDomainNotAllowed, domainName(d))
+		}
+	}
+	return nil
+}
+
+// matchDomain returns if the id or name of the domain is in list
+func matchDomain(d Domain, list []string) bool {
+	for _, s := range list {
+		if s != "" && (s == d.ID || s == d.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func domainName(d Domain) string {
+	if d.Name != "" {
+		return d.Name
+	}
+	return d.ID
+}
@@ -0,0 +1,44 @@
+package keystone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDomains(t *testing.T) {
+	idServer := identityMock(200, `
+{
+  "token": {
+    "expires_at": "2120-10-09T15:09:12.355Z",
+    "issued_at": "2015-10-08T15:09:12.355Z",
+    "user": {"id": "u-1", "domain": {"id": "d-staff", "name": "staff"}},
+    "project": {"id": "p-1", "domain": {"id": "d-customers", "name": "customers"}}
+  }
+}`)
+	defer idServer.Close()
+
+	cases := []struct {
+		allowed, denied []string
+		valid           bool
+	}{
+		{nil, nil, true},
+		{[]string{"staff", "d-customers"}, nil, true},
+		{[]string{"staff"}, nil, false},
+		{[]string{"d-customers"}, nil, false},
+		{nil, []string{"customers"}, false},
+		{nil, []string{"d-staff"}, false},
+		{nil, []string{"d-other"}, true},
+		{[]string{"staff", "customers"}, []string{"staff"}, false},
+	}
+	for _, c := range cases {
+		a := Auth{Endpoint: idServer.URL, AllowedDomains: c.allowed, DeniedDomains: c.denied}
+		a.Handler(okHandler)
+		_, err := a.Validate("1234")
+		if c.valid && err != nil {
+			t.Errorf("%+v: expected token to be valid, got %v", c, err)
+		}
+		if !c.valid && !errors.Is(err, ErrDomainNotAllowed) {
+			t.Errorf("%+v: expected %v, got %v", c, ErrDomainNotAllowed, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package keystone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// Error is returned when Keystone answers a request with an error.
+// It carries the HTTP status and the error details from Keystone's response body if present.
+//
+//	var kerr *keystone.Error
+//	if errors.As(err, &kerr) && kerr.StatusCode == http.StatusNotFound {
+//		//token is unknown or expired
+//	}
+type Error struct {
+	//HTTP status code of the response
+	StatusCode int
+	//HTTP status of the response, e.g. "404 Not Found"
+	Status string
+	//Error title as returned by Keystone
+	Title string
+	//Error message as returned by Keystone
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s : %s", e.Status, e.Message)
+	}
+	return e.Status
+}
+
+// IsUnavailable reports whether err indicates that Keystone couldn't be reached or failed to
+// process the validation request (network errors, 5xx responses, timeouts, throttling, an open circuit breaker, load shedding) as opposed to
+// Keystone rejecting the token.
+func IsUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var kerr *Error
+	if errors.As(err, &kerr) {
+		return kerr.StatusCode >= 500
+	}
+	var uerr *url.Error
+	return errors.Is(err, ErrThrottled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrOverloaded) || errors.As(err, &uerr)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/databus23/keystone"
+	"github.com/databus23/keystone/policy"
+)
+
+// newGateway returns the handler of the gateway. Requests to /v1/<project_id>/... are authorized using
+// the gateway:read (GET, HEAD and OPTIONS) or gateway:write rule of the policy and forwarded to the upstream.
+// The handler must be placed behind the handler returned by auth.Handler.
+func newGateway(p *policy.Policy, upstream *url.URL) http.Handler {
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	target := func(r *http.Request) map[string]string {
+		return map[string]string{"project_id": r.PathValue("project_id")}
+	}
+	read := p.Require(proxy, "gateway:read", target)
+	write := p.Require(proxy, "gateway:write", target)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+	mux.HandleFunc("/v1/{project_id}/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			read.ServeHTTP(w, r)
+		default:
+			write.ServeHTTP(w, r)
+		}
+	})
+	return mux
+}
+
+// configure applies the gateway's defaults to auth
+func configure(auth *keystone.Auth, cacheSize int) {
+	//reject requests without a valid token before they reach the gateway, health checks excepted
+	auth.RejectUnauthenticated = true
+	auth.Routes = []keystone.Route{{Pattern: "/healthz", Access: keystone.Anonymous}}
+	auth.TokenCache = keystone.NewInMemoryCache(cacheSize)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/databus23/keystone"
+	"github.com/databus23/keystone/policy"
+)
+
+func TestGateway(t *testing.T) {
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role := r.Header.Get("X-Subject-Token")
+		if role == "invalid" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u-1"}, "project": {"id": "p-1"}, "roles": [{"name": %q}]}}`, role)
+	}))
+	defer idServer.Close()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream-Project", r.Header.Get("X-Project-Id"))
+	}))
+	defer upstream.Close()
+
+	p, err := policy.Parse(defaultPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, _ := url.Parse(upstream.URL)
+	auth := keystone.New(idServer.URL)
+	configure(auth, 10)
+	h := auth.Handler(newGateway(p, target))
+
+	for _, tc := range []struct {
+		method, path, token string
+		status              int
+	}{
+		{"GET", "/healthz", "", 200},
+		{"GET", "/v1/p-1/servers", "", 401},
+		{"GET", "/v1/p-1/servers", "invalid", 401},
+		{"GET", "/v1/p-1/servers", "reader", 200},
+		{"POST", "/v1/p-1/servers", "reader", 403},
+		{"POST", "/v1/p-1/servers", "member", 200},
+		{"GET", "/v1/p-2/servers", "member", 403},
+		{"DELETE", "/v1/p-2/servers/1", "admin", 200},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.token != "" {
+			req.Header.Set("X-Auth-Token", tc.token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("Expected status %d for %s %s with token %q, got %d", tc.status, tc.method, tc.path, tc.token, rec.Code)
+		}
+		if rec.Code == 200 && tc.token != "" && rec.Header().Get("X-Upstream-Project") != "p-1" {
+			t.Errorf("Expected request to be forwarded with identity headers, got %v", rec.Header())
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Command gateway is an example API gateway built with https://github.com/databus23/keystone.
+// It is meant as a template to fork and as documentation of how the packages fit together:
+//
+//   - tokens are validated by the middleware in reject mode and cached in memory
+//   - requests are authorized with an oslo.policy file (see policy.yaml) and forwarded to an upstream
+//   - prometheus metrics and a Grafana dashboard are served on a separate address
+//
+// Keystone is configured using the [keystone_authtoken] section of a configuration file or, if -config isn't
+// given, the OS_* environment variables. To run it against DevStack:
+//
+//	source devstack/openrc admin admin
+//	go run ./examples/gateway -upstream http://localhost:8080
+//	curl -H "X-Auth-Token: $(openstack token issue -f value -c id)" \
+//		http://localhost:3000/v1/$(openstack token issue -f value -c project_id)/servers
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/databus23/keystone"
+	"github.com/databus23/keystone/metrics/prometheus"
+	"github.com/databus23/keystone/policy"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+//go:embed policy.yaml
+var defaultPolicy []byte
+
+func main() {
+	listen := flag.String("listen", "0.0.0.0:3000", "Address to listen on")
+	metricsListen := flag.String("metrics-listen", "127.0.0.1:9090", "Address serving /metrics and the Grafana dashboard at /dashboard.json")
+	upstream := flag.String("upstream", "", "Upstream url authorized requests are forwarded to")
+	config := flag.String("config", "", "Configuration file with a [keystone_authtoken] section, defaults to the OS_* environment variables")
+	policyFile := flag.String("policy", "", "Policy file, defaults to the built-in policy.yaml")
+	cacheSize := flag.Int("cache-size", 10000, "Maximum number of cached tokens")
+	flag.Parse()
+
+	if *upstream == "" {
+		log.Fatal("-upstream is required")
+	}
+	target, err := url.Parse(*upstream)
+	if err != nil {
+		log.Fatalf("Invalid upstream url: %s", err)
+	}
+
+	var auth *keystone.Auth
+	if *config != "" {
+		var c *keystone.Config
+		if c, err = keystone.LoadConfig(*config); err == nil {
+			auth, err = c.NewAuth()
+		}
+	} else {
+		auth, err = keystone.NewAuthFromEnv()
+	}
+	if err != nil {
+		log.Fatalf("Failed to configure keystone: %s", err)
+	}
+	configure(auth, *cacheSize)
+	auth.Metrics = prometheus.New(prom.DefaultRegisterer)
+
+	var p *policy.Policy
+	if *policyFile != "" {
+		p, err = policy.Load(*policyFile)
+	} else {
+		p, err = policy.Parse(defaultPolicy)
+	}
+	if err != nil {
+		log.Fatalf("Failed to load policy: %s", err)
+	}
+
+	metrics := http.NewServeMux()
+	metrics.Handle("/metrics", promhttp.Handler())
+	metrics.Handle("/dashboard.json", prometheus.DashboardHandler())
+	go func() {
+		log.Fatal(http.ListenAndServe(*metricsListen, metrics))
+	}()
+
+	log.Printf("Listening on %s, forwarding to %s, validating tokens against %s", *listen, target, auth.Endpoint)
+	log.Fatal(http.ListenAndServe(*listen, auth.Handler(newGateway(p, target))))
+}
@@ -0,0 +1,66 @@
+package keystone
+
+import (
+	"net/http"
+	"strings"
+)
+
+// TokenExtractor returns the token carried by a request, or "" if the request doesn't carry one
+// where the extractor looks for it. See Auth.TokenExtractors.
+type TokenExtractor func(req *http.Request) string
+
+// HeaderToken extracts the token from a request header, e.g. X-Auth-Token
+func HeaderToken(name string) TokenExtractor {
+	return func(req *http.Request) string {
+		return req.Header.Get(name)
+	}
+}
+
+// BearerToken extracts the token from an Authorization: Bearer <token> header
+func BearerToken() TokenExtractor {
+	return func(req *http.Request) string {
+		scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return ""
+		}
+		return strings.TrimSpace(token)
+	}
+}
+
+// CookieToken extracts the token from a cookie, e.g. the session cookie of a browser based client
+func CookieToken(name string) TokenExtractor {
+	return func(req *http.Request) string {
+		c, err := req.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return c.Value
+	}
+}
+
+// QueryToken extracts the token from a query parameter, e.g. for WebSocket clients which can't set headers.
+// Tokens in urls easily end up in logs, so prefer the other extractors.
+func QueryToken(param string) TokenExtractor {
+	return func(req *http.Request) string {
+		return req.URL.Query().Get(param)
+	}
+}
+
+// extractToken sets X-Auth-Token to the token found by the first matching Auth.TokenExtractors, so all
+// subsequent handling of the request is independent of where the client put the token
+func (a *Auth) extractToken(req *http.Request) {
+	if len(a.TokenExtractors) == 0 {
+		return
+	}
+	//the extractors see the request as sent, HeaderToken("X-Auth-Token") is a valid extractor
+	token := ""
+	for _, extract := range a.TokenExtractors {
+		if token = extract(req); token != "" {
+			break
+		}
+	}
+	req.Header.Del("X-Auth-Token")
+	if token != "" {
+		req.Header.Set("X-Auth-Token", token)
+	}
+}
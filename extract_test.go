@@ -0,0 +1,97 @@
+package keystone
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenExtractors(t *testing.T) {
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Subject-Token") != "1234" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(validTokenBody))
+	}))
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.TokenExtractors = []TokenExtractor{BearerToken(), CookieToken("session"), QueryToken("token")}
+	var status, token string
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, token = r.Header.Get("X-Identity-Status"), r.Header.Get("X-Auth-Token")
+	}))
+
+	for _, c := range []struct {
+		name    string
+		prepare func(r *http.Request)
+		status  string
+	}{
+		{"bearer", func(r *http.Request) { r.Header.Set("Authorization", "Bearer 1234") }, "Confirmed"},
+		{"lowercase bearer", func(r *http.Request) { r.Header.Set("Authorization", "bearer 1234") }, "Confirmed"},
+		{"basic", func(r *http.Request) { r.Header.Set("Authorization", "Basic 1234") }, "Invalid"},
+		{"cookie", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "session", Value: "1234"}) }, "Confirmed"},
+		{"query", func(r *http.Request) { r.URL.RawQuery = "token=1234" }, "Confirmed"},
+		//X-Auth-Token isn't one of the configured extractors
+		{"header", func(r *http.Request) { r.Header.Set("X-Auth-Token", "1234") }, "Invalid"},
+		//the first matching extractor wins
+		{"order", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer 1234")
+			r.URL.RawQuery = "token=5678"
+		}, "Confirmed"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		c.prepare(req)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if status != c.status {
+			t.Errorf("%s: expected status %s, got %s", c.name, c.status, status)
+		}
+		if c.status == "Confirmed" && token != "1234" {
+			t.Errorf("%s: expected token to be passed on in X-Auth-Token, got %q", c.name, token)
+		}
+	}
+}
+
+func TestDocumentedTokenExtractors(t *testing.T) {
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Subject-Token") != "1234" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(validTokenBody))
+	}))
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.TokenExtractors = []TokenExtractor{HeaderToken("X-Auth-Token"), BearerToken(), CookieToken("session")}
+	var status, token string
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, token = r.Header.Get("X-Identity-Status"), r.Header.Get("X-Auth-Token")
+	}))
+
+	for _, c := range []struct {
+		name    string
+		prepare func(r *http.Request)
+		status  string
+	}{
+		{"header", func(r *http.Request) { r.Header.Set("X-Auth-Token", "1234") }, "Confirmed"},
+		{"bearer", func(r *http.Request) { r.Header.Set("Authorization", "Bearer 1234") }, "Confirmed"},
+		{"cookie", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "session", Value: "1234"}) }, "Confirmed"},
+		//X-Auth-Token comes first
+		{"order", func(r *http.Request) {
+			r.Header.Set("X-Auth-Token", "5678")
+			r.Header.Set("Authorization", "Bearer 1234")
+		}, "Invalid"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		c.prepare(req)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if status != c.status {
+			t.Errorf("%s: expected status %s, got %s", c.name, c.status, status)
+		}
+		if c.status == "Confirmed" && token != "1234" {
+			t.Errorf("%s: expected token to be passed on in X-Auth-Token, got %q", c.name, token)
+		}
+	}
+}
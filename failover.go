@@ -0,0 +1,105 @@
+package keystone
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// nodeDownTime is how long a Keystone node is avoided after it failed
+const nodeDownTime = 10 * time.Second
+
+// nodePool tracks the health of the nodes given by Auth.Endpoint and Auth.Endpoints
+type nodePool struct {
+	next atomic.Uint32
+	mu   sync.Mutex
+	down map[string]time.Time
+	//results of the last probe of each node, see Auth.ProbeEndpoints
+	probes        map[string]EndpointStatus
+	probeInterval atomic.Int64
+}
+
+// nodes returns the nodes to try for a request to endpoint in order. Requests are distributed round-robin
+// over the healthy nodes, nodes which failed recently are only tried as a last resort. If the nodes are
+// probed, the remaining healthy nodes are tried in order of their probed latency.
+func (a *Auth) nodes(endpoint string) []string {
+	if endpoint != a.Endpoint || len(a.Endpoints) == 0 {
+		return []string{endpoint}
+	}
+	all := append([]string{a.Endpoint}, a.Endpoints...)
+	start := int(a.pool.next.Add(1)-1) % len(all)
+
+	a.pool.mu.Lock()
+	defer a.pool.mu.Unlock()
+	healthy := make([]string, 0, len(all))
+	var unhealthy []string
+	for i := range all {
+		node := all[(start+i)%len(all)]
+		if time.Now().Before(a.pool.down[node]) {
+			unhealthy = append(unhealthy, node)
+		} else {
+			healthy = append(healthy, node)
+		}
+	}
+	if len(healthy) > 2 && len(a.pool.probes) > 0 {
+		rest := healthy[1:]
+		sort.SliceStable(rest, func(i, j int) bool { return a.pool.rank(rest[i]) < a.pool.rank(rest[j]) })
+	}
+	return append(healthy, unhealthy...)
+}
+
+// rank returns the probed latency of a node, unprobed nodes rank last
+func (p *nodePool) rank(node string) time.Duration {
+	if s, ok := p.probes[node]; ok && s.Healthy {
+		return s.Latency
+	}
+	return math.MaxInt64
+}
+
+// markDown avoids node for d
+func (p *nodePool) markDown(node string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.down == nil {
+		p.down = map[string]time.Time{}
+	}
+	p.down[node] = time.Now().Add(d)
+}
+
+func (p *nodePool) markUp(node string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.down, node)
+}
+
+// requestFailover validates a token against the nodes of endpoint, moving on to the next node
+// if a node can't be reached or answers with a 5xx response
+func (a *Auth) requestFailover(ctx context.Context, endpoint, authToken string) (*Token, error) {
+	return a.requestNodes(ctx, endpoint, a.nodes(endpoint), authToken)
+}
+
+// requestNodes is requestFailover trying the given nodes of endpoint in order
+func (a *Auth) requestNodes(ctx context.Context, endpoint string, nodes []string, authToken string) (*Token, error) {
+	if len(nodes) == 1 {
+		return a.requestToken(ctx, endpoint, authToken)
+	}
+	var err error
+	for _, node := range nodes {
+		var token *Token
+		token, err = a.requestToken(ctx, node, authToken)
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if !retryable(err) {
+			a.pool.markUp(node)
+			return token, err
+		}
+		a.pool.markDown(node, nodeDownTime)
+		a.log(ctx, slog.LevelWarn, "Keystone node unavailable, failing over", "node", node, "error", err)
+	}
+	return nil, err
+}
@@ -0,0 +1,57 @@
+package keystone
+
+import (
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEndpointsRoundRobin(t *testing.T) {
+	var first, second atomic.Int32
+	node1 := flakyMock(&first, 200)
+	defer node1.Close()
+	node2 := flakyMock(&second, 200)
+	defer node2.Close()
+
+	a := New(node1.URL)
+	a.Endpoints = []string{node2.URL}
+	for i := 0; i < 4; i++ {
+		token, err := a.Validate("1234")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.Issuer != node1.URL {
+			t.Errorf("Expected issuer to be %s, got %s", node1.URL, token.Issuer)
+		}
+	}
+	if first.Load() != 2 || second.Load() != 2 {
+		t.Errorf("Expected requests to be distributed evenly, got %d and %d", first.Load(), second.Load())
+	}
+}
+
+func TestEndpointsFailover(t *testing.T) {
+	down := httptest.NewServer(nil)
+	down.Close()
+	var failing, healthy atomic.Int32
+	node2 := flakyMock(&failing, 503)
+	defer node2.Close()
+	node3 := flakyMock(&healthy, 200)
+	defer node3.Close()
+
+	a := New(down.URL)
+	a.Endpoints = []string{node2.URL, node3.URL}
+	for i := 0; i < 3; i++ {
+		if _, err := a.Validate("1234"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := failing.Load(); n != 1 {
+		t.Errorf("Expected failed node to be avoided, got %d requests", n)
+	}
+	if n := healthy.Load(); n != 3 {
+		t.Errorf("Expected 3 requests to the healthy node, got %d", n)
+	}
+	if nodes := a.nodes(a.Endpoint); nodes[0] != node3.URL {
+		t.Errorf("Expected healthy node to be tried first, got %v", nodes)
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/databus23/keystone/fallback/htpasswd
+
+go 1.25.0
+
+require (
+	github.com/databus23/keystone v0.1.0
+	golang.org/x/crypto v0.54.0
+)
@@ -0,0 +1,103 @@
+// Package htpasswd provides a break-glass basic auth fallback for https://github.com/databus23/keystone
+//
+// While Keystone is unavailable requests carrying basic auth credentials found in an htpasswd file
+// are authenticated with a configured synthetic identity. The fallback is only consulted after
+// validating the request's X-Auth-Token failed, so clients need to send both headers:
+//
+//	file, err := htpasswd.Load("/etc/keystone-proxy/htpasswd")
+//	...
+//	auth.Fallback = file.Fallback(map[string]*keystone.Token{"ops": opsIdentity})
+//
+// Only bcrypt ($2y$) and SHA1 ({SHA}) hashes are supported.
+package htpasswd
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/databus23/keystone"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// File contains the users of an htpasswd file
+type File struct {
+	users map[string]string
+}
+
+// Load reads an htpasswd file
+func Load(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads htpasswd entries from r. Entries using unsupported hash algorithms are rejected.
+func Parse(r io.Reader) (*File, error) {
+	users := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid htpasswd entry on line %d", n)
+		}
+		if !supported(parts[1]) {
+			return nil, fmt.Errorf("Unsupported hash for user %s on line %d", parts[0], n)
+		}
+		users[parts[0]] = parts[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &File{users: users}, nil
+}
+
+func supported(hash string) bool {
+	return strings.HasPrefix(hash, "{SHA}") || strings.HasPrefix(hash, "$2a$") ||
+		strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// Verify checks the password of a user
+func (f *File) Verify(user, password string) bool {
+	hash, ok := f.users[user]
+	if !ok {
+		return false
+	}
+	if strings.HasPrefix(hash, "{SHA}") {
+		sum := sha1.Sum([]byte(password))
+		expected := "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(expected)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// Fallback returns a function suitable for keystone.Auth.Fallback.
+// Requests with valid basic auth credentials are authenticated with the identity configured for the user.
+// Users without a configured identity are rejected.
+func (f *File) Fallback(identities map[string]*keystone.Token) func(*http.Request) *keystone.Token {
+	return func(req *http.Request) *keystone.Token {
+		user, password, ok := req.BasicAuth()
+		if !ok {
+			return nil
+		}
+		identity, found := identities[user]
+		if !found || !f.Verify(user, password) {
+			keystone.Log("Fallback authentication failed for user %s", user)
+			return nil
+		}
+		return identity
+	}
+}
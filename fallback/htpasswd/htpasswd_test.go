@@ -0,0 +1,63 @@
+package htpasswd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/databus23/keystone"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestFallback(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	file, err := Parse(strings.NewReader("# break glass users\nops:" + string(hash) + "\nlegacy:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !file.Verify("legacy", "secret") {
+		t.Error("Expected SHA password to be verified")
+	}
+
+	identity := &keystone.Token{}
+	identity.User.ID = "u-ops"
+	identity.User.Name = "ops"
+
+	//keystone is unreachable
+	idServer := httptest.NewServer(http.NotFoundHandler())
+	idServer.Close()
+	a := keystone.New(idServer.URL)
+	a.Fallback = file.Fallback(map[string]*keystone.Token{"ops": identity})
+
+	cases := []struct {
+		user, password, status string
+	}{
+		{"ops", "secret", "Confirmed"},
+		{"ops", "wrong", "Invalid"},
+		{"legacy", "secret", "Invalid"},
+	}
+	for _, c := range cases {
+		var status, userID string
+		h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			status = r.Header.Get("X-Identity-Status")
+			userID = r.Header.Get("X-User-Id")
+		}))
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Auth-Token", "1234")
+		req.SetBasicAuth(c.user, c.password)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if status != c.status {
+			t.Errorf("%s/%s: expected status %s, got %s", c.user, c.password, c.status, status)
+		}
+		if status == "Confirmed" && userID != "u-ops" {
+			t.Errorf("Expected user id u-ops, got %q", userID)
+		}
+	}
+}
+
+func TestParseUnsupportedHash(t *testing.T) {
+	if _, err := Parse(strings.NewReader("user:$apr1$abc$def\n")); err == nil {
+		t.Error("Expected error for unsupported hash")
+	}
+}
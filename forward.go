@@ -0,0 +1,73 @@
+package keystone
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// TokenHeader is the http header used to forward a validated token context to internal services.
+// It is removed from incoming requests by Auth.Handler.
+const TokenHeader = "X-Keystone-Token-Context"
+
+// TokenMetadataKey is the gRPC metadata key used to forward a validated token context to internal services.
+// The -bin suffix makes gRPC transmit the value as binary (base64 encoded on the wire).
+const TokenMetadataKey = "x-keystone-token-context-bin"
+
+// ErrNoTokenContext is returned if no forwarded token context is present
+var ErrNoTokenContext = errors.New("No forwarded token context found")
+
+// ToMetadata serializes the token context to gRPC metadata.
+// The result can be converted to metadata.MD of the google.golang.org/grpc/metadata package:
+//
+//	ctx = metadata.NewOutgoingContext(ctx, metadata.MD(token.ToMetadata()))
+//
+// Only forward token contexts to trusted backends which don't accept requests from the outside.
+func (t *Token) ToMetadata() map[string][]string {
+	return map[string][]string{TokenMetadataKey: {string(t.marshal())}}
+}
+
+// TokenFromMetadata reconstructs a token context serialized with Token.ToMetadata.
+// Keys are expected to be lower case as in metadata.MD.
+func TokenFromMetadata(md map[string][]string) (*Token, error) {
+	values := md[TokenMetadataKey]
+	if len(values) == 0 {
+		return nil, ErrNoTokenContext
+	}
+	return unmarshalToken([]byte(values[0]))
+}
+
+// ToHeader serializes the token context to the TokenHeader of an outgoing http request.
+// Only forward token contexts to trusted backends which don't accept requests from the outside.
+// See KeyRing for signed token contexts.
+func (t *Token) ToHeader(h http.Header) {
+	h.Set(TokenHeader, base64.RawURLEncoding.EncodeToString(t.marshal()))
+}
+
+// TokenFromHeader reconstructs a token context serialized with Token.ToHeader
+func TokenFromHeader(h http.Header) (*Token, error) {
+	v := h.Get(TokenHeader)
+	if v == "" {
+		return nil, ErrNoTokenContext
+	}
+	data, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalToken(data)
+}
+
+func (t *Token) marshal() []byte {
+	//a Token only consists of types which can always be marshalled
+	data, _ := json.Marshal(t)
+	return data
+}
+
+func unmarshalToken(data []byte) (*Token, error) {
+	var t Token
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,51 @@
+package keystone
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func forwardedToken() *Token {
+	t := tokenWithRoles("admin", "member")
+	t.ExpiresAt = time.Date(2120, 10, 8, 8, 40, 33, 0, time.UTC)
+	t.User.ID = "u-1"
+	t.User.Enabled = true
+	t.Project = &Project{ID: "p-1", Name: "Arc", Enabled: true, Domain: Domain{ID: "d-1", Enabled: true}}
+	return t
+}
+
+func TestTokenMetadata(t *testing.T) {
+	token := forwardedToken()
+	got, err := TokenFromMetadata(token.ToMetadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, token) {
+		t.Errorf("Expected %+v, got %+v", token, got)
+	}
+	if _, err := TokenFromMetadata(nil); err != ErrNoTokenContext {
+		t.Errorf("Expected ErrNoTokenContext, got %v", err)
+	}
+}
+
+func TestTokenHeader(t *testing.T) {
+	token := forwardedToken()
+	h := http.Header{}
+	token.ToHeader(h)
+	got, err := TokenFromHeader(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, token) {
+		t.Errorf("Expected %+v, got %+v", token, got)
+	}
+
+	req := newRequest("GET", "/")
+	req.Header = h
+	filterIncomingHeaders(req)
+	if _, err := TokenFromHeader(req.Header); err != ErrNoTokenContext {
+		t.Errorf("Expected forwarded token context to be filtered from incoming requests, got %v", err)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/databus23/keystone
+
+go 1.22
@@ -0,0 +1,106 @@
+// Package grpc provides gRPC server interceptors authenticating calls using https://github.com/databus23/keystone
+//
+// The token is read from the x-auth-token metadata of incoming calls and validated with the same Auth
+// (and therefore the same cache) used for http requests. The validated token context is available to
+// handlers via keystone.TokenFromContext:
+//
+//	auth := keystone.New("https://keystone:5000/v3")
+//	auth.RejectUnauthenticated = true
+//	server := grpc.NewServer(
+//		grpc.UnaryInterceptor(keystonegrpc.UnaryServerInterceptor(auth)),
+//		grpc.StreamInterceptor(keystonegrpc.StreamServerInterceptor(auth)),
+//	)
+//
+// Calls are validated like http requests with their metadata as headers and the address of the peer as
+// RemoteAddr, so Auth.ValidationRatePerIP, Auth.ClientIP and Auth.VerifyRequest (e.g. keystone.RestrictSourceIP)
+// apply to them as well.
+//
+// Like the http middleware the interceptors delegate the authorization decision to the handlers unless
+// Auth.RejectUnauthenticated is set, in which case calls without a valid token fail with Unauthenticated
+// or, if Keystone is unavailable, with Unavailable.
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/databus23/keystone"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// TokenMetadataKey is the metadata key carrying the token of incoming calls
+const TokenMetadataKey = "x-auth-token"
+
+// UnaryServerInterceptor returns an interceptor authenticating unary calls with auth
+func UnaryServerInterceptor(auth *keystone.Auth) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, auth, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor authenticating streaming calls with auth
+func StreamServerInterceptor(auth *keystone.Auth) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), auth, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticate validates the token of an incoming call and returns a context carrying the token context
+func authenticate(ctx context.Context, auth *keystone.Auth, method string) (context.Context, error) {
+	var err error
+	if values := metadata.ValueFromIncomingContext(ctx, TokenMetadataKey); len(values) > 0 && values[0] != "" {
+		var token *keystone.Token
+		if token, err = auth.ValidateRequest(callRequest(ctx, method), values[0]); err == nil {
+			return keystone.NewContext(ctx, token), nil
+		}
+	}
+	if !auth.RejectUnauthenticated {
+		return ctx, nil
+	}
+	if err != nil && keystone.IsUnavailable(err) {
+		return nil, status.Error(codes.Unavailable, "keystone unavailable")
+	}
+	return nil, status.Error(codes.Unauthenticated, "invalid or missing token")
+}
+
+// callRequest represents an incoming call as http request for keystone.Auth.ValidateRequest
+func callRequest(ctx context.Context, method string) *http.Request {
+	req := &http.Request{
+		Method:     http.MethodPost,
+		URL:        &url.URL{Path: method},
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		Header:     http.Header{},
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, v := range md {
+		req.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		req.RemoteAddr = p.Addr.String()
+	}
+	return req.WithContext(ctx)
+}
+
+// serverStream overrides the context of a grpc.ServerStream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,123 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/databus23/keystone"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func identityMock() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Subject-Token") != "valid" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"token": {"expires_at": "2120-10-09T15:09:12.355Z", "user": {"id": "u-1"}}}`))
+	}))
+}
+
+func incoming(token string) context.Context {
+	if token == "" {
+		return context.Background()
+	}
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(TokenMetadataKey, token))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	idServer := identityMock()
+	defer idServer.Close()
+
+	auth := keystone.New(idServer.URL)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		if token, ok := keystone.TokenFromContext(ctx); ok {
+			return token.User.ID, nil
+		}
+		return "anonymous", nil
+	}
+	cases := []struct {
+		token  string
+		reject bool
+		resp   interface{}
+		code   codes.Code
+	}{
+		{"valid", false, "u-1", codes.OK},
+		{"invalid", false, "anonymous", codes.OK},
+		{"", false, "anonymous", codes.OK},
+		{"valid", true, "u-1", codes.OK},
+		{"invalid", true, nil, codes.Unauthenticated},
+		{"", true, nil, codes.Unauthenticated},
+	}
+	for _, c := range cases {
+		auth.RejectUnauthenticated = c.reject
+		resp, err := UnaryServerInterceptor(auth)(incoming(c.token), nil, &grpc.UnaryServerInfo{}, handler)
+		if resp != c.resp || status.Code(err) != c.code {
+			t.Errorf("%+v: got %v, %v", c, resp, err)
+		}
+	}
+
+	idServer.Close()
+	auth.RejectUnauthenticated = true
+	if _, err := UnaryServerInterceptor(auth)(incoming("other"), nil, &grpc.UnaryServerInfo{}, handler); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected Unavailable while Keystone is down, got %v", err)
+	}
+}
+
+type stream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stream) Context() context.Context { return s.ctx }
+
+func TestStreamServerInterceptor(t *testing.T) {
+	idServer := identityMock()
+	defer idServer.Close()
+
+	auth := keystone.New(idServer.URL)
+	auth.RejectUnauthenticated = true
+	var user string
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		token, _ := keystone.TokenFromContext(ss.Context())
+		user = token.User.ID
+		return nil
+	}
+	if err := StreamServerInterceptor(auth)(nil, &stream{ctx: incoming("valid")}, &grpc.StreamServerInfo{}, handler); err != nil || user != "u-1" {
+		t.Errorf("Expected token context in stream, got %q, %v", user, err)
+	}
+	if err := StreamServerInterceptor(auth)(nil, &stream{ctx: incoming("invalid")}, &grpc.StreamServerInfo{}, handler); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestSourceRestriction(t *testing.T) {
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"token": {"expires_at": "2120-10-09T15:09:12.355Z", "user": {"id": "u-1"}, "extras": {"allowed_cidrs": ["10.0.0.0/8"]}}}`))
+	}))
+	defer idServer.Close()
+
+	auth := keystone.New(idServer.URL)
+	auth.RejectUnauthenticated = true
+	auth.VerifyRequest = keystone.RestrictSourceIP("allowed_cidrs", nil)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	for _, c := range []struct {
+		peer string
+		code codes.Code
+	}{
+		{"10.1.2.3", codes.OK},
+		{"192.0.2.1", codes.Unauthenticated},
+	} {
+		ctx := peer.NewContext(incoming("valid"), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(c.peer), Port: 4711}})
+		if _, err := UnaryServerInterceptor(auth)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Call"}, handler); status.Code(err) != c.code {
+			t.Errorf("%s: expected %s, got %v", c.peer, c.code, err)
+		}
+	}
+}
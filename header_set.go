@@ -0,0 +1,50 @@
+package keystone
+
+import "net/http"
+
+// HeaderSet selects the identity headers passed on to subsequent handlers
+type HeaderSet int
+
+const (
+	//FullHeaders passes on all identity headers. This is the default.
+	FullHeaders HeaderSet = iota + 1
+	//MinimalHeaders only passes on X-Identity-Status, X-User-Id, X-Project-Id, X-Domain-Id, X-System-Scope, X-Roles and X-Role-Ids.
+	//Names and domains are omitted for deployments which must not leak personal data to downstream backends.
+	MinimalHeaders
+)
+
+// minimalHeaders are the identity headers set with MinimalHeaders
+var minimalHeaders = []string{"X-User-Id", "X-Project-Id", "X-Domain-Id", "X-System-Scope", "X-Roles", "X-Role-Ids"}
+
+// headerSet returns the header set for requests matching route
+func (a *Auth) headerSet(route *Route) HeaderSet {
+	if route != nil && route.Headers != 0 {
+		return route.Headers
+	}
+	if a.Headers != 0 {
+		return a.Headers
+	}
+	return FullHeaders
+}
+
+// setHeaders sets the identity headers of a validated token on an incoming request
+func (a *Auth) setHeaders(header http.Header, token *Token, route *Route) {
+	derived := http.Header{}
+	if a.headerSet(route) != MinimalHeaders {
+		token.SetHeaders(derived)
+	} else {
+		derived.Set("X-Identity-Status", "Confirmed")
+		headers := token.headers()
+		for _, k := range minimalHeaders {
+			if v, ok := headers[k]; ok {
+				derived.Set(k, v)
+			}
+		}
+	}
+	if a.HeaderMapper != nil {
+		a.HeaderMapper(token, derived)
+	}
+	for k, v := range derived {
+		header[k] = v
+	}
+}
@@ -0,0 +1,105 @@
+package keystone
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMinimalHeaders(t *testing.T) {
+	idServer := identityMock(200, `{"token": {
+		"expires_at": "2120-10-08T08:40:33.100Z",
+		"user": {"id": "u-1", "name": "jane", "domain": {"id": "d-1", "name": "Default"}},
+		"project": {"id": "p-1", "name": "secret-project", "domain": {"id": "d-1", "name": "Default"}},
+		"roles": [{"id": "r-1", "name": "member"}]
+	}}`)
+	defer idServer.Close()
+
+	var headers http.Header
+	a := New(idServer.URL)
+	a.Routes = []Route{{Pattern: "/partner/**", Headers: MinimalHeaders}}
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+	}))
+
+	for _, tc := range []struct {
+		path     string
+		expected map[string]string
+	}{
+		{"/partner/api", map[string]string{
+			"X-Identity-Status": "Confirmed",
+			"X-User-Id":         "u-1",
+			"X-Project-Id":      "p-1",
+			"X-Roles":           "member",
+			"X-Role-Ids":        "r-1",
+			"X-User-Name":       "",
+			"X-User-Domain-Id":  "",
+			"X-Project-Name":    "",
+		}},
+		{"/internal", map[string]string{
+			"X-Identity-Status": "Confirmed",
+			"X-User-Name":       "jane",
+			"X-Project-Name":    "secret-project",
+		}},
+	} {
+		req := httptest.NewRequest("GET", tc.path, nil)
+		req.Header.Set("X-Auth-Token", "1234")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		for k, v := range tc.expected {
+			if headers.Get(k) != v {
+				t.Errorf("%s: Expected %s to be %q, got %q", tc.path, k, v, headers.Get(k))
+			}
+		}
+	}
+
+	a.Headers = MinimalHeaders
+	preview, err := a.PreviewHeaders(context.Background(), "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preview) != 5 || preview["X-User-Name"] != "" {
+		t.Errorf("Expected minimal headers, got %v", preview)
+	}
+}
+
+func TestHeaderMapper(t *testing.T) {
+	idServer := identityMock(200, `{"token": {
+		"expires_at": "2120-10-08T08:40:33.100Z",
+		"user": {"id": "u-1", "name": "jane", "domain": {"id": "d-1", "name": "Default"}},
+		"project": {"id": "p-1", "name": "secret-project", "domain": {"id": "d-1", "name": "Default"}},
+		"roles": [{"id": "r-1", "name": "member"}]
+	}}`)
+	defer idServer.Close()
+
+	var headers http.Header
+	a := New(idServer.URL)
+	a.HeaderMapper = func(token *Token, header http.Header) {
+		header.Set("X-Tenant-Id", header.Get("X-Project-Id"))
+		header.Del("X-Project-Id")
+		header.Del("X-User-Name")
+		header.Set("X-User-Email", token.User.Email)
+	}
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Auth-Token", "1234")
+	req.Header.Set("X-User-Name", "forged")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	for k, v := range map[string]string{
+		"X-Identity-Status": "Confirmed",
+		"X-Tenant-Id":       "p-1",
+		"X-Project-Id":      "",
+		"X-User-Name":       "",
+		"X-Role-Ids":        "r-1",
+	} {
+		if headers.Get(k) != v {
+			t.Errorf("Expected %s to be %q, got %q", k, v, headers.Get(k))
+		}
+	}
+	if _, ok := headers["X-User-Email"]; !ok {
+		t.Error("Expected header added by the mapper")
+	}
+}
@@ -0,0 +1,102 @@
+package keystone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthCacheTime is how long the result of a health check served on Auth.HealthPath is reused
+const healthCacheTime = 5 * time.Second
+
+// Pinger is implemented by caches able to check the connection to their backend, see Auth.HealthCheck
+type Pinger interface {
+	//Ping returns an error if the cache backend can't be reached
+	Ping(ctx context.Context) error
+}
+
+// HealthCheck verifies that the middleware is able to validate tokens, e.g. for Kubernetes readiness probes:
+// at least one of Endpoint and Endpoints must answer a probe (see ProbeEndpoints), the token of the service
+// user must be accepted by Keystone if ServiceCredentials are set, and the TokenCache must be reachable if
+// it implements Pinger. All failures are reported in the returned error.
+func (a *Auth) HealthCheck(ctx context.Context) error {
+	a.ensureDefaults()
+	var errs []error
+	endpoint := ""
+	for _, s := range a.ProbeEndpoints(ctx) {
+		if s.Healthy {
+			endpoint = s.Endpoint
+			break
+		}
+		errs = append(errs, fmt.Errorf("Keystone %s: %w", s.Endpoint, s.Err))
+	}
+	if endpoint != "" {
+		errs = nil
+		if err := a.checkServiceToken(ctx, endpoint); err != nil {
+			errs = append(errs, fmt.Errorf("Service token: %w", err))
+		}
+	}
+	if p, ok := a.cache().(Pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("Token cache: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkServiceToken validates the service user's token against the endpoint
+func (a *Auth) checkServiceToken(ctx context.Context, endpoint string) error {
+	s := a.serviceLogin(endpoint)
+	if s == nil {
+		return nil
+	}
+	serviceToken, err := a.serviceToken(s)
+	if err != nil {
+		return err
+	}
+	_, err = a.sendValidation(ctx, endpoint, serviceToken, serviceToken)
+	return err
+}
+
+// healthState caches the result of the health check served on HealthPath, so probes
+// can't be used to flood Keystone and the cache backend with requests
+type healthState struct {
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+// cachedHealthCheck returns the result of HealthCheck, running it at most once per healthCacheTime
+func (a *Auth) cachedHealthCheck(ctx context.Context) error {
+	s := &a.health
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.checked) < healthCacheTime {
+		return s.err
+	}
+	err := a.HealthCheck(ctx)
+	if ctx.Err() != nil {
+		//the result of an aborted check says nothing about the health of Keystone
+		return err
+	}
+	if err != nil {
+		a.log(ctx, slog.LevelWarn, "Health check failed", "error", err)
+	}
+	s.checked, s.err = time.Now(), err
+	return err
+}
+
+// serveHealth answers requests to HealthPath with the result of HealthCheck
+func (h *handler) serveHealth(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	if err := h.cachedHealthCheck(req.Context()); err != nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("OK\n"))
+}
@@ -0,0 +1,89 @@
+package keystone
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type pingCache struct {
+	*InMemoryCache
+	err error
+}
+
+func (c pingCache) Ping(ctx context.Context) error {
+	return c.err
+}
+
+func TestHealthCheck(t *testing.T) {
+	var serviceTokenValid atomic.Bool
+	serviceTokenValid.Store(true)
+	var calls atomic.Int32
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch {
+		case r.Method == "POST":
+			w.Header().Set("X-Subject-Token", "service-token")
+			w.WriteHeader(201)
+			io.WriteString(w, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z"}}`)
+		case r.URL.Path == "/auth/tokens":
+			if !serviceTokenValid.Load() {
+				w.WriteHeader(404)
+				return
+			}
+			io.WriteString(w, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z"}}`)
+		}
+	}))
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.ServiceCredentials = PasswordCredentials{UserID: "u-service", Password: "secret"}
+	cache := pingCache{InMemoryCache: NewInMemoryCache(10)}
+	a.TokenCache = cache
+	a.HealthPath = "/healthz"
+	var logs bytes.Buffer
+	a.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected health checks not to be passed on")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != 200 {
+		t.Errorf("Expected healthy, got %d: %s", rec.Code, rec.Body)
+	}
+	//the result is reused for repeated probes
+	n := calls.Load()
+	for i := 0; i < 5; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+	}
+	if calls.Load() != n {
+		t.Errorf("Expected cached health check result, got %d requests to Keystone", calls.Load()-n)
+	}
+
+	serviceTokenValid.Store(false)
+	a.TokenCache = pingCache{InMemoryCache: cache.InMemoryCache, err: errors.New("connection refused")}
+	err := a.HealthCheck(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "Service token") || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("Expected service token and cache failures, got %v", err)
+	}
+
+	idServer.Close()
+	a.health.checked = time.Time{}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != 503 || strings.Contains(rec.Body.String(), idServer.URL) {
+		t.Errorf("Expected unhealthy without details, got %d: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(logs.String(), "Health check failed") || !strings.Contains(logs.String(), "Keystone") {
+		t.Errorf("Expected failure to be logged, got %q", logs.String())
+	}
+}
@@ -0,0 +1,59 @@
+package keystone
+
+import (
+	"context"
+	"time"
+)
+
+// fetchToken validates a token against a keystone endpoint, hedging the request if configured
+func (a *Auth) fetchToken(ctx context.Context, endpoint, authToken string) (*Token, error) {
+	if a.HedgeDelay <= 0 {
+		return a.requestFailover(ctx, endpoint, authToken)
+	}
+	return a.hedgedRequestToken(ctx, endpoint, authToken)
+}
+
+type tokenResult struct {
+	token *Token
+	err   error
+}
+
+// hedgedRequestToken sends a second validation request if the first one didn't complete within
+// HedgeDelay and returns the first successful result. The outstanding request is cancelled.
+// With multiple nodes (see Auth.Endpoints) the second request is sent to the node following the one
+// the first request was sent to.
+func (a *Auth) hedgedRequestToken(ctx context.Context, endpoint, authToken string) (*Token, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan tokenResult, 2)
+	request := func(nodes []string) {
+		token, err := a.requestNodes(ctx, endpoint, nodes, authToken)
+		results <- tokenResult{token, err}
+	}
+
+	//the nodes are picked once, other validations advance the round-robin meanwhile
+	nodes := a.nodes(endpoint)
+	go request(nodes)
+	timer := time.NewTimer(a.HedgeDelay)
+	defer timer.Stop()
+
+	pending := 1
+	var err error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if !a.throttle.throttled() {
+				pending++
+				go request(append(append([]string{}, nodes[1:]...), nodes[0]))
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				return res.token, nil
+			}
+			err = res.err
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,73 @@
+package keystone
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgedValidation(t *testing.T) {
+	var requests atomic.Int32
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			//first request is slow
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z"}}`)
+	}))
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.HedgeDelay = 20 * time.Millisecond
+
+	start := time.Now()
+	if _, err := a.Validate("1234"); err != nil {
+		t.Fatal("Validation failed: ", err)
+	}
+	if d := time.Since(start); d > 1*time.Second {
+		t.Errorf("Hedged request didn't return early, took %s", d)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected 2 requests, got %d", n)
+	}
+}
+
+func TestHedgedValidationNextNode(t *testing.T) {
+	a := &Auth{HedgeDelay: 20 * time.Millisecond}
+	var first atomic.Bool
+	var requests [2]atomic.Int32
+	node := func(i int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests[i].Add(1)
+			if first.CompareAndSwap(false, true) {
+				//another validation advances the round-robin while the first request is slow
+				a.nodes(a.Endpoint)
+				select {
+				case <-time.After(2 * time.Second):
+				case <-r.Context().Done():
+					return
+				}
+			}
+			io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z"}}`)
+		}))
+	}
+	node1, node2 := node(0), node(1)
+	defer node1.Close()
+	defer node2.Close()
+	a.Endpoint, a.Endpoints = node1.URL, []string{node2.URL}
+	a.Handler(okHandler)
+
+	if _, err := a.Validate("1234"); err != nil {
+		t.Fatal("Validation failed: ", err)
+	}
+	if n1, n2 := requests[0].Load(), requests[1].Load(); n1 != 1 || n2 != 1 {
+		t.Errorf("Expected hedged request to be sent to the other node, got %d and %d requests", n1, n2)
+	}
+}
@@ -0,0 +1,90 @@
+package keystone
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Identity is the flat view of a validated token context handed to downstream handlers.
+// Project, domain and system scope fields are empty unless the token has the respective scope.
+type Identity struct {
+	UserID            string
+	UserName          string
+	UserDomainID      string
+	UserDomainName    string
+	ProjectID         string
+	ProjectName       string
+	ProjectDomainID   string
+	ProjectDomainName string
+	DomainID          string
+	DomainName        string
+	SystemScope       string
+	Roles             []string
+	ExpiresAt         time.Time
+}
+
+// Identity returns the identity described by the token context
+func (t Token) Identity() Identity {
+	id := Identity{
+		UserID:         t.User.ID,
+		UserName:       t.User.Name,
+		UserDomainID:   t.User.Domain.ID,
+		UserDomainName: t.User.Domain.Name,
+		Roles:          t.RoleNames(),
+		ExpiresAt:      t.ExpiresAt,
+	}
+	if p := t.Project; p != nil {
+		id.ProjectID = p.ID
+		id.ProjectName = p.Name
+		id.ProjectDomainID = p.Domain.ID
+		id.ProjectDomainName = p.Domain.Name
+	}
+	if d := t.Domain; d != nil {
+		id.DomainID = d.ID
+		id.DomainName = d.Name
+	}
+	if t.systemScoped() {
+		id.SystemScope = "all"
+	}
+	return id
+}
+
+// HasRole reports whether the identity has any of the given roles
+func (id Identity) HasRole(roles ...string) bool {
+	for _, have := range id.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IdentityFromContext returns the identity of a request authenticated by Auth.Handler
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	token, ok := TokenFromContext(ctx)
+	if !ok {
+		return Identity{}, false
+	}
+	return token.Identity(), true
+}
+
+// RequireIdentity adapts a handler expecting an authenticated identity.
+// Requests without a validated token are rejected with 401.
+// It must be placed behind the handler returned by Auth.Handler.
+//
+//	http.Handle("/v1/things", auth.Handler(keystone.RequireIdentity(func(w http.ResponseWriter, r *http.Request, id keystone.Identity) {
+//		fmt.Fprintf(w, "Hello %s", id.UserName)
+//	})))
+func RequireIdentity(h func(w http.ResponseWriter, r *http.Request, id Identity)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := IdentityFromContext(r.Context())
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h(w, r, id)
+	})
+}
@@ -0,0 +1,34 @@
+package keystone
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireIdentity(t *testing.T) {
+	var got Identity
+	h := RequireIdentity(func(w http.ResponseWriter, r *http.Request, id Identity) {
+		got = id
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, newRequest("GET", "/"))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without identity, got %d", rec.Code)
+	}
+
+	token := tokenWithRoles("member")
+	token.User.ID = "u-1"
+	token.Project = &Project{ID: "p-1", Domain: Domain{ID: "d-1"}}
+	req := newRequest("GET", "/")
+	req = req.WithContext(withToken(req.Context(), token))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", rec.Code)
+	}
+	if got.UserID != "u-1" || got.ProjectID != "p-1" || got.ProjectDomainID != "d-1" || got.DomainID != "" || !got.HasRole("member") {
+		t.Errorf("Unexpected identity: %+v", got)
+	}
+}
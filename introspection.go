@@ -0,0 +1,66 @@
+package keystone
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// Rule is an authorization rule evaluated against a validated token context
+type Rule func(t *Token) bool
+
+// HasRole returns a rule satisfied by tokens having any of the given roles
+func HasRole(roles ...string) Rule {
+	return func(t *Token) bool {
+		for _, role := range t.Roles {
+			for _, r := range roles {
+				if role.Name == r {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// Introspection is a http handler reporting which of the configured rules the caller is authorized for.
+// This allows UIs to hide actions a user can't perform instead of probing with requests.
+// It must be placed behind the handler returned by Auth.Handler.
+//
+//	mux.Handle("/permissions", auth.Handler(&keystone.Introspection{Rules: map[string]keystone.Rule{
+//		"servers:create": keystone.HasRole("member", "admin"),
+//		"servers:delete": keystone.HasRole("admin"),
+//	}}))
+//
+// The response lists the names of the allowed and denied rules:
+//
+//	{"allowed": ["servers:create"], "denied": ["servers:delete"]}
+type Introspection struct {
+	Rules map[string]Rule
+}
+
+type introspectionResponse struct {
+	Allowed []string `json:"allowed"`
+	Denied  []string `json:"denied"`
+}
+
+func (i *Introspection) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := TokenFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	resp := introspectionResponse{Allowed: []string{}, Denied: []string{}}
+	for name, rule := range i.Rules {
+		if rule(token) {
+			resp.Allowed = append(resp.Allowed, name)
+		} else {
+			resp.Denied = append(resp.Denied, name)
+		}
+	}
+	sort.Strings(resp.Allowed)
+	sort.Strings(resp.Denied)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,42 @@
+package keystone
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIntrospection(t *testing.T) {
+	idServer := identityMock(200, `
+{
+  "token": {
+    "expires_at": "2120-10-09T15:09:12.355Z",
+    "issued_at": "2015-10-08T15:09:12.355Z",
+    "user": {"id": "u-1"},
+    "roles": [{"id": "r-member", "name": "member"}]
+  }
+}`)
+	defer idServer.Close()
+
+	a := Auth{Endpoint: idServer.URL}
+	h := a.Handler(&Introspection{Rules: map[string]Rule{
+		"servers:list":   HasRole("reader", "member"),
+		"servers:create": HasRole("member"),
+		"servers:delete": HasRole("admin"),
+	}})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, newRequest("GET", "/permissions"))
+	if rec.Code != 401 {
+		t.Errorf("Expected 401 without token, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req := newRequest("GET", "/permissions")
+	req.Header.Set("X-Auth-Token", "1234")
+	h.ServeHTTP(rec, req)
+	expected := `{"allowed":["servers:create","servers:list"],"denied":["servers:delete"]}`
+	if body := strings.TrimSpace(rec.Body.String()); body != expected {
+		t.Errorf("Expected %s, got %s", expected, body)
+	}
+}
@@ -0,0 +1,101 @@
+package keystone
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// ProjectIsolation is a middleware ensuring that requests only access the project their token is scoped to.
+// It must be placed behind the handler returned by Auth.Handler, the token context is taken from the request
+// context (see TokenFromContext).
+//
+//	isolation := &keystone.ProjectIsolation{
+//		Pattern:    regexp.MustCompile(`^/v1/projects/([^/]+)`),
+//		AdminRoles: []string{"cloud_admin"},
+//	}
+//	http.ListenAndServe(":3000", auth.Handler(isolation.Handler(myApp)))
+type ProjectIsolation struct {
+	//Pattern extracts the project id from the url path. The project id is taken from the capture group
+	//named project_id or the first capture group if there is no such group.
+	//Requests with a path not matching the pattern are passed through unchecked.
+	Pattern *regexp.Regexp
+	//Tokens having any of these roles may access all projects
+	AdminRoles []string
+}
+
+// Handler returns a http handler for use in a middleware chain.
+func (p *ProjectIsolation) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		projectID, found := matchPath(p.Pattern, "project_id", r.URL.Path)
+		if !found {
+			h.ServeHTTP(w, r)
+			return
+		}
+		token, ok := TokenFromContext(r.Context())
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		//an empty project id in the path doesn't match tokens which aren't project scoped
+		scoped := projectID != "" && token.Project != nil && token.Project.ID == projectID
+		if !scoped && !HasRole(p.AdminRoles...)(token) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// UserIsolation is a middleware ensuring that requests only access resources of the user their token belongs to,
+// e.g. the credentials below /v3/users/{user_id}. It must be placed behind the handler returned by Auth.Handler,
+// the token context is taken from the request context (see TokenFromContext).
+//
+//	isolation := &keystone.UserIsolation{
+//		Pattern:    regexp.MustCompile(`^/v1/users/([^/]+)`),
+//		AdminRoles: []string{"cloud_admin"},
+//	}
+//	http.ListenAndServe(":3000", auth.Handler(isolation.Handler(myApp)))
+type UserIsolation struct {
+	//Pattern extracts the user id from the url path. The user id is taken from the capture group
+	//named user_id or the first capture group if there is no such group.
+	//Requests with a path not matching the pattern are passed through unchecked.
+	Pattern *regexp.Regexp
+	//Tokens having any of these roles may access all users
+	AdminRoles []string
+}
+
+// Handler returns a http handler for use in a middleware chain.
+func (u *UserIsolation) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, found := matchPath(u.Pattern, "user_id", r.URL.Path)
+		if !found {
+			h.ServeHTTP(w, r)
+			return
+		}
+		token, ok := TokenFromContext(r.Context())
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if (userID == "" || userID != token.User.ID) && !HasRole(u.AdminRoles...)(token) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// matchPath extracts the value of the named capture group (or the first group) from path
+func matchPath(pattern *regexp.Regexp, name, path string) (string, bool) {
+	if pattern == nil {
+		return "", false
+	}
+	match := pattern.FindStringSubmatch(path)
+	if match == nil || len(match) < 2 {
+		return "", false
+	}
+	if i := pattern.SubexpIndex(name); i > 0 {
+		return match[i], true
+	}
+	return match[1], true
+}
@@ -0,0 +1,96 @@
+package keystone
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestProjectIsolation(t *testing.T) {
+	isolation := &ProjectIsolation{
+		Pattern:    regexp.MustCompile(`^/v1/projects/(?P<project_id>[^/]*)`),
+		AdminRoles: []string{"cloud_admin"},
+	}
+	h := isolation.Handler(okHandler)
+
+	cases := []struct {
+		path  string
+		token *Token
+		code  int
+	}{
+		{"/v1/info", nil, 200},
+		{"/v1/projects/p-1/servers", nil, 401},
+		{"/v1/projects/p-1/servers", isolationToken("p-1", "u-1"), 200},
+		{"/v1/projects/p-1/servers", isolationToken("p-2", "u-1", "member"), 403},
+		{"/v1/projects/p-1/servers", isolationToken("p-2", "u-1", "member", "cloud_admin"), 200},
+		//an empty capture doesn't match unscoped tokens
+		{"/v1/projects//servers", isolationToken("", "u-1"), 403},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		req := newRequest("GET", c.path)
+		//identity headers sent by clients are ignored
+		req.Header.Set("X-Identity-Status", "Confirmed")
+		req.Header.Set("X-Project-Id", "p-1")
+		req.Header.Set("X-Roles", "cloud_admin")
+		if c.token != nil {
+			req = req.WithContext(withToken(req.Context(), c.token))
+		}
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.code {
+			t.Errorf("%s %+v: expected status %d, got %d", c.path, c.token, c.code, rec.Code)
+		}
+	}
+}
+
+// isolationToken returns a token context of the given user, project (unscoped if empty) and roles
+func isolationToken(projectID, userID string, roles ...string) *Token {
+	token := &Token{}
+	token.User.ID = userID
+	if projectID != "" {
+		token.Project = &Project{ID: projectID}
+	}
+	for _, role := range roles {
+		token.Roles = append(token.Roles, struct {
+			ID   string
+			Name string
+		}{"r-" + role, role})
+	}
+	return token
+}
+
+func TestUserIsolation(t *testing.T) {
+	isolation := &UserIsolation{
+		Pattern:    regexp.MustCompile(`^/v1/users/(?P<user_id>[^/]*)`),
+		AdminRoles: []string{"cloud_admin"},
+	}
+	h := isolation.Handler(okHandler)
+
+	cases := []struct {
+		path  string
+		token *Token
+		code  int
+	}{
+		{"/v1/info", nil, 200},
+		{"/v1/users/u-1/keys", nil, 401},
+		{"/v1/users/u-1/keys", isolationToken("p-1", "u-1"), 200},
+		{"/v1/users/u-1/keys", isolationToken("p-1", "u-2", "member"), 403},
+		{"/v1/users/u-1/keys", isolationToken("p-1", "u-2", "member", "cloud_admin"), 200},
+		{"/v1/users//keys", isolationToken("p-1", ""), 403},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		req := newRequest("GET", c.path)
+		//identity headers sent by clients are ignored
+		req.Header.Set("X-Identity-Status", "Confirmed")
+		req.Header.Set("X-User-Id", "u-1")
+		req.Header.Set("X-Roles", "cloud_admin")
+		if c.token != nil {
+			req = req.WithContext(withToken(req.Context(), c.token))
+		}
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.code {
+			t.Errorf("%s %+v: expected status %d, got %d", c.path, c.token, c.code, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package keystone
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// fetchFromIssuers validates a token against endpoint and, if the token is unknown there and endpoint is
+// the configured Endpoint, against SecondaryEndpoint. The validating endpoint is recorded in Token.Issuer.
+func (a *Auth) fetchFromIssuers(ctx context.Context, endpoint, authToken string) (*Token, error) {
+	issuer := endpoint
+	token, err := a.fetchToken(ctx, issuer, authToken)
+	var kerr *Error
+	if endpoint == a.Endpoint && a.SecondaryEndpoint != "" && errors.As(err, &kerr) && kerr.StatusCode == http.StatusNotFound {
+		issuer = a.SecondaryEndpoint
+		token, err = a.fetchToken(ctx, issuer, authToken)
+	}
+	if err != nil {
+		return nil, err
+	}
+	token.Issuer = issuer
+	return token, nil
+}
@@ -0,0 +1,71 @@
+package keystone
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecondaryEndpoint(t *testing.T) {
+	primary := identityMock(404, `{"error": {"code": 404, "message": "Could not find token", "title": "Not Found"}}`)
+	defer primary.Close()
+	secondary := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z"}}`)
+	defer secondary.Close()
+
+	a := Auth{Endpoint: primary.URL, SecondaryEndpoint: secondary.URL}
+	h := a.Handler(checkHeaders(t, map[string]string{"X-Identity-Status": "Confirmed"}))
+	req := newRequest("GET", "/foo")
+	req.Header.Set("X-Auth-Token", "1234")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	token, err := a.Validate("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Issuer != secondary.URL {
+		t.Errorf("Expected issuer %s, got %s", secondary.URL, token.Issuer)
+	}
+	if n := a.Stats().Issuers[secondary.URL]; n != 1 {
+		t.Errorf("Expected 1 request confirmed by secondary issuer, got %d", n)
+	}
+}
+
+func TestSecondaryServiceCredentials(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.Header().Set("X-Subject-Token", "primary-service")
+			w.WriteHeader(201)
+			io.WriteString(w, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z", "user": {"id": "u-service"}}}`)
+			return
+		}
+		if r.Header.Get("X-Auth-Token") != "primary-service" {
+			w.WriteHeader(401)
+			return
+		}
+		w.WriteHeader(404)
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(&serviceUserMock{})
+	defer secondary.Close()
+
+	a := Auth{Endpoint: primary.URL, SecondaryEndpoint: secondary.URL}
+	a.ServiceCredentials = PasswordCredentials{UserID: "u-service", Password: "secret"}
+	a.SecondaryServiceCredentials = PasswordCredentials{UserID: "u-service", Password: "other"}
+	a.Handler(okHandler)
+
+	//the secondary endpoint only accepts its own service token
+	token, err := a.Validate("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Issuer != secondary.URL || token.User.ID != "u-1234" {
+		t.Errorf("Expected token validated by secondary endpoint, got %+v", token)
+	}
+	if err := a.RefreshServiceToken(); err != nil {
+		t.Fatal(err)
+	}
+	if a.serviceUser.token() != "primary-service" || a.secondaryServiceUser.token() != "service-2" {
+		t.Errorf("Expected both service users to be refreshed, got %q and %q", a.serviceUser.token(), a.secondaryServiceUser.token())
+	}
+}
@@ -0,0 +1,141 @@
+package keystone
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrInvalidJWS is returned for JWS tokens which aren't signed by any of the configured keys
+var ErrInvalidJWS = errors.New("Invalid JWS token signature")
+
+// JWSValidator validates tokens issued by Keystone's JWS token provider locally using the public keys
+// of Keystone's JWS key repository. Other tokens (e.g. Fernet) are validated against Keystone.
+//
+//	keys, err := keystone.LoadJWSKeys("/etc/keystone/jws-keys/public")
+//	...
+//	auth.LocalValidator = &keystone.JWSValidator{Keys: keys}
+type JWSValidator struct {
+	Keys []*ecdsa.PublicKey
+	//Accept tokens based on their payload alone without contacting Keystone. The token context then only
+	//contains the user and the ID of the scope but no names or roles, and revoked tokens are accepted until
+	//they expire. By default tokens are verified locally, so forged and expired tokens never reach Keystone,
+	//and then expanded by validating them against Keystone.
+	Offline bool
+	//Tolerated deviation of the local clock from Keystone's, see Auth.ClockSkew
+	ClockSkew time.Duration
+}
+
+// jwsClaims is the payload of Keystone JWS tokens
+type jwsClaims struct {
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	ProjectID string `json:"openstack_project_id"`
+	DomainID  string `json:"openstack_domain_id"`
+}
+
+// ValidateLocal implements LocalValidator
+func (v *JWSValidator) ValidateLocal(authToken string) (*Token, bool, error) {
+	parts := strings.Split(authToken, ".")
+	if len(parts) != 3 {
+		return nil, false, ErrNotLocal
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if data, err := base64.RawURLEncoding.DecodeString(parts[0]); err != nil || json.Unmarshal(data, &header) != nil || header.Alg != "ES256" {
+		return nil, false, ErrNotLocal
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 || !v.verify(parts[0]+"."+parts[1], sig) {
+		return nil, false, ErrInvalidJWS
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, false, err
+	}
+	var claims jwsClaims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, false, err
+	}
+
+	t := &Token{ExpiresAt: time.Unix(claims.ExpiresAt, 0), IssuedAt: time.Unix(claims.IssuedAt, 0)}
+	if !t.ValidWithSkew(v.ClockSkew) {
+		return nil, false, ErrTokenExpired
+	}
+	t.User.ID = claims.Subject
+	t.User.Enabled = true
+	t.User.Domain.Enabled = true
+	if claims.ProjectID != "" {
+		t.Project = &Project{ID: claims.ProjectID, Enabled: true, Domain: Domain{Enabled: true}}
+	}
+	if claims.DomainID != "" {
+		t.Domain = &Domain{ID: claims.DomainID, Enabled: true}
+	}
+	return t, v.Offline, nil
+}
+
+// verify checks an ES256 signature against all keys
+func (v *JWSValidator) verify(signed string, sig []byte) bool {
+	hash := sha256.Sum256([]byte(signed))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	for _, key := range v.Keys {
+		if ecdsa.Verify(key, hash[:], r, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadJWSKeys reads the PEM encoded public keys of a Keystone JWS key repository
+// (e.g. /etc/keystone/jws-keys/public)
+func LoadJWSKeys(dir string) ([]*ecdsa.PublicKey, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	var keys []*ecdsa.PublicKey
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseJWSKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("No JWS keys found in %s", dir)
+	}
+	return keys, nil
+}
+
+// ParseJWSKey parses a PEM encoded ECDSA public key
+func ParseJWSKey(data []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("No PEM data found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("Not an ECDSA public key")
+	}
+	return ecKey, nil
+}
@@ -0,0 +1,105 @@
+package keystone
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// signJWS creates a Keystone JWS token with the given claims
+func signJWS(t *testing.T, key *ecdsa.PrivateKey, claims map[string]interface{}) string {
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","typ":"JWT"}`)) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWSValidator(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	now := time.Now().Unix()
+	claims := map[string]interface{}{"sub": "u-1", "iat": now, "exp": now + 3600, "openstack_project_id": "p-1"}
+
+	v := &JWSValidator{Keys: []*ecdsa.PublicKey{&other.PublicKey, &key.PublicKey}}
+	token, complete, err := v.ValidateLocal(signJWS(t, key, claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if complete || token.User.ID != "u-1" || token.Project == nil || token.Project.ID != "p-1" {
+		t.Errorf("Unexpected result: %+v, %v", token, complete)
+	}
+	v.Offline = true
+	if _, complete, _ := v.ValidateLocal(signJWS(t, key, claims)); !complete {
+		t.Error("Expected offline token context to be complete")
+	}
+
+	if _, _, err := v.ValidateLocal("gAAAAABfernet"); err != ErrNotLocal {
+		t.Errorf("Expected ErrNotLocal for non JWS token, got %v", err)
+	}
+	forged, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, _, err := v.ValidateLocal(signJWS(t, forged, claims)); err != ErrInvalidJWS {
+		t.Errorf("Expected ErrInvalidJWS, got %v", err)
+	}
+	claims["exp"] = now - 1
+	if _, _, err := v.ValidateLocal(signJWS(t, key, claims)); err != ErrTokenExpired {
+		t.Errorf("Expected ErrTokenExpired, got %v", err)
+	}
+}
+
+func TestJWSValidatorAuth(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u-1", "name": "jane"}}}`)
+	defer idServer.Close()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	now := time.Now().Unix()
+	authToken := signJWS(t, key, map[string]interface{}{"sub": "u-1", "iat": now, "exp": now + 3600})
+
+	a := New(idServer.URL)
+	a.LocalValidator = &JWSValidator{Keys: []*ecdsa.PublicKey{&key.PublicKey}}
+	token, err := a.Validate(authToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.User.Name != "jane" {
+		t.Errorf("Expected token context to be expanded by Keystone, got %+v", token)
+	}
+	forged, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, err := a.Validate(signJWS(t, forged, map[string]interface{}{"sub": "u-1", "iat": now, "exp": now + 3600})); err != ErrInvalidJWS {
+		t.Errorf("Expected forged token to be rejected locally, got %v", err)
+	}
+	if n := a.LatencyStats().Samples; n != 1 {
+		t.Errorf("Expected a single request to Keystone, got %d", n)
+	}
+}
+
+func TestLoadJWSKeys(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadJWSKeys(dir); err == nil {
+		t.Error("Expected empty key repository to fail")
+	}
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	os.WriteFile(filepath.Join(dir, "0.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+	keys, err := LoadJWSKeys(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || !keys[0].Equal(&key.PublicKey) {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+}
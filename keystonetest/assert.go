@@ -0,0 +1,80 @@
+package keystonetest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/databus23/keystone"
+)
+
+// WithToken returns a copy of r authenticated with token as if it passed keystone.Auth.Handler.
+// The identity headers are set and the token context is available via keystone.TokenFromContext.
+// This is useful for testing handlers behind the middleware without a Keystone:
+//
+//	token := &keystone.Token{Project: &keystone.Project{ID: "p-1"}}
+//	handler.ServeHTTP(rec, keystonetest.WithToken(httptest.NewRequest("GET", "/", nil), token))
+func WithToken(r *http.Request, token *keystone.Token) *http.Request {
+	r = r.WithContext(keystone.NewContext(r.Context(), token))
+	r.Header = r.Header.Clone()
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	token.SetHeaders(r.Header)
+	return r
+}
+
+// AssertConfirmed fails the test unless r was authenticated by the middleware
+func AssertConfirmed(t testing.TB, r *http.Request) {
+	t.Helper()
+	assertHeader(t, r, "X-Identity-Status", "Confirmed")
+}
+
+// AssertInvalid fails the test unless r passed the middleware without a valid token
+func AssertInvalid(t testing.TB, r *http.Request) {
+	t.Helper()
+	assertHeader(t, r, "X-Identity-Status", "Invalid")
+}
+
+// AssertUser fails the test unless r was authenticated as the user
+func AssertUser(t testing.TB, r *http.Request, userID string) {
+	t.Helper()
+	AssertConfirmed(t, r)
+	assertHeader(t, r, "X-User-Id", userID)
+}
+
+// AssertProject fails the test unless r was authenticated with a token scoped to the project
+func AssertProject(t testing.TB, r *http.Request, projectID string) {
+	t.Helper()
+	AssertConfirmed(t, r)
+	assertHeader(t, r, "X-Project-Id", projectID)
+}
+
+// AssertDomain fails the test unless r was authenticated with a token scoped to the domain
+func AssertDomain(t testing.TB, r *http.Request, domainID string) {
+	t.Helper()
+	AssertConfirmed(t, r)
+	assertHeader(t, r, "X-Domain-Id", domainID)
+}
+
+// AssertRoles fails the test unless r was authenticated with a token having all of the roles
+func AssertRoles(t testing.TB, r *http.Request, roles ...string) {
+	t.Helper()
+	AssertConfirmed(t, r)
+	have := map[string]bool{}
+	for _, role := range strings.Split(r.Header.Get("X-Roles"), ",") {
+		have[role] = true
+	}
+	for _, role := range roles {
+		if !have[role] {
+			t.Errorf("Expected role %s, got X-Roles %q", role, r.Header.Get("X-Roles"))
+		}
+	}
+}
+
+func assertHeader(t testing.TB, r *http.Request, name, expected string) {
+	t.Helper()
+	if v := r.Header.Get(name); v != expected {
+		t.Errorf("Expected %s %q, got %q", name, expected, v)
+	}
+}
@@ -0,0 +1,63 @@
+package keystonetest
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/databus23/keystone"
+)
+
+// recordingT records failures instead of failing the test
+type recordingT struct {
+	testing.TB
+	failures []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	token := &keystone.Token{Project: &keystone.Project{ID: "p-1"}}
+	token.User.ID = "u-1"
+	token.Roles = append(token.Roles, struct {
+		ID   string
+		Name string
+	}{"r-1", "member"}, struct {
+		ID   string
+		Name string
+	}{"r-2", "reader"})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Identity-Status", "Invalid")
+	r := WithToken(req, token)
+	if req.Header.Get("X-Identity-Status") != "Invalid" {
+		t.Error("Expected original request to be unmodified")
+	}
+	if ctx, ok := keystone.TokenFromContext(r.Context()); !ok || ctx != token {
+		t.Error("Expected token context in request")
+	}
+
+	passing := &recordingT{}
+	AssertConfirmed(passing, r)
+	AssertUser(passing, r, "u-1")
+	AssertProject(passing, r, "p-1")
+	AssertRoles(passing, r, "reader", "member")
+	AssertInvalid(passing, req)
+	if len(passing.failures) != 0 {
+		t.Errorf("Unexpected failures %v", passing.failures)
+	}
+
+	failing := &recordingT{}
+	AssertConfirmed(failing, req)
+	AssertInvalid(failing, r)
+	AssertUser(failing, r, "u-2")
+	AssertProject(failing, r, "p-2")
+	AssertDomain(failing, r, "d-1")
+	AssertRoles(failing, r, "admin")
+	if len(failing.failures) != 6 {
+		t.Errorf("Expected 6 failures, got %v", failing.failures)
+	}
+}
@@ -0,0 +1,184 @@
+// Package keystonetest provides a fake Keystone (see Server), a record/replay transport for deterministic
+// integration tests of services using https://github.com/databus23/keystone and assertions for the identity
+// headers set by the middleware (see AssertConfirmed and WithToken).
+//
+// In record mode the responses of a real Keystone are written to a cassette file. Once recorded,
+// tests replay the cassette without a Keystone:
+//
+//	var record = flag.Bool("record", false, "record Keystone responses")
+//
+//	func TestService(t *testing.T) {
+//		mode := keystonetest.Replay
+//		if *record {
+//			mode = keystonetest.Record
+//		}
+//		rec, err := keystonetest.NewRecorder("testdata/keystone.json", mode, nil)
+//		...
+//		defer rec.Save()
+//		auth := keystone.New("https://keystone.example.com:5000/v3")
+//		auth.Client = &http.Client{Transport: rec}
+//	}
+//
+// Requests are matched by method, path, query and X-Subject-Token. Tokens are only stored as hashes,
+// but the recorded token contexts may still contain sensitive details of the identities used.
+package keystonetest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Mode selects whether a Recorder records or replays responses
+type Mode int
+
+const (
+	//Replay responses from the cassette, requests without a recorded response fail
+	Replay Mode = iota
+	//Forward requests to Keystone and record the responses
+	Record
+)
+
+// Interaction is a recorded request together with its response
+type Interaction struct {
+	Method string `json:"method"`
+	//Path and query of the request
+	URL string `json:"url"`
+	//SHA-256 hash of the X-Subject-Token header
+	SubjectToken string      `json:"subject_token,omitempty"`
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header"`
+	Body         string      `json:"body"`
+}
+
+// Recorder is a http.RoundTripper recording or replaying Keystone responses
+type Recorder struct {
+	file string
+	mode Mode
+	base http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+	//number of times each recorded interaction was replayed
+	replayed map[string]int
+}
+
+// NewRecorder returns a Recorder for the given cassette file.
+// In Replay mode the file is read, in Record mode requests are sent using base (http.DefaultTransport if nil).
+func NewRecorder(file string, mode Mode, base http.RoundTripper) (*Recorder, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	r := &Recorder{file: file, mode: mode, base: base, replayed: map[string]int{}}
+	if mode == Replay {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &r.interactions); err != nil {
+			return nil, fmt.Errorf("Invalid cassette %s: %w", file, err)
+		}
+	}
+	return r, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.mode == Record {
+		return r.record(req)
+	}
+	return r.replay(req)
+}
+
+func (r *Recorder) record(req *http.Request) (*http.Response, error) {
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	i := newInteraction(req)
+	i.Status = resp.StatusCode
+	i.Header = resp.Header.Clone()
+	i.Body = string(body)
+	r.mu.Lock()
+	r.interactions = append(r.interactions, i)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// replay answers a request with the matching recorded interactions in the order they were recorded.
+// Once all of them were replayed, the last one is repeated.
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	want := newInteraction(req)
+	key := want.key()
+
+	r.mu.Lock()
+	var matches []Interaction
+	for _, i := range r.interactions {
+		if i.key() == key {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("No recorded interaction for %s %s", want.Method, want.URL)
+	}
+	n := r.replayed[key]
+	r.replayed[key]++
+	r.mu.Unlock()
+	if n >= len(matches) {
+		n = len(matches) - 1
+	}
+
+	i := matches[n]
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        i.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewBufferString(i.Body)),
+		ContentLength: int64(len(i.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Save writes the recorded interactions to the cassette file. It does nothing in Replay mode.
+func (r *Recorder) Save() error {
+	if r.mode != Record {
+		return nil
+	}
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.file, data, 0600)
+}
+
+func newInteraction(req *http.Request) Interaction {
+	i := Interaction{Method: req.Method, URL: req.URL.RequestURI()}
+	if token := req.Header.Get("X-Subject-Token"); token != "" {
+		sum := sha256.Sum256([]byte(token))
+		i.SubjectToken = hex.EncodeToString(sum[:])
+	}
+	return i
+}
+
+func (i Interaction) key() string {
+	return i.Method + " " + i.URL + " " + i.SubjectToken
+}
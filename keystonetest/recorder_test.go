@@ -0,0 +1,72 @@
+package keystonetest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/databus23/keystone"
+)
+
+func TestRecordReplay(t *testing.T) {
+	var requests atomic.Int32
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("X-Subject-Token") != "valid" {
+			w.WriteHeader(404)
+			return
+		}
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u-1", "name": "user"}}}`)
+	}))
+	cassette := filepath.Join(t.TempDir(), "keystone.json")
+
+	rec, err := NewRecorder(cassette, Record, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := keystone.New(idServer.URL)
+	auth.Client = &http.Client{Transport: rec}
+	if _, err := auth.Validate("valid"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Validate("invalid"); err == nil {
+		t.Fatal("Expected invalid token to be rejected")
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	idServer.Close()
+
+	data, _ := os.ReadFile(cassette)
+	if strings.Contains(string(data), "valid\"") {
+		t.Errorf("Expected cassette to only contain token hashes: %s", data)
+	}
+
+	rec, err = NewRecorder(cassette, Replay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth = keystone.New(idServer.URL)
+	auth.Client = &http.Client{Transport: rec}
+	token, err := auth.Validate("valid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.User.ID != "u-1" {
+		t.Errorf("Unexpected token context: %+v", token)
+	}
+	if _, err := auth.Validate("invalid"); err == nil {
+		t.Error("Expected invalid token to be rejected on replay")
+	}
+	if _, err := auth.Validate("unknown"); err == nil || !strings.Contains(err.Error(), "No recorded interaction") {
+		t.Errorf("Expected unrecorded request to fail, got %v", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected only the recorded requests to reach Keystone, got %d", n)
+	}
+}
@@ -0,0 +1,134 @@
+package keystonetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/databus23/keystone"
+)
+
+// Server is a fake Keystone validating the tokens registered with AddToken, replacing hand written
+// JSON fixtures in tests of services using the middleware:
+//
+//	ks := keystonetest.NewServer()
+//	defer ks.Close()
+//	ks.AddToken("user-token", keystonetest.NewToken("u-1", "p-1", "member"))
+//	handler := ks.Auth().Handler(myApp)
+//	handler.ServeHTTP(rec, ks.Request("GET", "/v1/things", "user-token"))
+//
+// Unknown tokens are answered with 404 like Keystone does. Failures and slow responses
+// can be simulated with SetStatus and SetLatency.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	tokens   map[string]*keystone.Token
+	status   int
+	latency  time.Duration
+	requests int
+}
+
+// NewServer starts a fake Keystone, it must be closed once the test is done
+func NewServer() *Server {
+	s := &Server{tokens: map[string]*keystone.Token{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// NewToken returns a token of the user scoped to the project (unscoped if projectID is empty)
+// with the given role names, expiring in an hour. All entities are enabled.
+func NewToken(userID, projectID string, roles ...string) *keystone.Token {
+	t := &keystone.Token{IssuedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+	t.User.ID = userID
+	t.User.Name = userID
+	t.User.Enabled = true
+	t.User.Domain.ID = "default"
+	t.User.Domain.Name = "Default"
+	t.User.Domain.Enabled = true
+	if projectID != "" {
+		t.Project = &keystone.Project{ID: projectID, Name: projectID, Enabled: true,
+			Domain: keystone.Domain{ID: "default", Name: "Default", Enabled: true}}
+	}
+	for _, role := range roles {
+		t.Roles = append(t.Roles, struct {
+			ID   string
+			Name string
+		}{role, role})
+	}
+	return t
+}
+
+// AddToken registers the token context returned when authToken is validated.
+// The enabled flags are returned as set, use NewToken to get a token of enabled entities.
+func (s *Server) AddToken(authToken string, token *keystone.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[authToken] = token
+}
+
+// RevokeToken removes a token, subsequent validations of it fail with 404
+func (s *Server) RevokeToken(authToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, authToken)
+}
+
+// SetStatus answers all validations with the given status code, e.g. 401 or 503. 0 restores normal operation.
+func (s *Server) SetStatus(code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = code
+}
+
+// SetLatency delays all responses
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Requests returns the number of requests received
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Auth returns a middleware validating tokens against the server
+func (s *Server) Auth() *keystone.Auth {
+	return keystone.New(s.URL)
+}
+
+// Request returns a request carrying authToken in the X-Auth-Token header
+func (s *Server) Request(method, target, authToken string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set("X-Auth-Token", authToken)
+	return r
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	status, latency := s.status, s.latency
+	token := s.tokens[r.Header.Get("X-Subject-Token")]
+	s.mu.Unlock()
+
+	time.Sleep(latency)
+	switch {
+	case r.URL.Path != "/auth/tokens" || r.Method != http.MethodGet && r.Method != http.MethodHead:
+		http.NotFound(w, r)
+	case status != 0:
+		http.Error(w, http.StatusText(status), status)
+	case r.Header.Get("X-Auth-Token") == "":
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	case token == nil:
+		http.NotFound(w, r)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Subject-Token", r.Header.Get("X-Subject-Token"))
+		json.NewEncoder(w).Encode(map[string]*keystone.Token{"token": token})
+	}
+}
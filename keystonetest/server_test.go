@@ -0,0 +1,55 @@
+package keystonetest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/databus23/keystone"
+)
+
+func TestServer(t *testing.T) {
+	ks := NewServer()
+	defer ks.Close()
+	ks.AddToken("user-token", NewToken("u-1", "p-1", "member", "reader"))
+
+	auth := ks.Auth()
+	auth.RejectDisabled = true
+	var seen *http.Request
+	handler := auth.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = r }))
+
+	handler.ServeHTTP(httptest.NewRecorder(), ks.Request("GET", "/", "user-token"))
+	AssertUser(t, seen, "u-1")
+	AssertProject(t, seen, "p-1")
+	AssertRoles(t, seen, "member", "reader")
+
+	handler.ServeHTTP(httptest.NewRecorder(), ks.Request("GET", "/", "unknown"))
+	AssertInvalid(t, seen)
+
+	ks.RevokeToken("user-token")
+	if _, err := auth.Validate("user-token"); err == nil {
+		t.Error("Expected revoked token to be rejected")
+	}
+
+	ks.AddToken("user-token", NewToken("u-1", ""))
+	ks.SetStatus(http.StatusServiceUnavailable)
+	var kerr *keystone.Error
+	if _, err := auth.Validate("user-token"); !errors.As(err, &kerr) || kerr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 error, got %v", err)
+	}
+	ks.SetStatus(0)
+	ks.SetLatency(20 * time.Millisecond)
+	start := time.Now()
+	token, err := auth.Validate("user-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Project != nil || time.Since(start) < 20*time.Millisecond {
+		t.Errorf("Expected slow unscoped token, got %+v after %s", token, time.Since(start))
+	}
+	if n := ks.Requests(); n != 5 {
+		t.Errorf("Expected 5 requests, got %d", n)
+	}
+}
@@ -0,0 +1,71 @@
+package keystone
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyWindow is the number of Keystone requests LatencyStats are computed from
+const latencyWindow = 1000
+
+// LatencyStats describes the latency of the most recent validation requests sent to Keystone
+type LatencyStats struct {
+	//Number of requests the stats are based on, at most the last 1000
+	Samples int
+	P50     time.Duration
+	P95     time.Duration
+	P99     time.Duration
+	//Share of requests which failed with a network error or a 5xx response
+	ErrorRate float64
+}
+
+type latencySample struct {
+	duration time.Duration
+	failed   bool
+}
+
+// latencyRing keeps the samples of the last latencyWindow requests
+type latencyRing struct {
+	mu      sync.Mutex
+	samples []latencySample
+	next    int
+}
+
+func (l *latencyRing) record(d time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.samples) < latencyWindow {
+		l.samples = append(l.samples, latencySample{d, failed})
+		return
+	}
+	l.samples[l.next] = latencySample{d, failed}
+	l.next = (l.next + 1) % latencyWindow
+}
+
+// LatencyStats returns latency percentiles and the error rate of the last validation requests sent to Keystone.
+// Tokens served from the cache aren't included. This is cheap enough to be called from health endpoints.
+func (a *Auth) LatencyStats() LatencyStats {
+	a.latency.mu.Lock()
+	durations := make([]time.Duration, len(a.latency.samples))
+	failed := 0
+	for i, s := range a.latency.samples {
+		durations[i] = s.duration
+		if s.failed {
+			failed++
+		}
+	}
+	a.latency.mu.Unlock()
+
+	stats := LatencyStats{Samples: len(durations)}
+	if len(durations) == 0 {
+		return stats
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	percentile := func(p int) time.Duration {
+		return durations[(len(durations)-1)*p/100]
+	}
+	stats.P50, stats.P95, stats.P99 = percentile(50), percentile(95), percentile(99)
+	stats.ErrorRate = float64(failed) / float64(len(durations))
+	return stats
+}
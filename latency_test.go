@@ -0,0 +1,47 @@
+package keystone
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLatencyStats(t *testing.T) {
+	a := &Auth{}
+	if s := a.LatencyStats(); s.Samples != 0 || s.P99 != 0 {
+		t.Errorf("Expected empty stats, got %+v", s)
+	}
+	for i := 1; i <= 2*latencyWindow; i++ {
+		//the first window is overwritten by the second one
+		d := time.Hour
+		if i > latencyWindow {
+			d = time.Duration(i-latencyWindow) * time.Millisecond
+		}
+		a.latency.record(d, i%10 == 0)
+	}
+	s := a.LatencyStats()
+	if s.Samples != latencyWindow {
+		t.Errorf("Expected %d samples, got %d", latencyWindow, s.Samples)
+	}
+	if s.P50 != 500*time.Millisecond || s.P95 != 950*time.Millisecond || s.P99 != 990*time.Millisecond {
+		t.Errorf("Unexpected percentiles: %+v", s)
+	}
+	if s.ErrorRate != 0.1 {
+		t.Errorf("Expected error rate of 0.1, got %f", s.ErrorRate)
+	}
+}
+
+func TestLatencyStatsRecorded(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z"}}`)
+	defer idServer.Close()
+	failing := httptest.NewServer(nil)
+	failing.Close()
+
+	a := New(idServer.URL)
+	a.Validate("1234")
+	a.Endpoint = failing.URL
+	a.Validate("1234")
+	if s := a.LatencyStats(); s.Samples != 2 || s.ErrorRate != 0.5 {
+		t.Errorf("Unexpected stats: %+v", s)
+	}
+}
@@ -0,0 +1,88 @@
+package keystone
+
+import (
+	"context"
+	"time"
+)
+
+// Loader validates a token and returns its token context together with the time it may be cached.
+// It is called with the cache key the loading cache was asked for.
+type Loader func(key string) (*Token, time.Duration, error)
+
+// LoadingCache is a read-through token cache.
+// Instead of the middleware reading and writing the cache, the cache is handed the loader
+// and decides itself when to call it. This allows implementations to control stampedes,
+// refresh entries ahead of their expiry or use alternate loading strategies.
+type LoadingCache interface {
+	//Get returns the token context for the token identified by key, calling load if it is not cached.
+	//It reports whether the token context was served from the cache, callers waiting for a load
+	//of the same token by another caller got it from the loader.
+	//The key is a hash of the token unless Auth.RawCacheKeys is set.
+	Get(key string, load Loader) (token *Token, cached bool, err error)
+}
+
+type loadingCache struct {
+	cache        Cache
+	refreshAhead time.Duration
+	flights      flightGroup
+}
+
+type loadedToken struct {
+	payloadHeader
+	Token   Token
+	Expires time.Time
+}
+
+// NewLoadingCache returns a LoadingCache storing tokens in c. It implements Deleter.
+// Concurrent loads of the same token are coalesced into a single call to the loader.
+// Entries are served for the time returned by the loader, which takes care of the token's expiry.
+// If refreshAhead is positive entries expiring within that period are served from the cache
+// while being reloaded in the background.
+func NewLoadingCache(c Cache, refreshAhead time.Duration) LoadingCache {
+	return &loadingCache{cache: c, refreshAhead: refreshAhead}
+}
+
+func (l *loadingCache) Get(key string, load Loader) (*Token, bool, error) {
+	var entry loadedToken
+	//the loader limited the entry's lifetime to the token's expiry, tolerating Auth.ClockSkew
+	if l.cache.Get(key, &entry) && entry.check(key, "loaded_token") && time.Now().Before(entry.Expires) {
+		if l.refreshAhead > 0 && time.Until(entry.Expires) < l.refreshAhead {
+			go l.load(key, load)
+		}
+		return &entry.Token, true, nil
+	}
+	token, err := l.load(key, load)
+	return token, false, err
+}
+
+// Delete removes an entry if the underlying cache implements Deleter
+func (l *loadingCache) Delete(key string) {
+	if d, ok := l.cache.(Deleter); ok {
+		d.Delete(key)
+	}
+}
+
+func (l *loadingCache) Flush() {
+	if f, ok := l.cache.(Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Ping checks the underlying cache if it implements Pinger
+func (l *loadingCache) Ping(ctx context.Context) error {
+	if p, ok := l.cache.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (l *loadingCache) load(key string, load Loader) (*Token, error) {
+	token, _, err := l.flights.do(key, func(key string) (*Token, time.Duration, error) {
+		token, ttl, err := load(key)
+		if err == nil && ttl > 0 {
+			l.cache.Set(key, newLoadedToken(token, ttl), ttl)
+		}
+		return token, ttl, err
+	})
+	return token, err
+}
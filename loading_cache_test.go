@@ -0,0 +1,99 @@
+package keystone
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type syncCacheMock struct {
+	sync.Mutex
+	cacheMock
+}
+
+func (c *syncCacheMock) Get(k string, v interface{}) bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.cacheMock.Get(k, v)
+}
+
+func (c *syncCacheMock) Set(k string, v interface{}, ttl time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.cacheMock.Set(k, v, ttl)
+}
+
+func TestLoadingCache(t *testing.T) {
+	var loads atomic.Int32
+	loader := func(authToken string) (*Token, time.Duration, error) {
+		loads.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return &Token{ExpiresAt: time.Now().Add(time.Hour), IssuedAt: time.Now()}, 100 * time.Millisecond, nil
+	}
+	c := NewLoadingCache(&syncCacheMock{cacheMock: cacheMock{}}, 80*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, cached, err := c.Get("1234", loader); err != nil || cached {
+				t.Errorf("Expected token to be loaded, got cached: %t, err: %v", cached, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("Expected concurrent gets to load once, got %d loads", n)
+	}
+
+	//entry is now within the refresh ahead window, so it is served and reloaded in the background
+	time.Sleep(30 * time.Millisecond)
+	if _, cached, err := c.Get("1234", loader); err != nil || !cached {
+		t.Fatalf("Expected token to be served from the cache, got cached: %t, err: %v", cached, err)
+	}
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("Expected cached value to be served while refreshing, got %d loads", n)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := loads.Load(); n != 2 {
+		t.Fatalf("Expected token to be refreshed in the background, got %d loads", n)
+	}
+}
+
+func TestAuthLoadingCache(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z"}}`)
+	defer idServer.Close()
+
+	cache := &syncCacheMock{cacheMock: cacheMock{}}
+	a := New(idServer.URL)
+	a.LoadingCache = NewLoadingCache(cache, 0)
+	if _, err := a.Validate("1234"); err != nil {
+		t.Fatal(err)
+	}
+	idServer.Close()
+	if _, err := a.Validate("1234"); err != nil {
+		t.Fatal("Expected token to be served from cache: ", err)
+	}
+}
+
+func TestLoadingCacheClockSkew(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "`+time.Now().Add(-10*time.Second).UTC().Format(time.RFC3339)+`", "issued_at": "2015-10-08T07:40:33.099Z"}}`)
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.ClockSkew = time.Minute
+	a.LoadingCache = NewLoadingCache(&syncCacheMock{cacheMock: cacheMock{}}, 0)
+	if _, err := a.Validate("1234"); err != nil {
+		t.Fatal(err)
+	}
+	idServer.Close()
+	//the token expired according to the local clock, but not considering ClockSkew
+	if _, err := a.Validate("1234"); err != nil {
+		t.Fatal("Expected token to be served from cache: ", err)
+	}
+	if stats := a.CacheStats(); stats.Hits != 1 {
+		t.Errorf("Expected 1 cache hit, got %+v", stats)
+	}
+}
@@ -0,0 +1,30 @@
+package keystone
+
+import "errors"
+
+// ErrNotLocal is returned by a LocalValidator for tokens it can't validate, e.g. tokens of another format.
+// Such tokens are validated against Keystone.
+var ErrNotLocal = errors.New("Token can't be validated locally")
+
+// LocalValidator validates tokens without a round trip to Keystone, see Auth.LocalValidator and JWSValidator.
+type LocalValidator interface {
+	//ValidateLocal verifies a token and returns its token context. Token contexts which aren't complete
+	//(e.g. lacking names and roles) are expanded by validating the token against Keystone.
+	//Tokens failing local validation are rejected without contacting Keystone.
+	ValidateLocal(authToken string) (token *Token, complete bool, err error)
+}
+
+// validateLocal validates a token with the LocalValidator. It reports if the token was handled.
+func (a *Auth) validateLocal(authToken string) (*Token, bool, error) {
+	if a.LocalValidator == nil {
+		return nil, false, nil
+	}
+	token, complete, err := a.LocalValidator.ValidateLocal(authToken)
+	switch {
+	case errors.Is(err, ErrNotLocal):
+		return nil, false, nil
+	case err != nil:
+		return nil, true, err
+	}
+	return token, complete, nil
+}
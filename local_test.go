@@ -0,0 +1,44 @@
+package keystone
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type localValidatorFunc func(string) (*Token, bool, error)
+
+func (f localValidatorFunc) ValidateLocal(authToken string) (*Token, bool, error) {
+	return f(authToken)
+}
+
+func TestLocalValidator(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "online"}}}`)
+	defer idServer.Close()
+
+	errLocal := errors.New("rejected")
+	a := New(idServer.URL)
+	a.LocalValidator = localValidatorFunc(func(authToken string) (*Token, bool, error) {
+		switch authToken {
+		case "local":
+			token := &Token{ExpiresAt: time.Now().Add(time.Hour)}
+			token.User.ID = "local"
+			return token, true, nil
+		case "forged":
+			return nil, false, errLocal
+		}
+		return nil, false, ErrNotLocal
+	})
+	for authToken, expected := range map[string]string{"local": "local", "other": "online"} {
+		token, err := a.Validate(authToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.User.ID != expected {
+			t.Errorf("Expected token %s to be validated by %s, got %s", authToken, expected, token.User.ID)
+		}
+	}
+	if _, err := a.Validate("forged"); err != errLocal {
+		t.Errorf("Expected local validation error, got %v", err)
+	}
+}
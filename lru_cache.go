@@ -0,0 +1,160 @@
+package keystone
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// InMemoryCache is a concurrency safe in-memory cache with a bounded number of entries.
+// When full, the least recently used entry is evicted. Expired entries are removed lazily.
+// It implements Cache, Deleter, Flusher and EvictionNotifier.
+//
+//	auth := keystone.New("https://keystone:5000/v3")
+//	auth.TokenCache = keystone.NewInMemoryCache(10000)
+type InMemoryCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	onEvict func(string, EvictReason)
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+type eviction struct {
+	key    string
+	reason EvictReason
+}
+
+// NewInMemoryCache returns an in-memory cache holding up to maxEntries entries.
+// A maxEntries of 0 or less means the cache is unbounded.
+func NewInMemoryCache(maxEntries int) *InMemoryCache {
+	return &InMemoryCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// Set stores a value with the given ttl
+func (c *InMemoryCache) Set(key string, value interface{}, ttl time.Duration) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	var evicted []eviction
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		entry := e.Value.(*lruEntry)
+		entry.value = b
+		entry.expires = now.Add(ttl)
+		c.lru.MoveToFront(e)
+	} else {
+		c.entries[key] = c.lru.PushFront(&lruEntry{key: key, value: b, expires: now.Add(ttl)})
+		for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+			oldest := c.lru.Back().Value.(*lruEntry)
+			reason := EvictCapacity
+			if !now.Before(oldest.expires) {
+				reason = EvictExpired
+			}
+			c.remove(oldest.key)
+			evicted = append(evicted, eviction{oldest.key, reason})
+		}
+	}
+	f := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(f, evicted)
+}
+
+// Get retrieves a value previously stored in the cache
+func (c *InMemoryCache) Get(key string, value interface{}) bool {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		c.mu.Unlock()
+		return false
+	}
+	entry := e.Value.(*lruEntry)
+	if !time.Now().Before(entry.expires) {
+		c.remove(key)
+		f := c.onEvict
+		c.mu.Unlock()
+		c.notify(f, []eviction{{key, EvictExpired}})
+		return false
+	}
+	c.lru.MoveToFront(e)
+	b := entry.value
+	c.mu.Unlock()
+
+	return json.Unmarshal(b, value) == nil
+}
+
+// Delete removes the entry for key from the cache
+func (c *InMemoryCache) Delete(key string) {
+	c.mu.Lock()
+	_, ok := c.entries[key]
+	if ok {
+		c.remove(key)
+	}
+	f := c.onEvict
+	c.mu.Unlock()
+	if ok {
+		c.notify(f, []eviction{{key, EvictInvalidated}})
+	}
+}
+
+// Flush removes all entries from the cache
+func (c *InMemoryCache) Flush() {
+	c.mu.Lock()
+	evicted := make([]eviction, 0, len(c.entries))
+	for key := range c.entries {
+		evicted = append(evicted, eviction{key, EvictInvalidated})
+	}
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	f := c.onEvict
+	c.mu.Unlock()
+
+	c.notify(f, evicted)
+}
+
+// Len returns the number of entries in the cache including expired ones not removed yet
+func (c *InMemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// OnEvict registers a function called whenever an entry is removed from the cache, see EvictionNotifier
+func (c *InMemoryCache) OnEvict(f func(key string, reason EvictReason)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvict = f
+}
+
+// remove deletes an entry. Must be called with c.mu held.
+func (c *InMemoryCache) remove(key string) {
+	if e, ok := c.entries[key]; ok {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+	}
+}
+
+func (c *InMemoryCache) notify(f func(string, EvictReason), evicted []eviction) {
+	if f == nil {
+		return
+	}
+	for _, e := range evicted {
+		f(e.key, e.reason)
+	}
+}
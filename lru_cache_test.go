@@ -0,0 +1,73 @@
+package keystone
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInMemoryCache(t *testing.T) {
+	c := NewInMemoryCache(2)
+	evictions := map[string]EvictReason{}
+	c.OnEvict(func(key string, reason EvictReason) { evictions[key] = reason })
+
+	c.Set("a", Token{Issuer: "a"}, time.Minute)
+	c.Set("b", Token{Issuer: "b"}, time.Minute)
+
+	var token Token
+	if !c.Get("a", &token) || token.Issuer != "a" {
+		t.Fatalf("Expected to find a, got %+v", token)
+	}
+	//b is now the least recently used entry
+	c.Set("c", Token{Issuer: "c"}, time.Minute)
+	if c.Get("b", &token) {
+		t.Error("Expected b to be evicted")
+	}
+	if evictions["b"] != EvictCapacity {
+		t.Errorf("Expected b to be evicted for capacity, got %s", evictions["b"])
+	}
+	if !c.Get("a", &token) || !c.Get("c", &token) {
+		t.Error("Expected a and c to be cached")
+	}
+
+	c.Set("d", Token{}, -time.Second)
+	if c.Get("d", &token) {
+		t.Error("Expected expired entry not to be returned")
+	}
+	if evictions["d"] != EvictExpired {
+		t.Errorf("Expected d to be expired, got %s", evictions["d"])
+	}
+
+	c.Delete("c")
+	if c.Get("c", &token) || evictions["c"] != EvictInvalidated {
+		t.Error("Expected c to be deleted")
+	}
+
+	c.Set("e", Token{}, time.Minute)
+	c.Flush()
+	if c.Len() != 0 || c.Get("e", &token) || evictions["e"] != EvictInvalidated {
+		t.Error("Expected e to be flushed")
+	}
+}
+
+func TestInMemoryCacheConcurrency(t *testing.T) {
+	c := NewInMemoryCache(10)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := strconv.Itoa((i * j) % 20)
+				c.Set(key, j, time.Minute)
+				var v int
+				c.Get(key, &v)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if c.Len() > 10 {
+		t.Errorf("Expected at most 10 entries, got %d", c.Len())
+	}
+}
@@ -0,0 +1,46 @@
+package keystone
+
+import "time"
+
+// Validation outcomes reported to Metrics
+const (
+	OutcomeConfirmed = "confirmed"
+	OutcomeInvalid   = "invalid"
+	OutcomeError     = "error"
+)
+
+// Metrics receives measurements of the middleware's operation.
+// See the metrics/prometheus package for an implementation exposing them as prometheus metrics.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	//ValidationStarted is called when the validation of a token starts
+	ValidationStarted()
+	//ValidationDone is called with the outcome (OutcomeConfirmed, OutcomeInvalid or OutcomeError)
+	//when the validation of a token finished. issuer is the endpoint which validated confirmed tokens
+	//(see Token.Issuer and Auth.SecondaryEndpoint), it is empty for other outcomes.
+	ValidationDone(outcome, issuer string)
+	//KeystoneRequest is called for each validation request sent to Keystone.
+	//status is 0 if no response was received.
+	KeystoneRequest(endpoint string, status int, latency time.Duration)
+	//CacheLookup is called for each token cache lookup
+	CacheLookup(hit bool)
+}
+
+// validationIssuer returns the issuer reported to Metrics for a validated token
+func validationIssuer(token *Token) string {
+	if token == nil {
+		return ""
+	}
+	return token.Issuer
+}
+
+// validationOutcome classifies the result of a token validation
+func validationOutcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeConfirmed
+	case IsUnavailable(err):
+		return OutcomeError
+	}
+	return OutcomeInvalid
+}
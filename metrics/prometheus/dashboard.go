@@ -0,0 +1,150 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"net/http"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Grafana dashboard model, only containing the fields used by Dashboard
+type dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Refresh       string     `json:"refresh"`
+	Time          timeRange  `json:"time"`
+	Templating    templating `json:"templating"`
+	Panels        []panel    `json:"panels"`
+}
+
+type timeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type templating struct {
+	List []variable `json:"list"`
+}
+
+type variable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+type panel struct {
+	ID          int         `json:"id"`
+	Type        string      `json:"type"`
+	Title       string      `json:"title"`
+	Description string      `json:"description,omitempty"`
+	GridPos     gridPos     `json:"gridPos"`
+	Datasource  datasource  `json:"datasource"`
+	FieldConfig fieldConfig `json:"fieldConfig"`
+	Targets     []target    `json:"targets"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type fieldConfig struct {
+	Defaults struct {
+		Unit string `json:"unit"`
+	} `json:"defaults"`
+}
+
+type target struct {
+	RefID        string     `json:"refId"`
+	Datasource   datasource `json:"datasource"`
+	Expr         string     `json:"expr"`
+	LegendFormat string     `json:"legendFormat"`
+}
+
+// query is a prometheus query shown in a panel
+type query struct {
+	expr, legend string
+}
+
+// Dashboard returns a Grafana dashboard in JSON format for the metrics exported by Metrics.
+// The prometheus data source is chosen using the dashboard's datasource variable.
+// The dashboard can be imported into Grafana or provisioned from a file:
+//
+//	os.WriteFile("/etc/grafana/dashboards/keystone.json", prometheus.Dashboard(), 0644)
+func Dashboard() []byte {
+	var (
+		validations = prom.BuildFQName(namespace, "", validationsName)
+		inFlight    = prom.BuildFQName(namespace, "", inFlightName)
+		latency     = prom.BuildFQName(namespace, "", keystoneLatencyName)
+		lookups     = prom.BuildFQName(namespace, "", cacheLookupsName)
+	)
+	d := dashboard{
+		UID:           "keystone-middleware",
+		Title:         "Keystone middleware",
+		Tags:          []string{"keystone"},
+		SchemaVersion: 39,
+		Refresh:       "1m",
+		Time:          timeRange{From: "now-6h", To: "now"},
+		Templating:    templating{List: []variable{{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"}}},
+	}
+	d.add("Token validations", "Token validations per second by outcome", "reqps",
+		query{"sum by (outcome) (rate(" + validations + "[$__rate_interval]))", "{{outcome}}"})
+	d.add("Validations in flight", "Token validations currently in progress", "short",
+		query{"sum(" + inFlight + ")", "in flight"})
+	d.add("Keystone latency", "Latency of validation requests to Keystone", "s",
+		query{"histogram_quantile(0.5, sum by (le) (rate(" + latency + "_bucket[$__rate_interval])))", "p50"},
+		query{"histogram_quantile(0.95, sum by (le) (rate(" + latency + "_bucket[$__rate_interval])))", "p95"},
+		query{"histogram_quantile(0.99, sum by (le) (rate(" + latency + "_bucket[$__rate_interval])))", "p99"})
+	d.add("Keystone requests", "Validation requests to Keystone per second by status code (0 for failed requests)", "reqps",
+		query{"sum by (code) (rate(" + latency + "_count[$__rate_interval]))", "{{code}}"})
+	d.add("Cache hit ratio", "Share of token cache lookups which were hits", "percentunit",
+		query{"sum(rate(" + lookups + `{result="hit"}[$__rate_interval])) / sum(rate(` + lookups + "[$__rate_interval]))", "hit ratio"})
+	d.add("Cache lookups", "Token cache lookups per second by result", "ops",
+		query{"sum by (result) (rate(" + lookups + "[$__rate_interval]))", "{{result}}"})
+	d.add("Tokens by issuer", "Confirmed token validations per second by the Keystone endpoint which issued the token", "reqps",
+		query{"sum by (issuer) (rate(" + validations + `{outcome="confirmed"}[$__rate_interval]))`, "{{issuer}}"})
+
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		panic(err) //can't happen, the dashboard only consists of strings and ints
+	}
+	return data
+}
+
+// add appends a time series panel, laying out panels in two columns
+func (d *dashboard) add(title, description, unit string, queries ...query) {
+	ds := datasource{Type: "prometheus", UID: "${datasource}"}
+	n := len(d.Panels)
+	p := panel{
+		ID:          n + 1,
+		Type:        "timeseries",
+		Title:       title,
+		Description: description,
+		GridPos:     gridPos{H: 8, W: 12, X: 12 * (n % 2), Y: 8 * (n / 2)},
+		Datasource:  ds,
+	}
+	p.FieldConfig.Defaults.Unit = unit
+	for i, q := range queries {
+		p.Targets = append(p.Targets, target{RefID: string(rune('A' + i)), Datasource: ds, Expr: q.expr, LegendFormat: q.legend})
+	}
+	d.Panels = append(d.Panels, p)
+}
+
+// DashboardHandler returns a http handler serving the dashboard returned by Dashboard
+func DashboardHandler() http.Handler {
+	data := Dashboard()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+}
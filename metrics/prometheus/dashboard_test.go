@@ -0,0 +1,53 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/databus23/keystone"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+func TestDashboard(t *testing.T) {
+	var d dashboard
+	if err := json.Unmarshal(Dashboard(), &d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Panels) == 0 {
+		t.Fatal("Expected dashboard panels")
+	}
+
+	//every metric referenced by the dashboard must be exported by Metrics
+	reg := prom.NewRegistry()
+	m := New(reg)
+	m.ValidationDone(keystone.OutcomeConfirmed, "https://keystone:5000/v3")
+	m.KeystoneRequest("http://keystone", 200, 0)
+	m.CacheLookup(true)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported := map[string]bool{}
+	for _, f := range families {
+		exported[f.GetName()] = true
+	}
+	metric := regexp.MustCompile(`keystone_\w+`)
+	suffix := regexp.MustCompile(`_(bucket|count|sum)$`)
+	for _, p := range d.Panels {
+		for _, target := range p.Targets {
+			for _, name := range metric.FindAllString(target.Expr, -1) {
+				if !exported[name] && !exported[suffix.ReplaceAllString(name, "")] {
+					t.Errorf("Panel %q references unknown metric %s", p.Title, name)
+				}
+			}
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	DashboardHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/dashboard.json", nil))
+	if rec.Code != 200 || rec.Header().Get("Content-Type") != "application/json" || rec.Body.String() != string(Dashboard()) {
+		t.Errorf("Unexpected dashboard response %d %s", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,88 @@
+// Package prometheus exposes metrics of https://github.com/databus23/keystone for prometheus.
+//
+//	auth := keystone.New("https://keystone:5000/v3")
+//	auth.Metrics = prometheus.New(prom.DefaultRegisterer)
+//
+// A Grafana dashboard for the metrics is available from Dashboard and DashboardHandler.
+package prometheus
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/databus23/keystone"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Metric names, also used for generating the dashboard
+const (
+	namespace           = "keystone"
+	validationsName     = "token_validations_total"
+	inFlightName        = "token_validations_in_flight"
+	keystoneLatencyName = "request_duration_seconds"
+	cacheLookupsName    = "token_cache_lookups_total"
+)
+
+// Metrics implements keystone.Metrics
+type Metrics struct {
+	validations     *prom.CounterVec
+	inFlight        prom.Gauge
+	keystoneLatency *prom.HistogramVec
+	cacheLookups    *prom.CounterVec
+}
+
+// New creates the metrics and registers them with reg. It panics if registration fails.
+func New(reg prom.Registerer) *Metrics {
+	m := &Metrics{
+		validations: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      validationsName,
+			Help:      "Number of token validations by outcome (confirmed, invalid, error) and issuer (the endpoint which confirmed the token).",
+		}, []string{"outcome", "issuer"}),
+		inFlight: prom.NewGauge(prom.GaugeOpts{
+			Namespace: namespace,
+			Name:      inFlightName,
+			Help:      "Number of token validations currently in progress.",
+		}),
+		keystoneLatency: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      keystoneLatencyName,
+			Help:      "Latency of token validation requests to Keystone by endpoint and status code (0 for failed requests).",
+			Buckets:   prom.DefBuckets,
+		}, []string{"endpoint", "code"}),
+		cacheLookups: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      cacheLookupsName,
+			Help:      "Number of token cache lookups by result (hit, miss).",
+		}, []string{"result"}),
+	}
+	reg.MustRegister(m.validations, m.inFlight, m.keystoneLatency, m.cacheLookups)
+	return m
+}
+
+// ValidationStarted implements keystone.Metrics
+func (m *Metrics) ValidationStarted() {
+	m.inFlight.Inc()
+}
+
+// ValidationDone implements keystone.Metrics
+func (m *Metrics) ValidationDone(outcome, issuer string) {
+	m.inFlight.Dec()
+	m.validations.WithLabelValues(outcome, issuer).Inc()
+}
+
+// KeystoneRequest implements keystone.Metrics
+func (m *Metrics) KeystoneRequest(endpoint string, status int, latency time.Duration) {
+	m.keystoneLatency.WithLabelValues(endpoint, strconv.Itoa(status)).Observe(latency.Seconds())
+}
+
+// CacheLookup implements keystone.Metrics
+func (m *Metrics) CacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheLookups.WithLabelValues(result).Inc()
+}
+
+var _ keystone.Metrics = &Metrics{}
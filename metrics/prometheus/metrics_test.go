@@ -0,0 +1,48 @@
+package prometheus
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/databus23/keystone"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Subject-Token") != "valid" {
+			w.WriteHeader(404)
+			return
+		}
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z"}}`)
+	}))
+	defer idServer.Close()
+
+	reg := prom.NewRegistry()
+	m := New(reg)
+	a := keystone.Auth{Endpoint: idServer.URL, TokenCache: keystone.NewInMemoryCache(10), Metrics: m}
+	a.Handler(http.NotFoundHandler())
+
+	for _, token := range []string{"valid", "valid", "invalid"} {
+		a.Validate(token)
+	}
+
+	expected := map[prom.Collector]float64{
+		m.validations.WithLabelValues(keystone.OutcomeConfirmed, idServer.URL): 2,
+		m.validations.WithLabelValues(keystone.OutcomeInvalid, ""):             1,
+		m.cacheLookups.WithLabelValues("hit"):                                  1,
+		m.cacheLookups.WithLabelValues("miss"):                                 2,
+		m.inFlight:                                                             0,
+	}
+	for c, v := range expected {
+		if got := testutil.ToFloat64(c); got != v {
+			t.Errorf("Expected %v, got %v", v, got)
+		}
+	}
+	if n := testutil.CollectAndCount(m.keystoneLatency); n != 2 {
+		t.Errorf("Expected latency series for status 200 and 404, got %d", n)
+	}
+}
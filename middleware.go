@@ -5,19 +5,36 @@
 //
 // The middleware authenticates incoming requests by validating the `X-Auth-Token` header
 // and adding additional headers to the incoming request containing the validation result.
-// The final authentication/authorization decision is delegated to subsequent http handlers.
+// The final authentication/authorization decision is delegated to subsequent http handlers
+// unless Auth.RejectUnauthenticated is set.
+//
+// This package only depends on the standard library. Integrations requiring third party
+// libraries (cache backends, metrics, tracing, ...) live in sub packages with their own go.mod which
+// plug into the interfaces defined here (e.g. Cache), so applications only pull in the dependencies they use.
 package keystone
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
+// ErrNoEndpoint is returned (or raised by Auth.Handler) if no Keystone endpoint is configured
+var ErrNoEndpoint = errors.New("No keystone endpoint configured")
+
+// ErrNoRoles is returned for tokens without roles if Auth.RequireRoles is set
+var ErrNoRoles = errors.New("Token has no roles")
+
 var Log func(string, ...interface{}) = func(format string, a ...interface{}) {
 	log.Printf(format, a...)
 }
@@ -32,21 +49,351 @@ type Cache interface {
 	Get(key string, value interface{}) bool
 }
 
-//Auth is the entrypoint for creating the middlware
+// Auth is the entrypoint for creating the middlware.
+//
+// An Auth is safe for concurrent use. A single instance can back the handlers of multiple http servers
+// and listeners, which then share the token cache, throttling, circuit breaker, load shedding and service
+// token state. The configuration must not be changed once Handler was called.
 type Auth struct {
 	//Keystone v3 endpoint url for validating tokens ( e.g https://some.where:5000/v3)
 	Endpoint string
+	//Additional nodes of the Keystone given by Endpoint for HA deployments (e.g https://node2.some.where:5000/v3).
+	//Validations are distributed round-robin over all nodes and fail over to the next node if a node can't be
+	//reached or answers with a 5xx response. Failed nodes are avoided for 10 seconds.
+	//Token contexts still report Endpoint as their Issuer.
+	Endpoints []string
+	//Allow running without an Endpoint. Tokens are then only accepted from the TokenCache.
+	//Without this flag Handler panics if Endpoint is empty to make misconfigurations obvious.
+	//This is mostly useful for tests.
+	OfflineMode bool
 	//User-Agent used for all http request by the middlware. Defaults to go-keystone-middlware/1.0
 	UserAgent string
+	//Keystone v3 endpoint of a second identity service used during migrations.
+	//Tokens unknown to Endpoint (404) are validated against this endpoint. See Token.Issuer.
+	SecondaryEndpoint string
+	//Credentials and scope of a service user of SecondaryEndpoint, see ServiceCredentials. Service tokens of
+	//Endpoint aren't known to SecondaryEndpoint, so without them tokens are validated against SecondaryEndpoint
+	//using themselves.
+	SecondaryServiceCredentials Credentials
+	SecondaryServiceScope       *Scope
 	//A cache implementation the middleware should use for caching tokens. By default no caching is performed.
 	TokenCache Cache
+	//Tokens are hashed with SHA-256 before being used as cache keys, so credentials don't leak into cache backends.
+	//If set, HMAC-SHA256 keyed with this secret is used instead, preventing offline guessing of tokens from keys.
+	CacheKeySecret string
+	//Use raw tokens as cache keys like earlier versions did. Not recommended.
+	RawCacheKeys bool
+	//Write to the TokenCache asynchronously using this many workers, keeping slow cache backends out of the
+	//request path. Writes are dropped if the workers can't keep up (see Stats.DroppedCacheWrites).
+	//By default the cache is written synchronously.
+	AsyncCacheWrites int
 	//How long to cache tokens. Defaults to 5 minutes.
 	CacheTime time.Duration
+	//Computes how long to cache a token instead of CacheTime, e.g. shorter for tokens with sensitive roles:
+	//
+	//	auth.TTLFunc = func(t *keystone.Token) time.Duration {
+	//		if keystone.HasRole("admin")(t) {
+	//			return 30 * time.Second
+	//		}
+	//		return 5 * time.Minute
+	//	}
+	//
+	//Tokens are not cached if it returns 0. Tokens are never cached beyond their expiry and
+	//ThrottledCacheFactor and ValidationOptions.CacheTime still apply.
+	TTLFunc func(token *Token) time.Duration
+	//Multiply CacheTime by this factor while Keystone is throttling requests or signals being overloaded
+	//(503 with Retry-After) to shed validation load. CacheTime applies again 5 minutes after the last
+	//such response. Tokens are never cached beyond their expiry. Disabled by default.
+	ThrottledCacheFactor int
+	//How long to remember tokens rejected by Keystone (401 or 404) in the TokenCache, so clients retrying with
+	//a bad token don't hammer Keystone. Disabled by default.
+	InvalidCacheTime time.Duration
+	//Tolerated deviation of the local clock from Keystone's. Tokens issued up to ClockSkew in the future are
+	//accepted and tokens are accepted and cached up to ClockSkew past their expiry. Disabled by default.
+	ClockSkew time.Duration
+	//Log a warning for tokens not being cached because they expire within a second
+	WarnShortLivedTokens bool
+	//Called when revalidating a cached token against Keystone (see RefreshAhead, StaleCacheTime and
+	//ValidationOptions.SkipCache) reveals that its roles or scope changed since it was cached.
+	//Such changes are also logged.
+	OnTokenChange func(cached, fresh *Token, diff TokenDiff)
+	//Give up on TokenCache lookups taking longer than this and validate the token against Keystone instead,
+	//so a slow networked cache doesn't make requests slower than no cache at all. Caches implementing CacheCtx
+	//are passed a context cancelled after the timeout. Disabled by default.
+	CacheTimeout time.Duration
+	//Keep waiting for cache lookups exceeding CacheTimeout while validating the token against Keystone
+	//and use whichever answers first
+	CacheTimeoutRace bool
+	//A read-through cache loading tokens itself. If set, it is used instead of TokenCache.
+	LoadingCache LoadingCache
+
+	//Logger for structured events (validation failures, cache hits and misses, Keystone latency, ...).
+	//If nil, events of level Info and above are formatted and passed to the package level Log function.
+	Logger *slog.Logger
+
+	//Receives measurements of validations, Keystone requests and cache lookups. Disabled if nil.
+	//See the metrics/prometheus package.
+	Metrics Metrics
+
+	//Traces token validations, see the tracing/otel package. Disabled if nil.
+	Tracer Tracer
 
 	//http client to use for requests, default to  &http.Client{ Timeout: 5 * time.Second }
+	//The default client honors the proxy environment variables (HTTP_PROXY, HTTPS_PROXY, NO_PROXY)
+	//and can be adjusted using Timeout and TLSConfig. Set Client for full control (e.g. connection pool sizes).
 	Client *http.Client
+	//Timeout of the default client. Defaults to 5 seconds.
+	Timeout time.Duration
+	//Maximum duration of validating a token against Keystone including retries, regardless of the client.
+	//Validations are also bound to the context of the request: once the client disconnects or the request's
+	//deadline passes the request stops waiting, and the validation is cancelled unless other requests with
+	//the same token wait for it. Unlimited if zero.
+	ValidationTimeout time.Duration
+	//TLS configuration of the default client, e.g. for trusting the CA of a Keystone with a self-signed
+	//certificate or authenticating with a client certificate:
+	//
+	//	pool := x509.NewCertPool()
+	//	pool.AppendCertsFromPEM(caBundle)
+	//	auth.TLSConfig = &tls.Config{RootCAs: pool}
+	TLSConfig *tls.Config
+
+	//Credentials of a service user authenticating the validation requests of the middleware, like the
+	//python middleware does. By default tokens are validated using themselves as X-Auth-Token which
+	//requires Keystone's policy to allow users to validate their own tokens. See RefreshServiceToken.
+	//Use ApplicationCredentials for the recommended non-interactive authentication method.
+	ServiceCredentials Credentials
+	//Scope of the service user's token. Unscoped if nil. Must be nil for ApplicationCredentials.
+	ServiceScope *Scope
+
+	//Verifies that a token is intended for this service for deployments issuing audience restricted tokens
+	//via Keystone extensions. It is called with the token context and the raw token payload returned by Keystone
+	//whenever a token is validated against Keystone, tokens it returns an error for are rejected with ErrAudience.
+	//See AudienceClaim for checking a claim of the token payload.
+	VerifyAudience func(token *Token, payload json.RawMessage) error
+
+	//Checks every request authenticated with a valid token before it is confirmed, e.g. against restrictions
+	//vendor extensions embed in the token's extras (see RestrictSourceIP). Tokens it returns an error for are
+	//treated as invalid for this request with an error wrapping ErrRestricted.
+	VerifyRequest func(req *http.Request, token *Token) error
+
+	//Validates tokens locally before or instead of validating them against Keystone, see JWSValidator.
+	LocalValidator LocalValidator
+
+	//Request the service catalog when validating tokens and pass it on JSON encoded in the X-Service-Catalog
+	//header like the python middleware does. See Token.Catalog.
+	IncludeServiceCatalog bool
+
+	//Treat tokens without any role assignment as invalid
+	RequireRoles bool
+	//Treat tokens as invalid if their user, project or domain is disabled.
+	//Entities are only considered disabled if the token payload explicitly says so.
+	RejectDisabled bool
+	//Only accept tokens whose user domain and scope domain (the project's domain or the scoped domain) are
+	//in this list of domain IDs or names, e.g. for admin APIs reserved to employees. Other tokens are rejected
+	//with ErrDomainNotAllowed. All domains are allowed if empty.
+	AllowedDomains []string
+	//Reject tokens whose user domain or scope domain is in this list of domain IDs or names
+	DeniedDomains []string
+	//Only accept tokens scoped to one of these project IDs, other tokens are rejected with ErrProjectNotAllowed.
+	//All projects are allowed if empty.
+	AllowedProjects []string
+	//Only accept tokens having any of these roles, other tokens are rejected with ErrMissingRole.
+	//Like AllowedProjects this applies to service tokens as well.
+	AcceptRoles []string
+	//Treat service tokens (X-Service-Token) as invalid unless they have any of these roles, like
+	//keystonemiddleware's service_token_roles, e.g. []string{"service"}
+	RequiredServiceRoles []string
+
+	//Retry validation requests failing because Keystone is unavailable (network errors, 5xx responses)
+	//this many times. Retries back off exponentially starting at RetryBackoff (defaults to 100ms).
+	Retries      int
+	RetryBackoff time.Duration
+
+	//Open a circuit breaker after this many consecutive validations failed because Keystone is unavailable.
+	//While open, validations fail with ErrCircuitOpen without contacting Keystone. After BreakerCooldown
+	//(defaults to 30 seconds) a single validation probes if Keystone recovered. Disabled by default.
+	//Together with RejectUnauthenticated requests are rejected with 503, see also StaleCacheTime and Fallback.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	//Revalidate cached tokens in the background if they are requested within this period before their cache
+	//entry expires. The cached token is served meanwhile, so frequently used tokens don't wait for Keystone
+	//when their entry expires. Tokens Keystone rejects are evicted. Disabled by default.
+	//See NewLoadingCache for the equivalent of LoadingCache.
+	RefreshAhead time.Duration
+
+	//Keep accepting cached tokens for this long after they were revoked (see SyncRevocations), so in-flight
+	//long operations aren't cut off abruptly. Such tokens are flagged with Token.Revoked and
+	//X-Token-Revoked: True, so handlers can decide to abort. Disabled by default.
+	RevocationGracePeriod time.Duration
+
+	//Keep tokens in the TokenCache for this long after CacheTime passed (but not beyond their expiry).
+	//Such stale tokens are revalidated against Keystone and only served if Keystone is unavailable
+	//(see IsUnavailable), similar to nginx's proxy_cache_use_stale. This keeps services available during short
+	//Keystone outages at the cost of accepting tokens revoked during the outage. Disabled by default.
+	StaleCacheTime time.Duration
+
+	//Limit the number of validations contacting Keystone concurrently, further validations wait for a slot.
+	//Unlimited by default.
+	MaxConcurrentValidations int
+
+	//Limit the rate of validations sent to Keystone to this many per second, protecting it from being
+	//overwhelmed by requests with random tokens. Validations exceeding the limit fail with ErrRateLimited,
+	//see RateLimitOverflow. ValidationBurst (defaults to the rate) validations may be sent at once. Unlimited by default.
+	ValidationRate  float64
+	ValidationBurst int
+	//Like ValidationRate, but per client address (see ClientIP). IPv6 addresses are limited per /64 prefix.
+	ValidationRatePerIP  float64
+	ValidationBurstPerIP int
+	//How requests whose validation was rate limited are answered, defaults to RateLimitUnavailable
+	RateLimitOverflow RateLimitOverflow
+	//Returns the client address of a request for ValidationRatePerIP, e.g. from X-Forwarded-For behind a
+	//trusted reverse proxy. Defaults to the host of the request's RemoteAddr.
+	ClientIP func(r *http.Request) string
+	//Shed validations with ErrOverloaded instead of waiting if this many validations are already waiting for
+	//a slot (see MaxConcurrentValidations). Shed requests are passed on as Invalid or, if a token is
+	//required, rejected with 503 and Retry-After. Use ValidationOptions.ShedQueueDepth to shed low priority
+	//classes of requests earlier (see Classes). Disabled by default.
+	ShedQueueDepth int
+
+	//Send a second validation request if Keystone didn't answer within this delay and use
+	//whichever response arrives first. This trades additional load for lower tail latency. Disabled by default.
+	HedgeDelay time.Duration
+
+	//Reject requests without a valid token with 401 Unauthorized instead of delegating the decision to
+	//subsequent handlers. Requests which couldn't be authenticated because Keystone is unavailable
+	//(see IsUnavailable) are rejected with 503 Service Unavailable.
+	RejectUnauthenticated bool
+
+	//Renders the responses of requests terminated by the middleware itself, e.g. a JSON error envelope for APIs
+	//or an HTML page for user interfaces. status is the HTTP status of the response (401, 403, 503, or for
+	//CONNECT requests 405 and 407) and err the reason, nil for requests without a token. Headers like
+	//WWW-Authenticate and Retry-After are already set. Defaults to a plain text response.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+	//WWW-Authenticate challenge for 401 responses, see SetChallenge. Defaults to Keystone uri="<Endpoint>".
+	Challenge *Challenge
+
+	//Called when the middleware fails to validate the token of a request.
+	//Errors returned by Keystone are of type *Error and carry the status and error details of the response.
+	OnValidationError func(req *http.Request, err error)
+
+	//Where to look for the token of a request, evaluated in order. The first token found is validated and passed
+	//on in X-Auth-Token. Defaults to the X-Auth-Token header. E.g. for browser based clients:
+	//
+	//	auth.TokenExtractors = []keystone.TokenExtractor{
+	//		keystone.HeaderToken("X-Auth-Token"),
+	//		keystone.BearerToken(),
+	//		keystone.CookieToken("session"),
+	//	}
+	TokenExtractors []TokenExtractor
+
+	//Requests for which it returns true are passed on without validating their token, like those of Anonymous
+	//routes. Identity headers sent by the client are still removed. See SkipPaths for exempting health checks
+	//or metrics endpoints. It takes precedence over Classes and Routes.
+	SkipFunc func(req *http.Request) bool
+
+	//Per path identity requirements, evaluated in order before validating the token.
+	//The first matching route applies. Requests not matching any route are handled as TokenOptional.
+	Routes []Route
+
+	//Assigns requests to a class with its own treatment, configured by Classes:
+	//
+	//	auth.Classifier = func(r *http.Request) keystone.Class {
+	//		if r.Header.Get("X-Hub-Signature") != "" {
+	//			return "webhook"
+	//		}
+	//		return "interactive"
+	//	}
+	//	auth.Classes = map[keystone.Class]keystone.ClassOptions{
+	//		"webhook":     {Access: keystone.Anonymous},
+	//		"interactive": {Access: keystone.TokenRequired, Validation: keystone.ValidationOptions{CacheTime: time.Minute}},
+	//	}
+	//
+	//Classes take precedence over Routes, requests of classes without options are matched against Routes.
+	Classifier func(*http.Request) Class
+	Classes    map[Class]ClassOptions
+
+	//Roles required by handlers returned by AdminOnly. Defaults to admin.
+	AdminRoles []string
+	//How long handlers returned by AdminOnly cache tokens, capped by CacheTime. Defaults to 30 seconds.
+	AdminCacheTime time.Duration
+
+	//Break-glass authentication used while Keystone is unavailable (see IsUnavailable).
+	//If it returns a token context for a request, the request is treated as authenticated with that identity.
+	//Every request authenticated this way is logged. Disabled if nil.
+	//See the fallback/htpasswd package for an implementation based on htpasswd files.
+	Fallback func(req *http.Request) *Token
+
+	//Validate tokens without affecting the request. Incoming identity headers are passed on untouched
+	//and the validation result is only added as advisory X-Shadow-* headers (e.g. X-Shadow-Identity-Status).
+	//This allows assessing a Keystone rollout while another system still makes the auth decisions.
+	ShadowMode bool
+
+	//Stream of authentication decisions for security analytics. Disabled if nil.
+	Decisions *DecisionStream
+
+	//Add a Server-Timing entry to responses reporting the time spent on authenticating the request
+	ServerTiming bool
+
+	//Remove X-Auth-Token and X-Service-Token from requests passed on, so the tokens can't be leaked by
+	//subsequent handlers, e.g. in logs. The validated token context is still available, see TokenFromContext.
+	//Revalidation keeps working, the middleware passes the token on to it internally.
+	RemoveAuthToken bool
+	//Together with RemoveAuthToken pass on the hex encoded SHA-256 hash of the removed tokens in
+	//X-Auth-Token-Hash and X-Service-Token-Hash, e.g. for correlating requests of a session
+	AuthTokenHash bool
+
+	//Cache-Control header of responses to requests carrying a token and of requests which are rejected without
+	//a valid token (see RejectUnauthenticated and Routes), so shared caches like CDNs never serve the response
+	//for one user to another. Handlers can override it. Vary: X-Auth-Token is added to all responses whose
+	//requests were authenticated. Defaults to private.
+	CacheControl string
+	//Don't set Cache-Control and Vary headers
+	DisableCacheHeaders bool
+
+	//Identity headers passed on to subsequent handlers. Defaults to FullHeaders, see also Route.Headers.
+	Headers HeaderSet
+	//Adjusts the identity headers derived from a token before they are set on the request, e.g. to rename,
+	//add or drop headers. It is called for service tokens too, before their headers are prefixed with X-Service-.
+	//Headers added by the mapper aren't removed from incoming requests, handlers must not trust them
+	//unless X-Identity-Status is Confirmed.
+	HeaderMapper func(token *Token, header http.Header)
+
+	//Handling of CONNECT requests in forward proxies. Defaults to ConnectRequireToken.
+	Connect ConnectPolicy
+
+	//Answer requests to this path with the result of HealthCheck (200 or 503) instead of passing them on,
+	//e.g. "/healthz" for Kubernetes readiness probes. Disabled if empty. The result is cached for a few
+	//seconds, failures are logged but not disclosed to the client.
+	HealthPath string
+
+	//Clone incoming requests before injecting headers. If set, downstream handlers receive a
+	//shallow copy of the request with its own headers and the caller's request is left untouched.
+	CloneRequest bool
+
+	cacheWriter cacheWriter
+	asyncWriter asyncCacheWriter
+	flights     flightGroup
+	rateLimiter rateLimiter
+	health      healthState
+	throttle    throttleState
+	stats       stats
+	serviceUser serviceUser
+	breaker     circuitBreaker
+	latency     latencyRing
+	pool        nodePool
+	revocations revocationList
+	limiter     validationLimiter
+	refreshing  sync.Map
+
+	//service user of SecondaryEndpoint
+	secondaryServiceUser serviceUser
 }
 
+// minCacheTTL is the minimum remaining lifetime of a token for being cached
+const minCacheTTL = time.Second
+
 // New returns a new Auth object initialized with default values
 func New(endpoint string) *Auth {
 	auth := &Auth{Endpoint: endpoint}
@@ -54,70 +401,404 @@ func New(endpoint string) *Auth {
 	return auth
 }
 
-//Handler returns a http handler for use in a middleware chain.
+// Handler returns a http handler for use in a middleware chain.
+// It panics if no Endpoint is configured and OfflineMode isn't set.
 func (a *Auth) Handler(h http.Handler) http.Handler {
+	if a.Endpoint == "" && !a.OfflineMode {
+		panic(ErrNoEndpoint)
+	}
 	a.ensureDefaults()
 	return &handler{Auth: a, handler: h}
 }
 
-//Validate a token.
-//This is useful if you don't want to use the http middleware
+// Validate a token.
+// This is useful if you don't want to use the http middleware
 func (a *Auth) Validate(authToken string) (*Token, error) {
+	return a.ValidateContext(context.Background(), authToken)
+}
+
+// ValidateContext validates a token like Validate.
+// The context is passed to cache backends implementing CacheCtx.
+func (a *Auth) ValidateContext(ctx context.Context, authToken string) (*Token, error) {
+	token, _, err := a.validateToken(ctx, authToken)
+	return token, err
+}
 
-	if a.TokenCache != nil {
-		var cachedToken Token
-		if ok := a.TokenCache.Get(authToken, &cachedToken); ok && cachedToken.Valid() {
-			Log("Found valid token in cache")
-			return &cachedToken, nil
+// ValidateRequest validates the token of req like the middleware does: validations are limited per client
+// address (see ValidationRatePerIP) and valid tokens are checked with VerifyRequest. It's meant for servers
+// of other protocols representing their calls as http requests, like the gRPC interceptors.
+func (a *Auth) ValidateRequest(req *http.Request, authToken string) (*Token, error) {
+	ctx := req.Context()
+	if a.ValidationRatePerIP > 0 {
+		ctx = withClientIP(ctx, a.clientIP(req))
+	}
+	token, _, err := a.validateToken(ctx, authToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.verifyRequest(req, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// verifyRequest applies VerifyRequest to a valid token of req
+func (a *Auth) verifyRequest(req *http.Request, token *Token) error {
+	if a.VerifyRequest == nil {
+		return nil
+	}
+	if err := a.VerifyRequest(req, token); err != nil {
+		return fmt.Errorf("%w: %v", ErrRestricted, err)
+	}
+	return nil
+}
+
+// validateToken validates a token and applies the configured policies.
+// It also reports if the token was served from the cache.
+func (a *Auth) validateToken(ctx context.Context, authToken string) (token *Token, cached bool, err error) {
+	if a.Metrics != nil {
+		a.Metrics.ValidationStarted()
+		defer func() { a.Metrics.ValidationDone(validationOutcome(err), validationIssuer(token)) }()
+	}
+	if a.Tracer != nil {
+		var end func(cached bool, err error)
+		ctx, end = a.Tracer.StartValidation(ctx)
+		defer func() { end(cached, err) }()
+	}
+	token, cached, err = a.validate(ctx, authToken)
+	if err != nil {
+		return nil, cached, err
+	}
+	if cached {
+		if err := a.checkRevoked(ctx, authToken, token); err != nil {
+			return nil, cached, err
 		}
 	}
+	if err := a.checkToken(token); err != nil {
+		return nil, cached, err
+	}
+	if err := validationOptionsFromContext(ctx).RequireScope.check(token); err != nil {
+		return nil, cached, err
+	}
+	return token, cached, nil
+}
+
+func (a *Auth) validate(ctx context.Context, authToken string) (*Token, bool, error) {
+	if token, handled, err := a.validateLocal(authToken); handled {
+		return token, false, err
+	}
+	opts := validationOptionsFromContext(ctx)
+	if opts.Endpoint != "" && opts.Endpoint != a.Endpoint {
+		//token contexts of other endpoints don't share the cache
+		token, _, err := a.loadShared(ctx, opts.Endpoint, authToken)
+		return token, false, err
+	}
+
+	key := a.cacheKey(authToken)
+	if a.LoadingCache != nil {
+		if opts.SkipCache {
+			token, _, err := a.loadShared(ctx, a.Endpoint, authToken)
+			return token, false, err
+		}
+		token, cached, err := a.LoadingCache.Get(key, func(string) (*Token, time.Duration, error) {
+			//loading caches may load in the background after the request finished
+			return a.load(context.WithoutCancel(ctx), a.Endpoint, authToken)
+		})
+		a.cacheLookup(cached)
+		return token, cached, err
+	}
+
+	var stale, previous *Token
+	if a.TokenCache != nil && opts.SkipCache {
+		//the cached context is only read for reporting changes of a forced revalidation
+		previous, _, _, _ = getCachedToken(ctx, a.TokenCache, key, a.ClockSkew)
+	} else if a.TokenCache != nil {
+		res, pending := a.getCached(ctx, key)
+		if pending != nil {
+			a.stats.cacheTimeouts.Add(1)
+			a.log(ctx, slog.LevelDebug, "Token cache lookup timed out", "token", RedactToken(authToken), "timeout", a.CacheTimeout)
+			if a.CacheTimeoutRace {
+				return a.raceCache(ctx, key, authToken, pending)
+			}
+		}
+		if res.state == entryStale {
+			stale, res.ok = res.token, false
+		}
+		a.cacheLookup(res.ok)
+		if res.ok {
+			if res.err != nil {
+				return nil, true, res.err
+			}
+			a.log(ctx, slog.LevelDebug, "Token cache hit", "token", RedactToken(authToken))
+			if res.state == entryRefresh {
+				a.refreshAhead(ctx, key, authToken, res.token)
+			}
+			return res.token, true, nil
+		}
+		a.log(ctx, slog.LevelDebug, "Token cache miss", "token", RedactToken(authToken))
+	}
+
+	if previous == nil {
+		previous = stale
+	}
+	token, ttl, err := a.loadShared(ctx, a.Endpoint, authToken)
+	return a.loaded(ctx, authToken, stale, previous, token, ttl, err)
+}
+
+// loaded handles the result of validating a token against Keystone. If Keystone is unavailable the stale token
+// is served if present. Changes since the previously cached context of the token are reported.
+func (a *Auth) loaded(ctx context.Context, authToken string, stale, previous, token *Token, ttl time.Duration, err error) (*Token, bool, error) {
+	if err != nil {
+		if stale != nil && (IsUnavailable(err) || a.rateLimited(err)) {
+			a.stats.servedStale.Add(1)
+			a.log(ctx, slog.LevelWarn, "Keystone unavailable, serving stale token from cache", "token", RedactToken(authToken), "error", err)
+			return stale, true, nil
+		}
+		return nil, false, err
+	}
+
+	a.reportChanges(ctx, authToken, previous, token)
+	return token, false, nil
+}
+
+// cacheLoaded writes the result of validating a token against Keystone to the TokenCache.
+// Tokens rejected by Keystone are cached for InvalidCacheTime.
+func (a *Auth) cacheLoaded(ctx context.Context, key string, token *Token, ttl time.Duration, err error) {
+	if err == nil {
+		a.cacheToken(ctx, key, token, ttl)
+		return
+	}
+	var kerr *Error
+	if a.TokenCache != nil && a.InvalidCacheTime > 0 && errors.As(err, &kerr) &&
+		(kerr.StatusCode == http.StatusUnauthorized || kerr.StatusCode == http.StatusNotFound) {
+		a.writeCache(ctx, key, newCachedError(kerr), a.InvalidCacheTime)
+	}
+}
+
+// cacheToken writes a token validated against Keystone to the TokenCache
+func (a *Auth) cacheToken(ctx context.Context, key string, token *Token, ttl time.Duration) {
+	if opts := validationOptionsFromContext(ctx); opts.CacheTime > 0 && ttl > 0 {
+		if ttl = opts.CacheTime; ttl > a.expiresIn(token) {
+			ttl = a.expiresIn(token)
+		}
+	}
+	if a.TokenCache == nil || ttl <= 0 {
+		return
+	}
+	entry := newCachedToken(token)
+	if a.RefreshAhead > 0 && ttl > a.RefreshAhead {
+		entry.RefreshAt = time.Now().Add(ttl - a.RefreshAhead)
+	}
+	if a.StaleCacheTime > 0 {
+		entry.StaleAt = time.Now().Add(ttl)
+		if ttl += a.StaleCacheTime; ttl > a.expiresIn(token) {
+			ttl = a.expiresIn(token)
+		}
+	}
+	a.writeCache(ctx, key, entry, ttl)
+}
+
+// loadShared calls load unless a validation of the same token against the endpoint is already in flight,
+// in which case it waits for and shares its result. This avoids a burst of requests with the same token
+// and a cold cache resulting in a burst of requests to Keystone.
+// Callers stop waiting once ctx is done, the validation is cancelled when all of them gave up.
+// Results for the configured Endpoint are cached before the flight ends,
+// so requests arriving meanwhile don't validate the token again.
+func (a *Auth) loadShared(ctx context.Context, endpoint, authToken string) (*Token, time.Duration, error) {
+	return a.flights.doCtx(ctx, endpoint+" "+authToken, func(ctx context.Context) (*Token, time.Duration, error) {
+		token, ttl, err := a.load(ctx, endpoint, authToken)
+		if endpoint == a.Endpoint {
+			a.cacheLoaded(context.WithoutCancel(ctx), a.cacheKey(authToken), token, ttl, err)
+		}
+		return token, ttl, err
+	})
+}
+
+// load validates a token against the keystone endpoint and returns the token context together with the time it may be cached
+func (a *Auth) load(ctx context.Context, endpoint, authToken string) (*Token, time.Duration, error) {
+	if endpoint == "" {
+		return nil, 0, ErrNoEndpoint
+	}
+	if a.throttle.throttled() {
+		return nil, 0, ErrThrottled
+	}
+	if !a.allowValidation(clientIPFromContext(ctx)) {
+		a.stats.rateLimited.Add(1)
+		return nil, 0, ErrRateLimited
+	}
+	if a.ValidationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.ValidationTimeout)
+		defer cancel()
+	}
 
-	req, err := http.NewRequest("GET", a.Endpoint+"/auth/tokens?nocatalog", nil)
+	release, err := a.acquire(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	token, err := a.fetchWithRetries(ctx, endpoint, authToken)
+	release()
+	if err != nil {
+		return nil, 0, err
+	}
+	if a.VerifyAudience != nil {
+		if err := a.VerifyAudience(token, token.payload); err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrAudience, err)
+		}
+	}
+
+	cacheTime := a.CacheTime
+	if a.TTLFunc != nil {
+		if cacheTime = a.TTLFunc(token); cacheTime <= 0 {
+			return token, 0, nil
+		}
+	}
+	ttl := cacheTime
+	if a.ThrottledCacheFactor > 1 && a.throttle.degraded() {
+		ttl *= time.Duration(a.ThrottledCacheFactor)
+	}
+	//The expiry date of the token provides an upper bound on the cache time
+	expiresIn := a.expiresIn(token)
+	if expiresIn < ttl {
+		ttl = expiresIn
+	}
+	if early, total, report := a.stats.validated(expiresIn < cacheTime); report && 2*early > total {
+		a.log(context.Background(), slog.LevelWarn, "Tokens expire before CacheTime, consider lowering CacheTime or using a LoadingCache with refresh-ahead",
+			"expiring", early, "validated", total, "cache_time", a.CacheTime)
+	}
+	//Don't bother caching tokens about to expire
+	if ttl < minCacheTTL {
+		if a.WarnShortLivedTokens {
+			a.log(context.Background(), slog.LevelWarn, "Not caching token about to expire", "user_id", token.User.ID, "expires_in", ttl)
+		}
+		ttl = 0
+	}
+	return token, ttl, nil
+}
+
+// requestToken validates a token against the given keystone endpoint
+func (a *Auth) requestToken(ctx context.Context, endpoint, authToken string) (*Token, error) {
+	s := a.serviceLogin(endpoint)
+	if s == nil {
+		return a.sendValidation(ctx, endpoint, authToken, authToken)
+	}
+	serviceToken, err := a.serviceToken(s)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("X-Auth-Token", authToken)
+	token, err := a.sendValidation(ctx, endpoint, serviceToken, authToken)
+	var kerr *Error
+	if errors.As(err, &kerr) && kerr.StatusCode == http.StatusUnauthorized {
+		//Keystone rejected the service token itself, e.g. because it was revoked
+		a.log(ctx, slog.LevelWarn, "Service token rejected by Keystone, re-authenticating")
+		if serviceToken, err = a.renewServiceToken(s, serviceToken); err != nil {
+			return nil, err
+		}
+		token, err = a.sendValidation(ctx, endpoint, serviceToken, authToken)
+	}
+	return token, err
+}
+
+// sendValidation validates authToken against the given keystone endpoint, authenticating with serviceToken
+func (a *Auth) sendValidation(ctx context.Context, endpoint, serviceToken, authToken string) (*Token, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", a.validationURL(endpoint), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Auth-Token", serviceToken)
 	req.Header.Set("X-Subject-Token", authToken)
 	req.Header.Set("User-Agent", a.UserAgent)
 
+	start := time.Now()
 	r, err := a.Client.Do(req)
+	d := time.Since(start)
 	if err != nil {
+		//cancelled hedged requests aren't errors
+		if ctx.Err() == nil {
+			a.log(ctx, slog.LevelError, "Keystone request failed", "endpoint", endpoint, "error", err)
+			a.latency.record(d, true)
+			if a.Metrics != nil {
+				a.Metrics.KeystoneRequest(endpoint, 0, d)
+			}
+		}
 		return nil, err
 	}
 	defer r.Body.Close()
+	a.latency.record(d, r.StatusCode >= 500)
+	if a.Metrics != nil {
+		a.Metrics.KeystoneRequest(endpoint, r.StatusCode, d)
+	}
+	a.log(ctx, slog.LevelDebug, "Keystone request", "endpoint", endpoint, "status", r.StatusCode, "duration", d)
 
-	if r.StatusCode >= 400 {
-		return nil, errors.New(r.Status)
+	if r.StatusCode == http.StatusTooManyRequests {
+		a.stats.throttled.Add(1)
+		a.log(ctx, slog.LevelWarn, "Keystone is throttling requests", "backoff", a.throttle.backoff(r))
+		return nil, ErrThrottled
+	}
+	if r.StatusCode == http.StatusServiceUnavailable && r.Header.Get("Retry-After") != "" {
+		a.throttle.degrade()
 	}
 
+	return decodeToken(r, http.StatusOK, a.ClockSkew)
+}
+
+// decodeToken extracts the token context from a keystone response
+func decodeToken(r *http.Response, expectedStatus int, skew time.Duration) (*Token, error) {
 	var resp authResponse
-	if err = json.NewDecoder(r.Body).Decode(&resp); err != nil {
-		return nil, err
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &resp)
 	}
 
-	if e := resp.Error; e != nil {
-		return nil, fmt.Errorf("%s : %s", r.Status, e.Message)
+	if r.StatusCode >= 400 || resp.Error != nil || r.StatusCode != expectedStatus {
+		kerr := &Error{StatusCode: r.StatusCode, Status: r.Status}
+		//the error details are optional, a body which can't be decoded is ignored
+		if e := resp.Error; err == nil && e != nil {
+			kerr.Title = e.Title
+			kerr.Message = e.Message
+		}
+		return nil, kerr
 	}
-	if r.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s", r.Status)
+	if err != nil {
+		var syntax *json.SyntaxError
+		var typ *json.UnmarshalTypeError
+		var parse *time.ParseError
+		if errors.As(err, &syntax) || errors.As(err, &typ) || errors.As(err, &parse) {
+			return nil, newDecodeError(err, body)
+		}
+		return nil, err
 	}
 	if resp.Token == nil {
 		return nil, errors.New("Response didn't contain token context")
 	}
-	if !resp.Token.Valid() {
+	if !resp.Token.ValidWithSkew(skew) {
 		return nil, errors.New("Returned token is not valid")
 	}
+	var raw struct {
+		Token json.RawMessage
+	}
+	if err := json.Unmarshal(body, &raw); err == nil {
+		resp.Token.payload = raw.Token
+	}
+	return resp.Token, nil
+}
 
-	if a.TokenCache != nil {
-		ttl := a.CacheTime
-		//The expiry date of the token provides an upper bound on the cache time
-		if expiresIn := resp.Token.ExpiresAt.Sub(time.Now()); expiresIn < a.CacheTime {
-			ttl = expiresIn
+// checkToken applies the configured policies to a valid token
+func (a *Auth) checkToken(t *Token) error {
+	if a.RequireRoles && len(t.Roles) == 0 {
+		return ErrNoRoles
+	}
+	if a.RejectDisabled {
+		if err := checkEnabled(t); err != nil {
+			return err
 		}
-		a.TokenCache.Set(authToken, *resp.Token, ttl)
 	}
-
-	return resp.Token, nil
+	if len(a.AllowedDomains) > 0 || len(a.DeniedDomains) > 0 {
+		if err := a.checkDomains(t); err != nil {
+			return err
+		}
+	}
+	return a.checkBinding(t)
 }
 
 func (a *Auth) ensureDefaults() {
@@ -131,8 +812,17 @@ func (a *Auth) ensureDefaults() {
 	}
 
 	if a.Client == nil {
+		timeout := a.Timeout
+		if timeout == 0 {
+			timeout = 5 * time.Second
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if a.TLSConfig != nil {
+			transport.TLSClientConfig = a.TLSConfig
+		}
 		a.Client = &http.Client{
-			Timeout: 5 * time.Second,
+			Timeout:   timeout,
+			Transport: transport,
 		}
 	}
 
@@ -141,46 +831,221 @@ func (a *Auth) ensureDefaults() {
 type handler struct {
 	*Auth
 	handler http.Handler
+	//class applied to all requests instead of Classifier and Routes, see AdminOnly
+	class *ClassOptions
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if h.HealthPath != "" && req.URL.Path == h.HealthPath {
+		h.serveHealth(w, req)
+		return
+	}
+	if h.CloneRequest {
+		req = cloneRequest(req)
+	}
+	h.extractToken(req)
+	if h.ShadowMode {
+		h.shadow(w, req)
+		return
+	}
 	filterIncomingHeaders(req)
+	req = req.WithContext(withRequestID(req.Context(), req))
+	if h.ValidationRatePerIP > 0 {
+		req = req.WithContext(withClientIP(req.Context(), h.clientIP(req)))
+	}
+	if req.Method == http.MethodConnect && h.Connect != ConnectPassThrough {
+		h.serveConnect(w, req)
+		return
+	}
 	req.Header.Set("X-Identity-Status", "Invalid")
-	defer h.handler.ServeHTTP(w, req)
+
+	var route *Route
+	if h.class != nil {
+		req, route = h.class.apply(req)
+	} else if h.SkipFunc != nil && h.SkipFunc(req) {
+		route = &Route{Access: Anonymous}
+	} else if req, route = h.classify(req); route == nil {
+		route = h.route(req.URL.Path)
+	}
+	if route != nil && route.Access == Anonymous {
+		req = h.removeAuthTokens(req)
+		h.handler.ServeHTTP(w, req)
+		return
+	}
+	if beforeWrite := h.cacheHeaders(req, route); beforeWrite != nil {
+		rw := &responseWriter{ResponseWriter: w, beforeWrite: beforeWrite}
+		//handlers not writing anything get an implicit 200 response
+		defer rw.prepareHeader()
+		w = rw
+	}
+
+	token, err := h.authenticate(w, req)
+	h.stats.count(token)
+	if token == nil && (h.RejectUnauthenticated || route != nil && route.Access == TokenRequired) {
+		h.reject(w, req, err)
+		return
+	}
+	if !route.allow(token) {
+		h.fail(w, req, http.StatusForbidden, ErrMissingRole)
+		return
+	}
+	if token != nil {
+		h.setHeaders(req.Header, token, route)
+		req = req.WithContext(withToken(req.Context(), token))
+	}
+	if service := h.authenticateService(req, route); service != nil {
+		req = req.WithContext(withServiceToken(req.Context(), service))
+	}
+	req = h.removeAuthTokens(req)
+	h.handler.ServeHTTP(w, req)
+}
+
+// removeAuthTokens strips the tokens from a request passed on if RemoveAuthToken is set.
+// The token is kept in the context for Revalidation.
+func (h *handler) removeAuthTokens(req *http.Request) *http.Request {
+	if !h.RemoveAuthToken {
+		return req
+	}
+	if authToken := req.Header.Get("X-Auth-Token"); authToken != "" {
+		req = req.WithContext(withAuthToken(req.Context(), authToken))
+	}
+	for header, hashHeader := range map[string]string{"X-Auth-Token": "X-Auth-Token-Hash", "X-Service-Token": "X-Service-Token-Hash"} {
+		if authToken := req.Header.Get(header); authToken != "" && h.AuthTokenHash {
+			req.Header.Set(hashHeader, hashToken(authToken))
+		}
+		req.Header.Del(header)
+	}
+	return req
+}
+
+// authenticate validates the token of the request. It returns nil if the request isn't authenticated
+// together with the validation error if the request carried a token.
+func (h *handler) authenticate(w http.ResponseWriter, req *http.Request) (*Token, error) {
 	authToken := req.Header.Get("X-Auth-Token")
 	if authToken == "" {
-		return
+		h.Decisions.record(req, "", nil, false, 0)
+		return nil, nil
 	}
 
-	context, err := h.Auth.Validate(authToken)
+	start := time.Now()
+	token, cached, err := h.Auth.validateToken(req.Context(), authToken)
+	latency := time.Since(start)
+	if err == nil {
+		if err = h.verifyRequest(req, token); err != nil {
+			token = nil
+		}
+	}
+	if h.ServerTiming {
+		setServerTiming(w, latency, cached)
+	}
+	h.Decisions.record(req, authToken, token, cached, latency)
 	if err != nil {
 		//ToDo: How to handle logging, printing to stdout isn't the best thing
-		Log("Failed to validate token: %v", err)
+		h.log(req.Context(), slog.LevelInfo, "Token validation failed", "token", RedactToken(authToken), "error", redactError(err, authToken))
+		if h.OnValidationError != nil {
+			h.OnValidationError(req, err)
+		}
+		if h.Fallback != nil && IsUnavailable(err) {
+			if token := h.fallback(req); token != nil {
+				return token, nil
+			}
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+// reject responds to an unauthenticated request with 401 or with 503 if the token couldn't be validated
+// because keystone is unavailable
+func (h *handler) reject(w http.ResponseWriter, req *http.Request, err error) {
+	if errors.Is(err, ErrOverloaded) {
+		w.Header().Set("Retry-After", "1")
+	}
+	if errors.Is(err, ErrRateLimited) && h.RateLimitOverflow == RateLimitTooManyRequests {
+		w.Header().Set("Retry-After", "1")
+		h.fail(w, req, http.StatusTooManyRequests, err)
+		return
+	}
+	if err != nil && (IsUnavailable(err) || h.rateLimited(err)) {
+		h.fail(w, req, http.StatusServiceUnavailable, err)
+		return
+	}
+	h.SetChallenge(w)
+	h.fail(w, req, http.StatusUnauthorized, err)
+}
+
+// fail terminates a request with an error response, rendered by ErrorHandler if set
+func (h *handler) fail(w http.ResponseWriter, req *http.Request, status int, err error) {
+	if h.ErrorHandler != nil {
+		h.ErrorHandler(w, req, status, err)
 		return
 	}
+	http.Error(w, http.StatusText(status), status)
+}
 
-	req.Header.Set("X-Identity-Status", "Confirmed")
-	for k, v := range context.headers() {
-		req.Header.Set(k, v)
+// fallback authenticates a request using Auth.Fallback while keystone is unavailable
+func (h *handler) fallback(req *http.Request) *Token {
+	token := h.Fallback(req)
+	if token == nil {
+		return nil
 	}
+	h.log(req.Context(), slog.LevelWarn, "Keystone unavailable, request authenticated by fallback",
+		"method", req.Method, "path", req.URL.Path, "remote_addr", req.RemoteAddr, "user_name", token.User.Name, "user_id", token.User.ID)
+	return token
 }
 
-//Domain holds information about the scope of a token
+// Domain holds information about the scope of a token
 type Domain struct {
 	ID      string
 	Name    string
 	Enabled bool
 }
 
-//Project contains information about the scope of a token
+// Project contains information about the scope of a token
 type Project struct {
 	ID      string
 	Name    string
 	Enabled bool
 	Domain  Domain
+	//Set for projects acting as a domain
+	IsDomain bool `json:"is_domain,omitempty"`
+}
+
+// System contains information about the system scope of a token
+type System struct {
+	//Set for tokens scoped to the whole deployment
+	All bool
+}
+
+// Trust contains the delegation of a trust scoped token (OS-TRUST extension)
+type Trust struct {
+	ID      string `json:"id"`
+	Trustor struct {
+		ID string `json:"id"`
+	} `json:"trustor_user"`
+	Trustee struct {
+		ID string `json:"id"`
+	} `json:"trustee_user"`
+	//Set if the trustee acts as the trustor instead of on behalf of the trustor
+	Impersonation bool `json:"impersonation"`
 }
 
-//Token describes the scope of a validated token
+// Federation contains the origin of a federated user (OS-FEDERATION extension)
+type Federation struct {
+	IdentityProvider struct {
+		ID string `json:"id"`
+	} `json:"identity_provider"`
+	Protocol struct {
+		ID string `json:"id"`
+	} `json:"protocol"`
+	//Groups the user is a member of according to the identity provider's mapping
+	Groups []struct {
+		ID   string `json:"id"`
+		Name string `json:"name,omitempty"`
+	} `json:"groups"`
+}
+
+// Token describes the scope of a validated token
 type Token struct {
 	ExpiresAt time.Time `json:"expires_at"`
 	IssuedAt  time.Time `json:"issued_at"`
@@ -190,22 +1055,57 @@ type Token struct {
 		Email   string
 		Enabled bool
 		Domain  struct {
-			ID   string
-			Name string
+			ID      string
+			Name    string
+			Enabled bool
 		}
+		//Only set for federated users
+		Federation *Federation `json:"OS-FEDERATION,omitempty"`
 	}
 	Project *Project
-	Domain  *Domain
-	Roles   []struct {
+	//If the project scope of the token is Keystone's admin project. Keystone omits the flag unless an admin
+	//project is configured, in which case all project scoped tokens are considered admin project tokens
+	//like the python middleware does, see IsAdminProject.
+	AdminProject *bool `json:"is_admin_project,omitempty"`
+	Domain       *Domain
+	//System scope of the token, only set for system scoped tokens (Keystone v3.10+)
+	System *System
+	//Trust the token was obtained with, only set for trust scoped tokens
+	Trust *Trust `json:"OS-TRUST:trust,omitempty"`
+	//Keystone endpoint which validated the token, see Auth.SecondaryEndpoint
+	Issuer string `json:"issuer,omitempty"`
+	Roles  []struct {
 		ID   string
 		Name string
 	}
+	//Audit IDs of the token, the first one identifies the token, the last one the chain of tokens it was derived from
+	AuditIDs []string `json:"audit_ids,omitempty"`
+	//Set for tokens revoked within Auth.RevocationGracePeriod
+	Revoked bool `json:"-"`
+	//Free form extensions of the token added by Keystone plugins
+	Extras map[string]json.RawMessage `json:"extras,omitempty"`
+	//Service catalog, only present if Auth.IncludeServiceCatalog is set
+	Catalog []CatalogEntry `json:"catalog,omitempty"`
+
+	//raw token payload as returned by Keystone, see Auth.VerifyAudience
+	payload json.RawMessage
 }
 
 // Valid returns if the token is valid based on the expiration and issue date
 func (t Token) Valid() bool {
-	now := time.Now().Unix()
-	return t.IssuedAt.Unix() <= now && now < t.ExpiresAt.Unix()
+	return t.ValidWithSkew(0)
+}
+
+// ValidWithSkew is like Valid but tolerates clocks deviating from Keystone's by up to skew:
+// tokens issued up to skew in the future are valid and tokens expire up to skew later.
+func (t Token) ValidWithSkew(skew time.Duration) bool {
+	now := time.Now()
+	return t.IssuedAt.Unix() <= now.Add(skew).Unix() && now.Add(-skew).Unix() < t.ExpiresAt.Unix()
+}
+
+// expiresIn returns the remaining lifetime of a token, see ClockSkew
+func (a *Auth) expiresIn(t *Token) time.Duration {
+	return time.Until(t.ExpiresAt) + a.ClockSkew
 }
 
 type authResponse struct {
@@ -217,6 +1117,50 @@ type authResponse struct {
 	Token *Token
 }
 
+// SetHeaders sets the identity headers for the token context, including X-Identity-Status.
+// This is useful for running code outside of http requests (e.g. background jobs) with the same identity headers.
+func (t Token) SetHeaders(header http.Header) {
+	header.Set("X-Identity-Status", "Confirmed")
+	for k, v := range t.headers() {
+		header.Set(k, v)
+	}
+}
+
+// Canonical returns a canonical serialization of all identity headers of the token context, see canonicalHeaders.
+// Use Auth.Canonical for the headers the middleware actually injects with the configured Headers and HeaderMapper.
+func (t Token) Canonical() string {
+	header := http.Header{}
+	t.SetHeaders(header)
+	return canonicalHeaders(header)
+}
+
+// canonicalHeaders serializes header with each header on a separate line as "Name: value", lines are sorted
+// by header name and multiple values are joined by ", ". The output is stable and suitable for golden file
+// tests and checksums.
+func canonicalHeaders(header http.Header) string {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k + ": " + strings.Join(header[k], ", ") + "\n")
+	}
+	return b.String()
+}
+
+// IsAdminProject returns if the token is scoped to Keystone's admin project.
+// Project scoped tokens without the is_admin_project flag are considered admin project tokens.
+func (t Token) IsAdminProject() bool {
+	return t.Project != nil && (t.AdminProject == nil || *t.AdminProject)
+}
+
+// systemScoped returns if the token is scoped to the whole deployment
+func (t Token) systemScoped() bool {
+	return t.System != nil && t.System.All
+}
+
 func (t Token) headers() map[string]string {
 	headers := make(map[string]string)
 	headers["X-User-Id"] = t.User.ID
@@ -229,7 +1173,10 @@ func (t Token) headers() map[string]string {
 		headers["X-Project-Id"] = project.ID
 		headers["X-Project-Domain-Name"] = project.Domain.Name
 		headers["X-Project-Domain-Id"] = project.Domain.ID
-
+		headers["X-Is-Admin-Project"] = "False"
+		if t.IsAdminProject() {
+			headers["X-Is-Admin-Project"] = "True"
+		}
 	}
 
 	if domain := t.Domain; domain != nil {
@@ -237,18 +1184,71 @@ func (t Token) headers() map[string]string {
 		headers["X-Domain-Name"] = domain.Name
 	}
 
+	if t.systemScoped() {
+		headers["X-System-Scope"] = "all"
+	}
+
+	if trust := t.Trust; trust != nil {
+		headers["X-Trust-Id"] = trust.ID
+		headers["X-Trustor-User-Id"] = trust.Trustor.ID
+		headers["X-Trustee-User-Id"] = trust.Trustee.ID
+	}
+
+	if federation := t.User.Federation; federation != nil {
+		headers["X-Identity-Provider"] = federation.IdentityProvider.ID
+		headers["X-Protocol"] = federation.Protocol.ID
+		var ids, names []string
+		for _, g := range federation.Groups {
+			ids = append(ids, g.ID)
+			if g.Name != "" {
+				names = append(names, g.Name)
+			}
+		}
+		headers["X-Group-Ids"] = strings.Join(ids, ",")
+		if len(names) > 0 {
+			headers["X-Group-Names"] = strings.Join(names, ",")
+		}
+	}
+
 	if roles := t.Roles; roles != nil {
-		roleNames := []string{}
+		roleNames, roleIDs := []string{}, []string{}
 		for _, role := range t.Roles {
 			roleNames = append(roleNames, role.Name)
+			roleIDs = append(roleIDs, role.ID)
 		}
 		headers["X-Roles"] = strings.Join(roleNames, ",")
+		headers["X-Role-Ids"] = strings.Join(roleIDs, ",")
+	}
 
+	if len(t.AuditIDs) > 0 {
+		headers["X-Audit-Ids"] = strings.Join(t.AuditIDs, ",")
+	}
+	if !t.ExpiresAt.IsZero() {
+		headers["X-Token-Expires-At"] = t.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if t.Revoked {
+		headers["X-Token-Revoked"] = "True"
+	}
+
+	if t.Catalog != nil {
+		catalog, _ := json.Marshal(t.Catalog)
+		headers[catalogHeader] = string(catalog)
 	}
 
 	return headers
 }
 
+// cloneRequest returns a shallow copy of req with its own copy of the headers
+func cloneRequest(req *http.Request) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	r.Header = req.Header.Clone()
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	return r
+}
+
 func filterIncomingHeaders(req *http.Request) {
 	req.Header.Del("X-Identity-Status")
 	req.Header.Del("X-Service-Identity-Status")
@@ -271,6 +1271,9 @@ func filterIncomingHeaders(req *http.Request) {
 	req.Header.Del("X-Project-Domain-Name")
 	req.Header.Del("X-Service-Project-Domain-Name")
 
+	req.Header.Del("X-Is-Admin-Project")
+	req.Header.Del("X-Service-Is-Admin-Project")
+
 	req.Header.Del("X-User-Id")
 	req.Header.Del("X-Service-User-Id")
 
@@ -285,8 +1288,42 @@ func filterIncomingHeaders(req *http.Request) {
 
 	req.Header.Del("X-Roles")
 	req.Header.Del("X-Service-Roles")
+	req.Header.Del("X-Role-Ids")
+	req.Header.Del("X-Service-Role-Ids")
+
+	req.Header.Del("X-System-Scope")
+	req.Header.Del("X-Service-System-Scope")
+
+	req.Header.Del("X-Trust-Id")
+	req.Header.Del("X-Service-Trust-Id")
+	req.Header.Del("X-Trustor-User-Id")
+	req.Header.Del("X-Service-Trustor-User-Id")
+	req.Header.Del("X-Trustee-User-Id")
+	req.Header.Del("X-Service-Trustee-User-Id")
+
+	req.Header.Del("X-Identity-Provider")
+	req.Header.Del("X-Service-Identity-Provider")
+	req.Header.Del("X-Protocol")
+	req.Header.Del("X-Service-Protocol")
+	req.Header.Del("X-Group-Ids")
+	req.Header.Del("X-Service-Group-Ids")
+	req.Header.Del("X-Group-Names")
+	req.Header.Del("X-Service-Group-Names")
+
+	req.Header.Del("X-Audit-Ids")
+	req.Header.Del("X-Service-Audit-Ids")
+
+	req.Header.Del("X-Token-Expires-At")
+	req.Header.Del("X-Service-Token-Expires-At")
+	req.Header.Del("X-Token-Revoked")
+	req.Header.Del("X-Service-Token-Revoked")
+
+	req.Header.Del("X-Auth-Token-Hash")
+	req.Header.Del("X-Service-Token-Hash")
+
+	req.Header.Del(catalogHeader)
 
-	req.Header.Del("X-Servie-Catalog")
+	req.Header.Del(TokenHeader)
 
 	//deprecated Headers
 	req.Header.Del("X-Tenant-Id")
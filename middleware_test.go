@@ -1,11 +1,16 @@
 package keystone
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -55,14 +60,20 @@ func TestSpoofProtection(t *testing.T) {
 	req.Header.Add("X-Identity-Status", "Confirmed")
 	req.Header.Add("X-Project-Id", "p-1234")
 	req.Header.Add("X-Domain-Id", "d-1234")
+	req.Header.Add("X-System-Scope", "all")
+	req.Header.Add("X-Token-Revoked", "False")
+	req.Header.Add("X-Service-Token-Revoked", "False")
 
 	h := checkHeaders(t, map[string]string{
-		"X-Identity-Status": "Invalid",
-		"X-Project-Id":      "",
-		"X-Domain-Id":       "",
+		"X-Identity-Status":       "Invalid",
+		"X-Project-Id":            "",
+		"X-Domain-Id":             "",
+		"X-System-Scope":          "",
+		"X-Token-Revoked":         "",
+		"X-Service-Token-Revoked": "",
 	})
 
-	a := Auth{}
+	a := Auth{OfflineMode: true}
 	a.Handler(h).ServeHTTP(rec, req)
 
 	//Validate that checking middleware was called
@@ -82,7 +93,7 @@ func TestNoToken(t *testing.T) {
 		w.Write([]byte(ok))
 	})
 
-	a := Auth{}
+	a := Auth{OfflineMode: true}
 	a.Handler(h).ServeHTTP(rec, req)
 
 	//Validate that checking middleware was called
@@ -140,7 +151,7 @@ func TestUnscopedToken(t *testing.T) {
 	idServer := identityMock(200, `
 {
   "token": {
-    "expires_at": "2020-10-08T08:40:33.100Z",
+    "expires_at": "2120-10-08T08:40:33.100Z",
     "issued_at": "2015-10-08T07:40:33.099Z",
     "methods": [
       "password"
@@ -186,7 +197,7 @@ func TestProjectScopedToken(t *testing.T) {
 	idServer := identityMock(200, `
 {
   "token": {
-    "expires_at": "2020-10-09T15:09:12.355Z",
+    "expires_at": "2120-10-09T15:09:12.355Z",
     "issued_at": "2015-10-08T15:09:12.355Z",
     "user": {
       "id": "u-42e54ca0c",
@@ -253,7 +264,7 @@ func TestDomainScopedToken(t *testing.T) {
 	idServer := identityMock(200, `
 {
   "token": {
-    "expires_at": "2020-10-09T15:09:11.727Z",
+    "expires_at": "2120-10-09T15:09:11.727Z",
     "issued_at": "2015-10-08T15:09:11.727Z",
     "methods": [
       "password"
@@ -308,6 +319,128 @@ func TestDomainScopedToken(t *testing.T) {
 
 }
 
+func TestSystemScopedToken(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := newRequest("GET", "/foo")
+	req.Header.Set("X-Auth-Token", "1234")
+	idServer := identityMock(200, `
+{
+  "token": {
+    "expires_at": "2120-10-09T15:09:11.727Z",
+    "issued_at": "2015-10-08T15:09:11.727Z",
+    "user": {"id": "u-admin", "name": "admin", "domain": {"id": "default", "name": "Default"}},
+    "system": {"all": true},
+    "roles": [{"id": "r-admin", "name": "admin"}]
+  }
+}`)
+	defer idServer.Close()
+	h := checkHeaders(t, map[string]string{
+		"X-Identity-Status": "Confirmed",
+		"X-Project-Id":      "",
+		"X-Domain-Id":       "",
+		"X-System-Scope":    "all",
+		"X-Roles":           "admin",
+	})
+	a := Auth{Endpoint: idServer.URL}
+	a.Handler(h).ServeHTTP(rec, req)
+	if body := rec.Body.String(); body != ok {
+		t.Fatalf("wrong body, got %q want %q", body, ok)
+	}
+	if token, err := a.Validate("1234"); err != nil || token.Identity().SystemScope != "all" || SystemScope.check(token) != nil || Unscoped.check(token) == nil {
+		t.Errorf("Expected system scoped token, got %+v, %v", token, err)
+	}
+}
+
+func TestTrustScopedToken(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := newRequest("GET", "/foo")
+	req.Header.Set("X-Auth-Token", "1234")
+	req.Header.Set("X-Trustor-User-Id", "u-spoofed")
+	idServer := identityMock(200, `
+{
+  "token": {
+    "expires_at": "2120-10-09T15:09:11.727Z",
+    "issued_at": "2015-10-08T15:09:11.727Z",
+    "user": {"id": "u-trustee", "name": "heat", "domain": {"id": "default", "name": "Default"}},
+    "project": {"id": "p-1", "name": "demo", "domain": {"id": "default", "name": "Default"}},
+    "OS-TRUST:trust": {
+      "id": "t-1",
+      "impersonation": false,
+      "trustee_user": {"id": "u-trustee"},
+      "trustor_user": {"id": "u-trustor"}
+    },
+    "roles": [{"id": "r-member", "name": "member"}]
+  }
+}`)
+	defer idServer.Close()
+	h := checkHeaders(t, map[string]string{
+		"X-Identity-Status": "Confirmed",
+		"X-User-Id":         "u-trustee",
+		"X-Trust-Id":        "t-1",
+		"X-Trustor-User-Id": "u-trustor",
+		"X-Trustee-User-Id": "u-trustee",
+	})
+	a := Auth{Endpoint: idServer.URL}
+	a.Handler(h).ServeHTTP(rec, req)
+	if body := rec.Body.String(); body != ok {
+		t.Fatalf("wrong body, got %q want %q", body, ok)
+	}
+
+	//the trust survives the cache and forwarding
+	token, _ := a.Validate("1234")
+	forwarded, err := unmarshalToken(token.marshal())
+	if err != nil || forwarded.Trust == nil || forwarded.Trust.Trustor.ID != "u-trustor" {
+		t.Errorf("Expected trust to be serialized, got %+v, %v", forwarded, err)
+	}
+}
+
+func TestFederatedToken(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := newRequest("GET", "/foo")
+	req.Header.Set("X-Auth-Token", "1234")
+	req.Header.Set("X-Group-Ids", "g-spoofed")
+	idServer := identityMock(200, `
+{
+  "token": {
+    "expires_at": "2120-10-09T15:09:11.727Z",
+    "issued_at": "2015-10-08T15:09:11.727Z",
+    "user": {
+      "id": "u-fed",
+      "name": "alice",
+      "domain": {"id": "Federated", "name": "Federated"},
+      "OS-FEDERATION": {
+        "identity_provider": {"id": "corp-idp"},
+        "protocol": {"id": "openid"},
+        "groups": [{"id": "g-1"}, {"id": "g-2"}]
+      }
+    },
+    "project": {"id": "p-1", "name": "demo", "domain": {"id": "default", "name": "Default"}},
+    "roles": [{"id": "r-member", "name": "member"}]
+  }
+}`)
+	defer idServer.Close()
+	h := checkHeaders(t, map[string]string{
+		"X-Identity-Status":   "Confirmed",
+		"X-User-Id":           "u-fed",
+		"X-Identity-Provider": "corp-idp",
+		"X-Protocol":          "openid",
+		"X-Group-Ids":         "g-1,g-2",
+		"X-Group-Names":       "",
+	})
+	a := Auth{Endpoint: idServer.URL}
+	a.Handler(h).ServeHTTP(rec, req)
+	if body := rec.Body.String(); body != ok {
+		t.Fatalf("wrong body, got %q want %q", body, ok)
+	}
+
+	//the federation attributes survive the cache and forwarding
+	token, _ := a.Validate("1234")
+	forwarded, err := unmarshalToken(token.marshal())
+	if err != nil || forwarded.User.Federation == nil || len(forwarded.User.Federation.Groups) != 2 {
+		t.Errorf("Expected federation attributes to be serialized, got %+v, %v", forwarded, err)
+	}
+}
+
 type cacheMock map[string][]byte
 
 func (c cacheMock) Get(k string, v interface{}) bool {
@@ -327,14 +460,14 @@ func TestTokenCacheRead(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := newRequest("GET", "/foo")
 	req.Header.Set("X-Auth-Token", "1234")
-	val, _ := json.Marshal(Token{ExpiresAt: time.Now().Add(5 * time.Second), IssuedAt: time.Now()})
-	cache := cacheMock{"1234": val}
+	val, _ := json.Marshal(newCachedToken(&Token{ExpiresAt: time.Now().Add(5 * time.Second), IssuedAt: time.Now()}))
+	cache := cacheMock{hashToken("1234"): val}
 
 	h := checkHeaders(t, map[string]string{
 		"X-Identity-Status": "Confirmed",
 	})
 
-	a := Auth{TokenCache: &cache}
+	a := Auth{TokenCache: &cache, OfflineMode: true}
 
 	a.Handler(h).ServeHTTP(rec, req)
 
@@ -362,12 +495,398 @@ func TestTokenCacheWrite(t *testing.T) {
 	})
 	a := Auth{Endpoint: idServer.URL, TokenCache: &cache}
 	a.Handler(h).ServeHTTP(rec, req)
-	var tok Token
-	if err := json.Unmarshal(cache["1234"], &tok); err != nil {
+	var entry cachedToken
+	if err := json.Unmarshal(cache[hashToken("1234")], &entry); err != nil {
 		t.Fatal("token was not cached", err)
 	}
-	if !tok.ExpiresAt.Equal(expectedExpiry) {
+	if tok := entry.Token; !tok.ExpiresAt.Equal(expectedExpiry) {
 		t.Fatalf("cached element has incorrect value. expected %q, got %q", expectedExpiry, tok.ExpiresAt)
 	}
 
 }
+
+func TestCloneRequest(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := newRequest("GET", "/foo")
+	req.Header.Set("X-Project-Id", "p-1234")
+
+	var downstream *http.Request
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream = r
+		w.Write([]byte(ok))
+	})
+
+	a := Auth{CloneRequest: true, OfflineMode: true}
+	a.Handler(h).ServeHTTP(rec, req)
+
+	if downstream == req {
+		t.Fatal("Expected downstream handler to receive a copy of the request")
+	}
+	if status := downstream.Header.Get("X-Identity-Status"); status != "Invalid" {
+		t.Errorf("X-Identity-Status header got %q, expected %q", status, "Invalid")
+	}
+	if v := req.Header.Get("X-Project-Id"); v != "p-1234" {
+		t.Errorf("Original request was modified, X-Project-Id is %q", v)
+	}
+	if v := req.Header.Get("X-Identity-Status"); v != "" {
+		t.Errorf("Original request was modified, X-Identity-Status is %q", v)
+	}
+}
+
+func TestRequireRoles(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := newRequest("GET", "/foo")
+	req.Header.Set("X-Auth-Token", "1234")
+	idServer := identityMock(200, `
+{
+  "token": {
+    "expires_at": "2120-10-08T08:40:33.100Z",
+    "issued_at": "2015-10-08T07:40:33.099Z",
+    "user": {
+      "id": "u-42e54ca0c",
+      "name": "arc",
+      "domain": {
+        "id": "o-testdomain",
+        "name": "testdomain"
+      }
+    }
+  }
+}
+	`)
+	defer idServer.Close()
+	h := checkHeaders(t, map[string]string{
+		"X-Identity-Status": "Invalid",
+		"X-User-Id":         "",
+	})
+	a := Auth{Endpoint: idServer.URL, RequireRoles: true}
+	a.Handler(h).ServeHTTP(rec, req)
+	if body := rec.Body.String(); body != ok {
+		t.Fatalf("wrong body, got %q want %q", body, ok)
+	}
+	if _, err := a.Validate("1234"); err != ErrNoRoles {
+		t.Fatalf("Expected %v, got %v", ErrNoRoles, err)
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := newRequest("GET", "/foo")
+	req.Header.Set("X-Auth-Token", "1234")
+	idServer := identityMock(404, `{"error": {"code": 404, "message": "Could not find token: 1234.", "title": "Not Found"}}`)
+	defer idServer.Close()
+
+	var hookErr error
+	a := Auth{Endpoint: idServer.URL, OnValidationError: func(r *http.Request, err error) {
+		hookErr = err
+	}}
+	a.Handler(okHandler).ServeHTTP(rec, req)
+
+	var kerr *Error
+	if !errors.As(hookErr, &kerr) {
+		t.Fatalf("Expected *Error to be passed to hook, got %#v", hookErr)
+	}
+	if kerr.StatusCode != 404 || kerr.Title != "Not Found" || kerr.Message != "Could not find token: 1234." {
+		t.Errorf("Unexpected error details: %#v", kerr)
+	}
+	if expected := "404 Not Found : Could not find token: 1234."; kerr.Error() != expected {
+		t.Errorf("Expected error %q, got %q", expected, kerr.Error())
+	}
+}
+
+func TestMissingEndpoint(t *testing.T) {
+	defer func() {
+		if r := recover(); r != ErrNoEndpoint {
+			t.Fatalf("Expected panic with %v, got %v", ErrNoEndpoint, r)
+		}
+	}()
+	a := Auth{}
+	a.Handler(okHandler)
+}
+
+func TestOfflineMode(t *testing.T) {
+	a := Auth{OfflineMode: true}
+	a.Handler(okHandler)
+	if _, err := a.Validate("1234"); err != ErrNoEndpoint {
+		t.Fatalf("Expected %v, got %v", ErrNoEndpoint, err)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	var token Token
+	token.User.ID = "u-1"
+	token.User.Name = "arc"
+	token.Project = &Project{ID: "p-1", Name: "Arc"}
+
+	expected := `X-Identity-Status: Confirmed
+X-Is-Admin-Project: True
+X-Project-Domain-Id: 
+X-Project-Domain-Name: 
+X-Project-Id: p-1
+X-Project-Name: Arc
+X-User-Domain-Id: 
+X-User-Domain-Name: 
+X-User-Id: u-1
+X-User-Name: arc
+`
+	if c := token.Canonical(); c != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, c)
+	}
+
+	a := Auth{Headers: MinimalHeaders, HeaderMapper: func(token *Token, header http.Header) {
+		header.Add("X-Roles", "member")
+		header.Add("X-Roles", "reader")
+	}}
+	expected = `X-Identity-Status: Confirmed
+X-Project-Id: p-1
+X-Roles: member, reader
+X-User-Id: u-1
+`
+	if c := a.Canonical(&token); c != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, c)
+	}
+}
+
+func TestAdminProject(t *testing.T) {
+	cases := map[string]string{
+		`{"project": {"id": "p-1", "is_domain": true}, "is_admin_project": false}`: "False",
+		`{"project": {"id": "p-1"}, "is_admin_project": true}`:                     "True",
+		`{"project": {"id": "p-1"}}`:                                               "True",
+		`{"domain": {"id": "d-1"}, "is_admin_project": true}`:                      "",
+	}
+	for payload, expected := range cases {
+		var token Token
+		if err := json.Unmarshal([]byte(payload), &token); err != nil {
+			t.Fatal(err)
+		}
+		if h := token.headers()["X-Is-Admin-Project"]; h != expected {
+			t.Errorf("%s: expected X-Is-Admin-Project %q, got %q", payload, expected, h)
+		}
+		if token.Project != nil && token.Project.IsDomain != strings.Contains(payload, "is_domain") {
+			t.Errorf("%s: expected is_domain to be parsed", payload)
+		}
+	}
+}
+
+func TestShortLivedTokenNotCached(t *testing.T) {
+	cache := cacheMock{}
+	//Token.Valid has a resolution of seconds, so pick an expiry in the next second but less than a second away
+	if now := time.Now(); now.Sub(now.Truncate(time.Second)) < 300*time.Millisecond {
+		time.Sleep(300 * time.Millisecond)
+	}
+	expiry := time.Now().Truncate(time.Second).Add(1100 * time.Millisecond)
+	idServer := identityMock(200, fmt.Sprintf(`{"token": {"expires_at": "%s", "issued_at": "2015-10-08T15:09:11Z"}}`, expiry.Format(time.RFC3339Nano)))
+	defer idServer.Close()
+
+	a := Auth{Endpoint: idServer.URL, TokenCache: &cache}
+	a.Handler(okHandler)
+	if _, err := a.Validate("1234"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := cache[hashToken("1234")]; found {
+		t.Error("Token expiring within a second should not be cached")
+	}
+}
+
+func TestRejectUnauthenticated(t *testing.T) {
+	invalid := identityMock(404, `{"error": {"code": 404, "message": "Could not find token", "title": "Not Found"}}`)
+	defer invalid.Close()
+	unavailable := identityMock(502, ``)
+	defer unavailable.Close()
+
+	cases := []struct {
+		endpoint string
+		token    string
+		status   int
+	}{
+		{invalid.URL, "", 401},
+		{invalid.URL, "1234", 401},
+		{unavailable.URL, "1234", 503},
+	}
+	for _, c := range cases {
+		a := Auth{Endpoint: c.endpoint, RejectUnauthenticated: true}
+		req := newRequest("GET", "/")
+		if c.token != "" {
+			req.Header.Set("X-Auth-Token", c.token)
+		}
+		w := httptest.NewRecorder()
+		a.Handler(okHandler).ServeHTTP(w, req)
+		if w.Code != c.status {
+			t.Errorf("Expected status %d, got %d", c.status, w.Code)
+		}
+		if challenge := w.Header().Get("WWW-Authenticate"); c.status == 401 && challenge != `Keystone uri="`+c.endpoint+`"` {
+			t.Errorf("Unexpected challenge: %q", challenge)
+		}
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Subject-Token") {
+		case "member":
+			io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u"}, "roles": [{"name": "member"}]}}`)
+		case "unavailable":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.RejectUnauthenticated = true
+	a.Routes = []Route{{Pattern: "/admin", Access: TokenRequired, Roles: []string{"admin"}}}
+	a.ErrorHandler = func(w http.ResponseWriter, r *http.Request, status int, err error) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": status, "error": fmt.Sprint(err)})
+	}
+	h := a.Handler(okHandler)
+
+	for _, c := range []struct {
+		path, token string
+		status      int
+		err         string
+	}{
+		{"/", "", 401, "<nil>"},
+		{"/", "invalid", 401, "404 Not Found"},
+		{"/", "unavailable", 503, "502 Bad Gateway"},
+		{"/admin", "member", 403, ErrMissingRole.Error()},
+	} {
+		req := newRequest("GET", c.path)
+		if c.token != "" {
+			req.Header.Set("X-Auth-Token", c.token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		var body struct {
+			Code  int
+			Error string
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Expected JSON error response, got %q", w.Body)
+		}
+		if w.Code != c.status || body.Code != c.status || !strings.HasPrefix(body.Error, c.err) {
+			t.Errorf("Expected status %d with error %q, got %d with %+v", c.status, c.err, w.Code, body)
+		}
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	idServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u"}}}`)
+	}))
+	defer idServer.Close()
+
+	//the certificate of the test server isn't trusted by default
+	a := New(idServer.URL)
+	if _, err := a.Validate("1234"); err == nil {
+		t.Fatal("Expected validation against untrusted endpoint to fail")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(idServer.Certificate())
+	a = &Auth{Endpoint: idServer.URL, TLSConfig: &tls.Config{RootCAs: pool}, Timeout: time.Second}
+	a.ensureDefaults()
+	if a.Client.Timeout != time.Second {
+		t.Errorf("Expected client timeout to be 1s, got %s", a.Client.Timeout)
+	}
+	if _, err := a.Validate("1234"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTTLFunc(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "roles": [{"id": "r-1", "name": "admin"}]}}`)
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.CacheTime = time.Minute
+	a.TTLFunc = func(t *Token) time.Duration {
+		if HasRole("admin")(t) {
+			return 10 * time.Second
+		}
+		return time.Hour
+	}
+	if _, ttl, err := a.load(context.Background(), a.Endpoint, "1234"); err != nil || ttl != 10*time.Second {
+		t.Errorf("Expected ttl of TTLFunc, got %s, %v", ttl, err)
+	}
+	a.TTLFunc = func(*Token) time.Duration { return 0 }
+	if _, ttl, err := a.load(context.Background(), a.Endpoint, "1234"); err != nil || ttl != 0 {
+		t.Errorf("Expected token not to be cached, got %s, %v", ttl, err)
+	}
+}
+
+func TestRemoveAuthToken(t *testing.T) {
+	idServer := identityMock(200, validTokenBody)
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.RemoveAuthToken = true
+	a.Routes = []Route{{Pattern: "/public", Access: Anonymous}}
+	var headers http.Header
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { headers = r.Header.Clone() }))
+
+	for _, path := range []string{"/", "/public"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Auth-Token", "1234")
+		req.Header.Set("X-Service-Token", "5678")
+		req.Header.Set("X-Auth-Token-Hash", "spoofed")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		for _, header := range []string{"X-Auth-Token", "X-Service-Token", "X-Auth-Token-Hash", "X-Service-Token-Hash"} {
+			if v := headers.Get(header); v != "" {
+				t.Errorf("Expected %s to be removed for %s, got %q", header, path, v)
+			}
+		}
+	}
+	if headers.Get("X-Identity-Status") != "Invalid" {
+		t.Error("Expected anonymous route not to be authenticated")
+	}
+
+	a.AuthTokenHash = true
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Auth-Token", "1234")
+	req.Header.Set("X-Service-Token", "5678")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if headers.Get("X-Identity-Status") != "Confirmed" || headers.Get("X-Service-Identity-Status") != "Confirmed" {
+		t.Errorf("Expected tokens to be validated before being removed, got %v", headers)
+	}
+	if v := headers.Get("X-Auth-Token-Hash"); v != hashToken("1234") {
+		t.Errorf("Expected hash of the token, got %q", v)
+	}
+	if v := headers.Get("X-Service-Token-Hash"); v != hashToken("5678") {
+		t.Errorf("Expected hash of the service token, got %q", v)
+	}
+	if headers.Get("X-Auth-Token") != "" {
+		t.Error("Expected token to be removed")
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	now := time.Now().UTC()
+	for _, c := range []struct {
+		name                string
+		issuedAt, expiresAt time.Time
+	}{
+		{"issued in the future", now.Add(3 * time.Second), now.Add(time.Hour)},
+		{"expired", now.Add(-time.Hour), now.Add(-2 * time.Second)},
+	} {
+		idServer := identityMock(200, fmt.Sprintf(`{"token": {"issued_at": %q, "expires_at": %q, "user": {"id": "u"}}}`,
+			c.issuedAt.Format(time.RFC3339Nano), c.expiresAt.Format(time.RFC3339Nano)))
+		defer idServer.Close()
+
+		a := New(idServer.URL)
+		if _, err := a.Validate("1234"); err == nil {
+			t.Errorf("%s: expected token to be rejected without clock skew", c.name)
+		}
+		cache := ttlCache{}
+		a.TokenCache = cache
+		a.ClockSkew = 5 * time.Second
+		limit := time.Until(c.expiresAt) + a.ClockSkew
+		if _, err := a.Validate("1234"); err != nil {
+			t.Errorf("%s: expected token to be accepted with clock skew, got %v", c.name, err)
+		}
+		if ttl := cache[hashToken("1234")]; ttl > limit {
+			t.Errorf("%s: expected token not to be cached beyond its expiry and the clock skew, got %s", c.name, ttl)
+		}
+	}
+}
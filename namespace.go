@@ -0,0 +1,63 @@
+package keystone
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// NamespacedCache isolates the entries of one tenant (e.g. Keystone endpoint or virtual host)
+// within a cache shared by multiple Auth instances, preventing cache entries from bleeding between tenants.
+//
+//	shared := memory.New(time.Minute)
+//	regionOne := keystone.New("https://keystone.region-one:5000/v3")
+//	regionOne.TokenCache = keystone.NewNamespacedCache(shared, "region-one")
+type NamespacedCache struct {
+	cache      Cache
+	namespace  string
+	generation atomic.Uint64
+}
+
+// NewNamespacedCache returns a cache storing its entries in c with keys prefixed by namespace
+func NewNamespacedCache(c Cache, namespace string) *NamespacedCache {
+	return &NamespacedCache{cache: c, namespace: namespace}
+}
+
+// Invalidate drops all entries of the namespace.
+// Invalidated entries are not removed from the underlying cache but are no longer visible and expire eventually.
+// Invalidation only affects this NamespacedCache, other processes sharing the same cache are not affected.
+func (n *NamespacedCache) Invalidate() {
+	n.generation.Add(1)
+}
+
+func (n *NamespacedCache) key(key string) string {
+	return n.namespace + "/" + strconv.FormatUint(n.generation.Load(), 10) + "/" + key
+}
+
+// Set stores a value with the given ttl
+func (n *NamespacedCache) Set(key string, value interface{}, ttl time.Duration) {
+	n.cache.Set(n.key(key), value, ttl)
+}
+
+// Get retrieves a value previously stored in the cache
+func (n *NamespacedCache) Get(key string, value interface{}) bool {
+	return n.cache.Get(n.key(key), value)
+}
+
+// SetCtx stores a value with the given ttl, see CacheCtx
+func (n *NamespacedCache) SetCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+	cacheSet(ctx, n.cache, n.key(key), value, ttl)
+}
+
+// GetCtx retrieves a value previously stored in the cache, see CacheCtx
+func (n *NamespacedCache) GetCtx(ctx context.Context, key string, value interface{}) bool {
+	return cacheGet(ctx, n.cache, n.key(key), value)
+}
+
+// Delete removes an entry of the namespace if the underlying cache implements Deleter
+func (n *NamespacedCache) Delete(key string) {
+	if d, ok := n.cache.(Deleter); ok {
+		d.Delete(n.key(key))
+	}
+}
@@ -0,0 +1,31 @@
+package keystone
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNamespacedCache(t *testing.T) {
+	shared := &cacheMock{}
+	one := NewNamespacedCache(shared, "one")
+	two := NewNamespacedCache(shared, "two")
+
+	one.Set("1234", "one", time.Minute)
+	two.Set("1234", "two", time.Minute)
+
+	var v string
+	if !one.Get("1234", &v) || v != "one" {
+		t.Errorf("Expected %q, got %q", "one", v)
+	}
+	if !two.Get("1234", &v) || v != "two" {
+		t.Errorf("Expected %q, got %q", "two", v)
+	}
+
+	one.Invalidate()
+	if one.Get("1234", &v) {
+		t.Error("Expected entry to be invalidated")
+	}
+	if !two.Get("1234", &v) || v != "two" {
+		t.Error("Invalidation affected other namespace")
+	}
+}
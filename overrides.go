@@ -0,0 +1,74 @@
+package keystone
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrScope is returned for tokens not having the scope required by ValidationOptions.RequireScope
+var ErrScope = errors.New("Token doesn't have the required scope")
+
+// ScopeRequirement restricts the scope of accepted tokens
+type ScopeRequirement int
+
+const (
+	//AnyScope accepts all tokens
+	AnyScope ScopeRequirement = iota
+	//ProjectScope only accepts project scoped tokens
+	ProjectScope
+	//DomainScope only accepts domain scoped tokens
+	DomainScope
+	//Unscoped only accepts unscoped tokens
+	Unscoped
+	//SystemScope only accepts system scoped tokens
+	SystemScope
+)
+
+func (s ScopeRequirement) check(t *Token) error {
+	switch {
+	case s == ProjectScope && t.Project == nil,
+		s == DomainScope && t.Domain == nil,
+		s == Unscoped && (t.Project != nil || t.Domain != nil || t.System != nil),
+		s == SystemScope && !t.systemScoped():
+		return ErrScope
+	}
+	return nil
+}
+
+// ValidationOptions override how the token of a single request is validated.
+// Upstream middleware (e.g. a router) sets them with WithValidationOptions to vary the strictness
+// per route without creating multiple handlers:
+//
+//	func adminRoutes(h http.Handler) http.Handler {
+//		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//			ctx := keystone.WithValidationOptions(r.Context(), keystone.ValidationOptions{SkipCache: true, RequireScope: keystone.ProjectScope})
+//			h.ServeHTTP(w, r.WithContext(ctx))
+//		})
+//	}
+//	http.ListenAndServe(":3000", adminRoutes(auth.Handler(myApp)))
+type ValidationOptions struct {
+	//Always validate the token against Keystone instead of using a cached token context.
+	//The fresh result is still written to the TokenCache.
+	SkipCache bool
+	//Treat tokens without the required scope as invalid
+	RequireScope ScopeRequirement
+	//Validate the token against this Keystone v3 endpoint instead of Auth.Endpoint.
+	//Tokens validated against another endpoint are not cached.
+	Endpoint string
+	//Cache the token context for this long instead of Auth.CacheTime when writing the TokenCache
+	CacheTime time.Duration
+	//Overrides Auth.ShedQueueDepth, e.g. a lower depth sheds low priority requests first
+	ShedQueueDepth int
+}
+
+// WithValidationOptions returns a context carrying per request validation options honored by
+// Auth.Handler and Auth.ValidateContext
+func WithValidationOptions(ctx context.Context, opts ValidationOptions) context.Context {
+	return context.WithValue(ctx, validationOptionsKey, opts)
+}
+
+func validationOptionsFromContext(ctx context.Context) ValidationOptions {
+	opts, _ := ctx.Value(validationOptionsKey).(ValidationOptions)
+	return opts
+}
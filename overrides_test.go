@@ -0,0 +1,55 @@
+package keystone
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestValidationOptions(t *testing.T) {
+	var primaryCalls, alternateCalls int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryCalls, 1)
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z", "project": {"id": "p-1"}}}`)
+	}))
+	defer primary.Close()
+	alternate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&alternateCalls, 1)
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z"}}`)
+	}))
+	defer alternate.Close()
+
+	cache := cacheMock{}
+	a := Auth{Endpoint: primary.URL, TokenCache: &cache}
+	a.Handler(okHandler)
+	validate := func(opts ValidationOptions) (*Token, error) {
+		return a.ValidateContext(WithValidationOptions(context.Background(), opts), "1234")
+	}
+
+	if _, err := validate(ValidationOptions{RequireScope: ProjectScope}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := validate(ValidationOptions{RequireScope: DomainScope}); err != ErrScope {
+		t.Errorf("Expected ErrScope, got %v", err)
+	}
+	if n := atomic.LoadInt32(&primaryCalls); n != 1 {
+		t.Errorf("Expected cached token to be used, got %d calls", n)
+	}
+	if _, err := validate(ValidationOptions{SkipCache: true}); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&primaryCalls); n != 2 {
+		t.Errorf("Expected cache to be skipped, got %d calls", n)
+	}
+
+	token, err := validate(ValidationOptions{Endpoint: alternate.URL, RequireScope: Unscoped})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Issuer != alternate.URL || atomic.LoadInt32(&alternateCalls) != 1 {
+		t.Errorf("Expected token to be validated by alternate endpoint, got issuer %s", token.Issuer)
+	}
+}
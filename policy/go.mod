@@ -0,0 +1,8 @@
+module github.com/databus23/keystone/policy
+
+go 1.22
+
+require (
+	github.com/databus23/keystone v0.1.0
+	gopkg.in/yaml.v3 v3.0.1
+)
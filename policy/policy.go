@@ -0,0 +1,158 @@
+// Package policy evaluates oslo.policy style authorization rules for https://github.com/databus23/keystone
+//
+// Policy files map actions to rules, using the same syntax as OpenStack services:
+//
+//	{
+//	  "admin_required": "role:admin",
+//	  "owner": "user_id:%(user_id)s or project_id:%(project_id)s",
+//	  "servers:list": "",
+//	  "servers:delete": "rule:admin_required or rule:owner"
+//	}
+//
+// Rules are evaluated against the validated token of a request and a target describing the resource
+// acted upon, e.g. the owner of a server:
+//
+//	p, err := policy.Load("/etc/myservice/policy.yaml")
+//	...
+//	mux.Handle("/servers/", p.Require(deleteHandler, "servers:delete", func(r *http.Request) map[string]string {
+//		return map[string]string{"project_id": ownerOf(r)}
+//	}))
+//	http.ListenAndServe(":3000", auth.Handler(mux))
+//
+// Supported checks are @ (always), ! (never), rule:<name>, role:<name> and generic <attribute>:<value>
+// checks. Values can reference the target using %(key)s or be quoted literals. Available token
+// attributes are user_id, user_name, user_domain_id, user_domain_name, project_id, project_name,
+// project_domain_id, project_domain_name, domain_id, domain_name and system_scope. Checks are combined using
+// and, or, not and parentheses. Actions without a rule are evaluated using the rule named default,
+// or denied if there is none.
+package policy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/databus23/keystone"
+	"gopkg.in/yaml.v3"
+)
+
+// Policy contains the parsed rules of a policy file
+type Policy struct {
+	rules map[string]check
+}
+
+// Load reads a JSON or YAML policy file
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse parses JSON or YAML policy rules
+func Parse(data []byte) (*Policy, error) {
+	var raw map[string]string
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	p := &Policy{rules: make(map[string]check, len(raw))}
+	for name, rule := range raw {
+		c, err := parseRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+		p.rules[name] = c
+	}
+	for name := range p.rules {
+		if err := p.resolve(name, map[string]bool{}); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+	}
+	return p, nil
+}
+
+// resolve ensures all rules referenced by the named rule exist and don't form a cycle
+func (p *Policy) resolve(name string, visiting map[string]bool) error {
+	if visiting[name] {
+		return fmt.Errorf("cyclic reference to rule %s", name)
+	}
+	c, ok := p.rules[name]
+	if !ok {
+		return fmt.Errorf("undefined rule %s", name)
+	}
+	visiting[name] = true
+	defer delete(visiting, name)
+	for _, ref := range c.refs(nil) {
+		if err := p.resolve(ref, visiting); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Enforce returns if the token is authorized to perform the action on the target
+func (p *Policy) Enforce(action string, token *keystone.Token, target map[string]string) bool {
+	c, ok := p.rules[action]
+	if !ok {
+		if c, ok = p.rules["default"]; !ok {
+			return false
+		}
+	}
+	return c.eval(&env{policy: p, token: token, creds: credentials(token), target: target})
+}
+
+// Rule returns a keystone.Rule for the action on a fixed target, e.g. for use with keystone.Introspection
+func (p *Policy) Rule(action string, target map[string]string) keystone.Rule {
+	return func(t *keystone.Token) bool { return p.Enforce(action, t, target) }
+}
+
+// Require returns a handler only accepting requests authorized for the action. The target of the request
+// is determined by target, which may be nil. Requests without a valid token are rejected with 401,
+// unauthorized ones with 403. It must be placed behind the handler returned by keystone.Auth.Handler.
+func (p *Policy) Require(h http.Handler, action string, target func(r *http.Request) map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := keystone.TokenFromContext(r.Context())
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		var t map[string]string
+		if target != nil {
+			t = target(r)
+		}
+		if !p.Enforce(action, token, t) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// credentials returns the token attributes available to generic checks
+func credentials(t *keystone.Token) map[string]string {
+	creds := map[string]string{
+		"user_id":          t.User.ID,
+		"user_name":        t.User.Name,
+		"user_domain_id":   t.User.Domain.ID,
+		"user_domain_name": t.User.Domain.Name,
+	}
+	if t.Project != nil {
+		creds["project_id"] = t.Project.ID
+		creds["project_name"] = t.Project.Name
+		creds["project_domain_id"] = t.Project.Domain.ID
+		creds["project_domain_name"] = t.Project.Domain.Name
+	}
+	if t.Domain != nil {
+		creds["domain_id"] = t.Domain.ID
+		creds["domain_name"] = t.Domain.Name
+	}
+	if t.System != nil && t.System.All {
+		creds["system_scope"] = "all"
+	}
+	return creds
+}
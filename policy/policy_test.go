@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/databus23/keystone"
+)
+
+const testPolicy = `
+admin_required: role:admin
+owner: user_id:%(user_id)s or project_id:%(project_id)s
+default: rule:admin_required
+servers:list: ""
+servers:show: rule:admin_required or rule:owner
+servers:delete: rule:admin_required or (rule:owner and not role:reader)
+servers:lock: "!"
+servers:migrate: system_scope:all and role:admin
+`
+
+func newToken(user, project string, roles ...string) *keystone.Token {
+	t := &keystone.Token{Project: &keystone.Project{ID: project}}
+	t.User.ID = user
+	for _, r := range roles {
+		t.Roles = append(t.Roles, struct {
+			ID   string
+			Name string
+		}{Name: r})
+	}
+	return t
+}
+
+func TestEnforce(t *testing.T) {
+	p, err := Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := map[string]string{"user_id": "u-1", "project_id": "p-1"}
+	systemAdmin := newToken("u-1", "", "admin")
+	systemAdmin.Project, systemAdmin.System = nil, &keystone.System{All: true}
+	cases := []struct {
+		action string
+		token  *keystone.Token
+		target map[string]string
+		allow  bool
+	}{
+		{"servers:list", newToken("u-2", "p-2"), nil, true},
+		{"servers:show", newToken("u-1", "p-2"), owner, true},
+		{"servers:show", newToken("u-2", "p-1"), owner, true},
+		{"servers:show", newToken("u-2", "p-2"), owner, false},
+		{"servers:show", newToken("u-2", "p-2", "Admin"), owner, true},
+		{"servers:show", newToken("u-1", "p-1"), nil, false},
+		{"servers:delete", newToken("u-1", "p-1", "member"), owner, true},
+		{"servers:delete", newToken("u-1", "p-1", "reader"), owner, false},
+		{"servers:lock", newToken("u-1", "p-1", "admin"), owner, false},
+		{"servers:create", newToken("u-1", "p-1", "member"), owner, false},
+		{"servers:create", newToken("u-1", "p-1", "admin"), owner, true},
+		{"servers:migrate", newToken("u-1", "p-1", "admin"), nil, false},
+		{"servers:migrate", systemAdmin, nil, true},
+	}
+	for _, c := range cases {
+		if allow := p.Enforce(c.action, c.token, c.target); allow != c.allow {
+			t.Errorf("%s for %s %v with target %v: expected %t, got %t", c.action, c.token.User.ID, c.token.Roles, c.target, c.allow, allow)
+		}
+	}
+
+	p, _ = Parse([]byte(`{"servers:list": "role:admin"}`))
+	if p.Enforce("servers:create", newToken("u-1", "p-1", "admin"), nil) {
+		t.Error("Expected unknown action without default rule to be denied")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, policy := range []string{
+		`{"a": "rule:b"}`,
+		`{"a": "rule:b", "b": "not rule:a"}`,
+		`{"a": "role:admin or"}`,
+		`{"a": "(role:admin"}`,
+		`{"a": "role:admin)"}`,
+		`{"a": "admin"}`,
+		`{"a": "http://example.com/check"}`,
+		`[not, a, policy]`,
+	} {
+		if _, err := Parse([]byte(policy)); err == nil {
+			t.Errorf("Expected error for %s", policy)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	os.WriteFile(path, []byte(`{"servers:list": "role:reader or role:admin"}`), 0600)
+	p, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Enforce("servers:list", newToken("u-1", "p-1", "reader"), nil) {
+		t.Error("Expected reader to be allowed to list servers")
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected error for missing policy file")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var token keystone.Token
+		token.User.ID = r.Header.Get("X-Subject-Token")
+		token.ExpiresAt = token.ExpiresAt.AddDate(2120, 0, 0)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"token": token})
+	}))
+	defer idServer.Close()
+
+	p, _ := Parse([]byte(testPolicy))
+	auth := keystone.New(idServer.URL)
+	h := auth.Handler(p.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "deleted")
+	}), "servers:delete", func(r *http.Request) map[string]string {
+		return map[string]string{"user_id": r.URL.Query().Get("owner")}
+	}))
+
+	for _, c := range []struct {
+		token string
+		code  int
+	}{{"", 401}, {"u-1", 200}, {"u-2", 403}} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("DELETE", "/servers/1?owner=u-1", nil)
+		if c.token != "" {
+			req.Header.Set("X-Auth-Token", c.token)
+		}
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.code {
+			t.Errorf("Token %q: expected status %d, got %d", c.token, c.code, rec.Code)
+		}
+	}
+}
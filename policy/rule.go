@@ -0,0 +1,281 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/databus23/keystone"
+)
+
+// env is the context a rule is evaluated in
+type env struct {
+	policy *Policy
+	token  *keystone.Token
+	creds  map[string]string
+	target map[string]string
+}
+
+// check is a parsed rule expression
+type check interface {
+	eval(e *env) bool
+	//refs appends the names of referenced rules
+	refs(names []string) []string
+}
+
+type constCheck bool
+
+func (c constCheck) eval(*env) bool               { return bool(c) }
+func (c constCheck) refs(names []string) []string { return names }
+
+type notCheck struct{ check }
+
+func (c notCheck) eval(e *env) bool { return !c.check.eval(e) }
+
+type andCheck []check
+
+func (c andCheck) eval(e *env) bool {
+	for _, sub := range c {
+		if !sub.eval(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c andCheck) refs(names []string) []string {
+	for _, sub := range c {
+		names = sub.refs(names)
+	}
+	return names
+}
+
+type orCheck []check
+
+func (c orCheck) eval(e *env) bool {
+	for _, sub := range c {
+		if sub.eval(e) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c orCheck) refs(names []string) []string {
+	return andCheck(c).refs(names)
+}
+
+// ruleCheck evaluates another rule of the policy
+type ruleCheck string
+
+func (c ruleCheck) eval(e *env) bool             { return e.policy.rules[string(c)].eval(e) }
+func (c ruleCheck) refs(names []string) []string { return append(names, string(c)) }
+
+// roleCheck matches if the token has the role
+type roleCheck struct{ match value }
+
+func (c roleCheck) eval(e *env) bool {
+	role, ok := c.match.resolve(e.target)
+	if !ok {
+		return false
+	}
+	for _, r := range e.token.Roles {
+		if strings.EqualFold(r.Name, role) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c roleCheck) refs(names []string) []string { return names }
+
+// genericCheck compares a token attribute or literal with a value
+type genericCheck struct {
+	kind  string
+	match value
+}
+
+func (c genericCheck) eval(e *env) bool {
+	left, ok := e.creds[c.kind]
+	if lit, quoted := unquote(c.kind); quoted {
+		left, ok = lit, true
+	}
+	if !ok {
+		return false
+	}
+	right, ok := c.match.resolve(e.target)
+	return ok && left == right
+}
+
+func (c genericCheck) refs(names []string) []string { return names }
+
+var substitution = regexp.MustCompile(`%\(([^)]+)\)s`)
+
+// value is the right hand side of a check, either a literal or referencing the target using %(key)s
+type value string
+
+func (v value) resolve(target map[string]string) (string, bool) {
+	if lit, quoted := unquote(string(v)); quoted {
+		return lit, true
+	}
+	ok := true
+	s := substitution.ReplaceAllStringFunc(string(v), func(m string) string {
+		t, found := target[substitution.FindStringSubmatch(m)[1]]
+		ok = ok && found
+		return t
+	})
+	return s, ok
+}
+
+func unquote(s string) (string, bool) {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1], true
+	}
+	return s, false
+}
+
+var errUnbalanced = errors.New("unbalanced parentheses")
+
+// parseRule parses a rule expression. Like oslo.policy "not" binds tighter than "and", which binds tighter than "or".
+func parseRule(rule string) (check, error) {
+	p := &parser{tokens: tokenize(rule)}
+	if len(p.tokens) == 0 {
+		return constCheck(true), nil
+	}
+	c, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		if p.tokens[p.pos] == ")" {
+			return nil, errUnbalanced
+		}
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return c, nil
+}
+
+// tokenize splits a rule into parentheses and words
+func tokenize(rule string) []string {
+	var tokens []string
+	for _, word := range strings.Fields(rule) {
+		for strings.HasPrefix(word, "(") {
+			tokens = append(tokens, "(")
+			word = word[1:]
+		}
+		//closing parentheses not belonging to a %(key)s substitution
+		closing := 0
+		for strings.HasSuffix(word, ")") && strings.Count(word, ")") > strings.Count(word, "(") {
+			closing++
+			word = word[:len(word)-1]
+		}
+		if word != "" {
+			tokens = append(tokens, word)
+		}
+		for ; closing > 0; closing-- {
+			tokens = append(tokens, ")")
+		}
+	}
+	return tokens
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) or() (check, error) {
+	checks, err := p.list("or", p.and)
+	if err != nil {
+		return nil, err
+	}
+	if len(checks) == 1 {
+		return checks[0], nil
+	}
+	return orCheck(checks), nil
+}
+
+func (p *parser) and() (check, error) {
+	checks, err := p.list("and", p.not)
+	if err != nil {
+		return nil, err
+	}
+	if len(checks) == 1 {
+		return checks[0], nil
+	}
+	return andCheck(checks), nil
+}
+
+// list parses operands separated by the operator
+func (p *parser) list(op string, operand func() (check, error)) ([]check, error) {
+	var checks []check
+	for {
+		c, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, c)
+		if !strings.EqualFold(p.next(), op) {
+			return checks, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) not() (check, error) {
+	if strings.EqualFold(p.next(), "not") {
+		p.pos++
+		c, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return notCheck{c}, nil
+	}
+	return p.atom()
+}
+
+func (p *parser) atom() (check, error) {
+	token := p.next()
+	p.pos++
+	switch token {
+	case "":
+		return nil, errors.New("unexpected end of rule")
+	case "(":
+		c, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, errUnbalanced
+		}
+		p.pos++
+		return c, nil
+	case ")":
+		return nil, errUnbalanced
+	case "@":
+		return constCheck(true), nil
+	case "!":
+		return constCheck(false), nil
+	}
+	kind, match, ok := strings.Cut(token, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid check %q", token)
+	}
+	switch kind {
+	case "rule":
+		return ruleCheck(match), nil
+	case "role":
+		return roleCheck{value(match)}, nil
+	case "http", "https":
+		return nil, fmt.Errorf("unsupported check %q", token)
+	}
+	return genericCheck{kind: kind, match: value(match)}, nil
+}
@@ -0,0 +1,58 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	cases := map[string][]string{
+		"":                                nil,
+		"role:admin or rule:owner":        {"role:admin", "or", "rule:owner"},
+		"((role:a or role:b) and not !)":  {"(", "(", "role:a", "or", "role:b", ")", "and", "not", "!", ")"},
+		"(project_id:%(project_id)s)":     {"(", "project_id:%(project_id)s", ")"},
+		"user_id:%(target.user.id)s":      {"user_id:%(target.user.id)s"},
+		"'member':%(role)s and (role:a))": {"'member':%(role)s", "and", "(", "role:a", ")", ")"},
+	}
+	for rule, expected := range cases {
+		if tokens := tokenize(rule); !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("%q: expected %q, got %q", rule, expected, tokens)
+		}
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	//not binds tighter than and, which binds tighter than or
+	c, err := parseRule("@ or ! and not @")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.eval(&env{}) {
+		t.Error("Expected @ or (! and (not @)) to be true")
+	}
+	c, _ = parseRule("(@ or !) and not @")
+	if c.eval(&env{}) {
+		t.Error("Expected (@ or !) and (not @) to be false")
+	}
+}
+
+func TestValueResolve(t *testing.T) {
+	target := map[string]string{"project_id": "p-1", "target.user.id": "u-1"}
+	cases := []struct {
+		value    value
+		expected string
+		ok       bool
+	}{
+		{"%(project_id)s", "p-1", true},
+		{"%(target.user.id)s", "u-1", true},
+		{"prefix-%(project_id)s", "prefix-p-1", true},
+		{"%(missing)s", "", false},
+		{"'literal'", "literal", true},
+		{"True", "True", true},
+	}
+	for _, c := range cases {
+		if s, ok := c.value.resolve(target); s != c.expected || ok != c.ok {
+			t.Errorf("%s: expected %q, %t, got %q, %t", c.value, c.expected, c.ok, s, ok)
+		}
+	}
+}
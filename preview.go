@@ -0,0 +1,32 @@
+package keystone
+
+import (
+	"context"
+	"net/http"
+)
+
+// PreviewHeaders validates a token and returns the identity headers the middleware would inject
+// into a request carrying it under the current configuration (routes aren't taken into account).
+// This is useful for tests and debugging tools.
+func (a *Auth) PreviewHeaders(ctx context.Context, authToken string) (map[string]string, error) {
+	token, err := a.ValidateContext(ctx, authToken)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	a.setHeaders(header, token, nil)
+	headers := make(map[string]string, len(header))
+	for k := range header {
+		headers[k] = header.Get(k)
+	}
+	return headers, nil
+}
+
+// Canonical returns a canonical serialization of the identity headers the middleware injects for token under
+// the current configuration, including Headers and HeaderMapper (routes aren't taken into account).
+// See Token.Canonical for the format.
+func (a *Auth) Canonical(token *Token) string {
+	header := http.Header{}
+	a.setHeaders(header, token, nil)
+	return canonicalHeaders(header)
+}
@@ -0,0 +1,44 @@
+package keystone
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestPreviewHeaders(t *testing.T) {
+	idServer := identityMock(200, `
+{
+  "token": {
+    "expires_at": "2120-10-09T15:09:11.727Z",
+    "issued_at": "2015-10-08T15:09:11.727Z",
+    "user": {"id": "u-1", "name": "arc", "domain": {"id": "d-1", "name": "testdomain"}},
+    "domain": {"id": "d-1", "name": "testdomain"},
+    "roles": [{"id": "r-member", "name": "member"}],
+    "audit_ids": ["VcxU2JYqT8OzfUVvrjEITQ", "qNUTIJntTzO1-XUk5STybw"]
+  }
+}`)
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	headers, err := a.PreviewHeaders(context.Background(), "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"X-Identity-Status":  "Confirmed",
+		"X-User-Id":          "u-1",
+		"X-User-Name":        "arc",
+		"X-User-Domain-Id":   "d-1",
+		"X-User-Domain-Name": "testdomain",
+		"X-Domain-Id":        "d-1",
+		"X-Domain-Name":      "testdomain",
+		"X-Roles":            "member",
+		"X-Role-Ids":         "r-member",
+		"X-Audit-Ids":        "VcxU2JYqT8OzfUVvrjEITQ,qNUTIJntTzO1-XUk5STybw",
+		"X-Token-Expires-At": "2120-10-09T15:09:11Z",
+	}
+	if !reflect.DeepEqual(headers, expected) {
+		t.Errorf("Expected %v, got %v", expected, headers)
+	}
+}
@@ -0,0 +1,113 @@
+package keystone
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// EndpointStatus is the result of probing a Keystone node
+type EndpointStatus struct {
+	Endpoint string
+	//If the node answered without a 5xx status
+	Healthy bool
+	//Latency of the probe
+	Latency   time.Duration
+	CheckedAt time.Time
+	//Error of an unhealthy node
+	Err error
+}
+
+// ProbeEndpoints checks the health of Endpoint and all Endpoints concurrently using Keystone's lightweight
+// version discovery. Unhealthy nodes are avoided by failover until they pass a probe, healthy ones are marked
+// up again and ranked by their latency. Usually called periodically using WatchEndpoints.
+func (a *Auth) ProbeEndpoints(ctx context.Context) []EndpointStatus {
+	all := append([]string{a.Endpoint}, a.Endpoints...)
+	results := make([]EndpointStatus, len(all))
+	var wg sync.WaitGroup
+	for i, node := range all {
+		wg.Add(1)
+		go func(i int, node string) {
+			defer wg.Done()
+			results[i] = a.probe(ctx, node)
+		}(i, node)
+	}
+	wg.Wait()
+	return results
+}
+
+func (a *Auth) probe(ctx context.Context, node string) EndpointStatus {
+	s := EndpointStatus{Endpoint: node, CheckedAt: time.Now()}
+	req, err := http.NewRequestWithContext(ctx, "GET", node, nil)
+	if err == nil {
+		req.Header.Set("User-Agent", a.UserAgent)
+		var r *http.Response
+		if r, err = a.Client.Do(req); err == nil {
+			r.Body.Close()
+			if r.StatusCode >= 500 {
+				err = &Error{StatusCode: r.StatusCode, Status: r.Status}
+			}
+		}
+	}
+	s.Latency = time.Since(s.CheckedAt)
+	s.Healthy, s.Err = err == nil, err
+	if ctx.Err() != nil {
+		return s
+	}
+
+	if s.Healthy {
+		a.pool.markUp(node)
+	} else {
+		a.pool.markDown(node, a.probeDownTime())
+		a.log(ctx, slog.LevelWarn, "Keystone node failed probe", "node", node, "error", err)
+	}
+	a.pool.mu.Lock()
+	if a.pool.probes == nil {
+		a.pool.probes = map[string]EndpointStatus{}
+	}
+	a.pool.probes[node] = s
+	a.pool.mu.Unlock()
+	return s
+}
+
+// probeDownTime is how long a node failing a probe is avoided. Nodes failing probes stay down until the
+// next probe, unless a request succeeded in the meantime.
+func (a *Auth) probeDownTime() time.Duration {
+	if d := time.Duration(a.pool.probeInterval.Load()); d > 0 {
+		return d + nodeDownTime
+	}
+	return nodeDownTime
+}
+
+// EndpointHealth returns the results of the last probe of each node, see ProbeEndpoints
+func (a *Auth) EndpointHealth() []EndpointStatus {
+	a.pool.mu.Lock()
+	defer a.pool.mu.Unlock()
+	var results []EndpointStatus
+	for _, node := range append([]string{a.Endpoint}, a.Endpoints...) {
+		if s, ok := a.pool.probes[node]; ok {
+			results = append(results, s)
+		}
+	}
+	return results
+}
+
+// WatchEndpoints probes the Keystone nodes every interval until ctx is done, keeping standby nodes warm
+// so requests fail over to healthy nodes right away:
+//
+//	go auth.WatchEndpoints(ctx, 10*time.Second)
+func (a *Auth) WatchEndpoints(ctx context.Context, interval time.Duration) {
+	a.pool.probeInterval.Store(int64(interval))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		a.ProbeEndpoints(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
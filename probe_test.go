@@ -0,0 +1,72 @@
+package keystone
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProbeEndpoints(t *testing.T) {
+	down := httptest.NewServer(nil)
+	down.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fast.Close()
+
+	a := New(down.URL)
+	a.Endpoints = []string{failing.URL, slow.URL, fast.URL}
+	results := a.ProbeEndpoints(context.Background())
+	for i, healthy := range []bool{false, false, true, true} {
+		if results[i].Healthy != healthy || (results[i].Err == nil) != healthy {
+			t.Errorf("Expected %s to be healthy=%t, got %+v", results[i].Endpoint, healthy, results[i])
+		}
+	}
+	if h := a.EndpointHealth(); len(h) != 4 || h[3].Endpoint != fast.URL {
+		t.Errorf("Unexpected endpoint health %+v", h)
+	}
+
+	//the primary is skipped right away and the faster standby is preferred for failover
+	for i := 0; i < 4; i++ {
+		nodes := a.nodes(a.Endpoint)
+		if nodes[0] == down.URL || nodes[0] == failing.URL || nodes[1] == down.URL || nodes[1] == failing.URL {
+			t.Errorf("Expected probed down nodes to be tried last, got %v", nodes)
+		}
+	}
+	a.pool.next.Store(0)
+	if nodes := a.nodes(a.Endpoint); nodes[0] != slow.URL || nodes[1] != fast.URL {
+		t.Errorf("Expected round-robin node followed by ranked nodes, got %v", nodes)
+	}
+	a.pool.next.Store(3)
+	if nodes := a.nodes(a.Endpoint); nodes[0] != fast.URL || nodes[1] != slow.URL {
+		t.Errorf("Expected round-robin node followed by ranked nodes, got %v", nodes)
+	}
+}
+
+func TestWatchEndpoints(t *testing.T) {
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer node.Close()
+	a := New(node.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.WatchEndpoints(ctx, time.Hour)
+		close(done)
+	}()
+	for len(a.EndpointHealth()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	if a.probeDownTime() != time.Hour+nodeDownTime {
+		t.Errorf("Expected nodes to stay down until the next probe, got %s", a.probeDownTime())
+	}
+}
@@ -0,0 +1,152 @@
+package keystone
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned for validations exceeding Auth.ValidationRate or Auth.ValidationRatePerIP
+var ErrRateLimited = errors.New("Validation rate limit exceeded")
+
+// RateLimitOverflow selects how requests are answered whose validation exceeded the rate limit
+type RateLimitOverflow int
+
+const (
+	//RateLimitUnavailable treats rate limited validations like Keystone being unavailable: requests are passed on
+	//as Invalid, stale tokens are served if StaleCacheTime is set and rejected requests get 503. This is the default.
+	RateLimitUnavailable RateLimitOverflow = iota
+	//RateLimitTooManyRequests rejects requests with 429 Too Many Requests if a token is required
+	RateLimitTooManyRequests
+	//RateLimitInvalid treats the token as invalid, rejected requests get 401
+	RateLimitInvalid
+)
+
+// maxRateLimitedIPs bounds the number of per ip buckets, the least recently used one is evicted once it is reached
+const maxRateLimitedIPs = 10000
+
+// tokenBucket is a token bucket refilled at rate tokens per second up to burst tokens
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket and takes a token if available
+func (b *tokenBucket) take(now time.Time, rate float64, burst int) bool {
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+	} else {
+		b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// rateLimiter limits the rate of validations sent to Keystone globally and per client ip
+type rateLimiter struct {
+	mu     sync.Mutex
+	global tokenBucket
+	ips    map[string]*list.Element
+	lru    *list.List
+}
+
+type ipBucket struct {
+	tokenBucket
+	ip string
+}
+
+// allowValidation reports whether a validation for a request from ip may be sent to Keystone.
+// Validations not caused by a request (e.g. refresh-ahead) have an empty ip and are only limited globally.
+func (a *Auth) allowValidation(ip string) bool {
+	if a.ValidationRate <= 0 && a.ValidationRatePerIP <= 0 {
+		return true
+	}
+	l := &a.rateLimiter
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if a.ValidationRatePerIP > 0 && ip != "" {
+		b := l.bucket(rateLimitKey(ip))
+		if !b.take(now, a.ValidationRatePerIP, burstOf(a.ValidationRatePerIP, a.ValidationBurstPerIP)) {
+			return false
+		}
+	}
+	if a.ValidationRate > 0 && !l.global.take(now, a.ValidationRate, burstOf(a.ValidationRate, a.ValidationBurst)) {
+		return false
+	}
+	return true
+}
+
+// bucket returns the bucket of ip. If maxRateLimitedIPs is reached the least recently used bucket is evicted,
+// so clients cycling through addresses can't grow the limiter. Must be called with l.mu held.
+func (l *rateLimiter) bucket(ip string) *tokenBucket {
+	if l.ips == nil {
+		l.ips = map[string]*list.Element{}
+		l.lru = list.New()
+	}
+	if e, ok := l.ips[ip]; ok {
+		l.lru.MoveToFront(e)
+		return &e.Value.(*ipBucket).tokenBucket
+	}
+	if l.lru.Len() >= maxRateLimitedIPs {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.ips, oldest.Value.(*ipBucket).ip)
+	}
+	b := &ipBucket{ip: ip}
+	l.ips[ip] = l.lru.PushFront(b)
+	return &b.tokenBucket
+}
+
+// rateLimitKey aggregates IPv6 addresses by their /64 prefix, which usually belongs to a single client
+func rateLimitKey(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || !addr.Is6() || addr.Is4In6() {
+		return ip
+	}
+	prefix, _ := addr.Prefix(64)
+	return prefix.String()
+}
+
+// burstOf defaults the burst to the rate per second, but at least 1
+func burstOf(rate float64, burst int) int {
+	if burst > 0 {
+		return burst
+	}
+	return int(math.Max(1, math.Ceil(rate)))
+}
+
+// clientIP returns the address of the client of a request, see Auth.ClientIP
+func (a *Auth) clientIP(req *http.Request) string {
+	if a.ClientIP != nil {
+		return a.ClientIP(req)
+	}
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return host
+	}
+	return req.RemoteAddr
+}
+
+func withClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey, ip)
+}
+
+func clientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey).(string)
+	return ip
+}
+
+// rateLimited reports whether err is a rate limited validation to be handled like Keystone being unavailable
+func (a *Auth) rateLimited(err error) bool {
+	return a.RateLimitOverflow == RateLimitUnavailable && errors.Is(err, ErrRateLimited)
+}
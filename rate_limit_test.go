@@ -0,0 +1,128 @@
+package keystone
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	var b tokenBucket
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if !b.take(now, 10, 2) {
+			t.Fatalf("Expected burst of 2, failed at %d", i)
+		}
+	}
+	if b.take(now, 10, 2) {
+		t.Error("Expected empty bucket")
+	}
+	if !b.take(now.Add(100*time.Millisecond), 10, 2) {
+		t.Error("Expected bucket to be refilled")
+	}
+	if burstOf(0.5, 0) != 1 || burstOf(2.5, 0) != 3 || burstOf(10, 4) != 4 {
+		t.Error("Unexpected burst defaults")
+	}
+}
+
+func TestValidationRate(t *testing.T) {
+	idServer := identityMock(200, validTokenBody)
+	defer idServer.Close()
+
+	for overflow, status := range map[RateLimitOverflow]int{
+		RateLimitUnavailable:     http.StatusServiceUnavailable,
+		RateLimitTooManyRequests: http.StatusTooManyRequests,
+		RateLimitInvalid:         http.StatusUnauthorized,
+	} {
+		a := New(idServer.URL)
+		a.ValidationRate = 0.001
+		a.RateLimitOverflow = overflow
+		a.RejectUnauthenticated = true
+		h := a.Handler(okHandler)
+		for i, expected := range []int{http.StatusOK, status} {
+			req := newRequest("GET", "/")
+			req.Header.Set("X-Auth-Token", "token-"+strconv.Itoa(i))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != expected {
+				t.Errorf("Overflow %d: expected status %d for validation %d, got %d", overflow, expected, i, rec.Code)
+			}
+		}
+		if n := a.Stats().RateLimited; n != 1 {
+			t.Errorf("Overflow %d: expected 1 rate limited validation, got %d", overflow, n)
+		}
+	}
+}
+
+func TestValidationRatePerIP(t *testing.T) {
+	idServer := identityMock(200, validTokenBody)
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.ValidationRatePerIP = 0.001
+	a.ValidationBurstPerIP = 2
+	a.ClientIP = func(r *http.Request) string { return r.Header.Get("X-Client") }
+	var status string
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status = r.Header.Get("X-Identity-Status")
+	}))
+	for i, tc := range []struct {
+		client, status string
+	}{
+		{"a", "Confirmed"},
+		{"a", "Confirmed"},
+		{"a", "Invalid"},
+		{"b", "Confirmed"},
+	} {
+		req := newRequest("GET", "/")
+		req.Header.Set("X-Auth-Token", "token-"+strconv.Itoa(i))
+		req.Header.Set("X-Client", tc.client)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if status != tc.status {
+			t.Errorf("Request %d from %s: expected %s, got %s", i, tc.client, tc.status, status)
+		}
+	}
+	//validations without a client address are only limited globally
+	if _, err := a.Validate("token"); errors.Is(err, ErrRateLimited) {
+		t.Error("Expected validation without client address not to be rate limited")
+	}
+}
+
+func TestRateLimitedIPsBounded(t *testing.T) {
+	a := &Auth{ValidationRatePerIP: 0.001}
+	for i := 0; i < maxRateLimitedIPs+100; i++ {
+		ip := fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+		if !a.allowValidation(ip) {
+			t.Fatalf("Expected first validation from %s to be allowed", ip)
+		}
+	}
+	l := &a.rateLimiter
+	if len(l.ips) != maxRateLimitedIPs || l.lru.Len() != maxRateLimitedIPs {
+		t.Errorf("Expected %d buckets, got %d", maxRateLimitedIPs, len(l.ips))
+	}
+	//the least recently used buckets were evicted
+	if _, ok := l.ips["10.0.0.0"]; ok {
+		t.Error("Expected oldest bucket to be evicted")
+	}
+	if a.allowValidation("10.0.39.15") {
+		t.Error("Expected recent bucket to be kept")
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	for ip, key := range map[string]string{
+		"192.0.2.1":            "192.0.2.1",
+		"2001:db8:1:2:3:4:5:6": "2001:db8:1:2::/64",
+		"2001:db8:1:2:ffff::1": "2001:db8:1:2::/64",
+		"::ffff:192.0.2.1":     "::ffff:192.0.2.1",
+		"not-an-ip":            "not-an-ip",
+	} {
+		if got := rateLimitKey(ip); got != key {
+			t.Errorf("rateLimitKey(%q) = %q, expected %q", ip, got, key)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package keystone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// RedactToken returns a representation of a token which is safe for logging.
+// The first and last 4 characters are kept and the rest is replaced by a short hash,
+// so log lines of the same token can still be correlated:
+//
+//	gAAA...9f86d081...Q2Jk
+//
+// Tokens shorter than 16 characters are replaced by the hash entirely.
+func RedactToken(s string) string {
+	if s == "" {
+		return ""
+	}
+	if len(s) < 16 {
+		return "..." + shortHash(s) + "..."
+	}
+	return s[:4] + "..." + shortHash(s[4:len(s)-4]) + "..." + s[len(s)-4:]
+}
+
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:4])
+}
+
+// redactError returns the message of err with all occurrences of authToken redacted.
+// Keystone includes the token in some error messages (e.g. "Could not find token: ...").
+func redactError(err error, authToken string) string {
+	if authToken == "" {
+		return err.Error()
+	}
+	return strings.ReplaceAll(err.Error(), authToken, RedactToken(authToken))
+}
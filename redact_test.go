@@ -0,0 +1,29 @@
+package keystone
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRedactToken(t *testing.T) {
+	token := "gAAAAABfk3r8xY2Q3zLk4oqQ2Jk"
+	redacted := RedactToken(token)
+	if !strings.HasPrefix(redacted, "gAAA...") || !strings.HasSuffix(redacted, "...Q2Jk") || strings.Contains(redacted, "ABfk3r8") {
+		t.Errorf("Unexpected redaction: %s", redacted)
+	}
+	if RedactToken(token) != redacted {
+		t.Error("Expected redaction to be stable")
+	}
+	if short := RedactToken("1234"); strings.Contains(short, "1234") {
+		t.Errorf("Expected short token to be hashed entirely, got %s", short)
+	}
+	if RedactToken("") != "" {
+		t.Error("Expected empty token to stay empty")
+	}
+
+	err := errors.New("Could not find token: " + token)
+	if msg := redactError(err, token); strings.Contains(msg, token) || !strings.Contains(msg, redacted) {
+		t.Errorf("Expected token to be redacted from error, got %s", msg)
+	}
+}
@@ -0,0 +1,35 @@
+package keystone
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// refreshAhead revalidates a cached token in the background, see Auth.RefreshAhead
+func (a *Auth) refreshAhead(ctx context.Context, key, authToken string, cached *Token) {
+	if _, running := a.refreshing.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+	//the refresh outlives the request
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer a.refreshing.Delete(key)
+		token, _, err := a.loadShared(ctx, a.Endpoint, authToken)
+		if err == nil {
+			a.stats.refreshed.Add(1)
+			a.reportChanges(ctx, authToken, cached, token)
+			return
+		}
+		var kerr *Error
+		if errors.As(err, &kerr) && (kerr.StatusCode == http.StatusUnauthorized || kerr.StatusCode == http.StatusNotFound) {
+			//the token was revoked since it was cached
+			if err := a.Invalidate(authToken); err != nil {
+				a.log(ctx, slog.LevelWarn, "Failed to evict rejected token", "token", RedactToken(authToken), "error", err)
+			}
+			return
+		}
+		a.log(ctx, slog.LevelInfo, "Refreshing cached token failed", "token", RedactToken(authToken), "error", err)
+	}()
+}
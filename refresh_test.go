@@ -0,0 +1,73 @@
+package keystone
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshAhead(t *testing.T) {
+	var requests atomic.Int32
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(int(status.Load()))
+		w.Write([]byte(validTokenBody))
+	}))
+	defer idServer.Close()
+
+	cache := NewInMemoryCache(10)
+	a := New(idServer.URL)
+	a.TokenCache = cache
+	a.CacheTime = time.Minute
+	a.RefreshAhead = time.Minute - 50*time.Millisecond
+
+	if _, err := a.Validate("1234"); err != nil {
+		t.Fatal(err)
+	}
+	//fresh entries are served without contacting Keystone
+	a.Validate("1234")
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("Expected one request, got %d", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := a.Validate("1234"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if time.Since(start) > 40*time.Millisecond {
+		t.Error("Expected entries within the refresh window to be served from the cache")
+	}
+	waitFor(t, func() bool { return a.Stats().Refreshed == 1 })
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected a single background refresh, got %d requests", n)
+	}
+
+	//tokens rejected while refreshing are evicted
+	status.Store(http.StatusNotFound)
+	time.Sleep(60 * time.Millisecond)
+	a.Validate("1234")
+	waitFor(t, func() bool {
+		_, _, ok, _ := getCachedToken(context.Background(), cache, a.cacheKey("1234"), 0)
+		return !ok
+	})
+	if _, err := a.Validate("1234"); err == nil {
+		t.Error("Expected revoked token to be rejected after the refresh")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+	}
+}
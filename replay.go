@@ -0,0 +1,41 @@
+package keystone
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrReplay is returned by ReplayGuard for nonces which have already been used
+var ErrReplay = errors.New("Nonce has already been used")
+
+// ReplayGuard tracks the nonces of single-use signed requests (e.g. pre-signed or temporary URLs)
+// to reject replays within their validity window. Used nonces are stored in Cache until the request expires,
+// so a cache shared between processes extends the protection across them.
+//
+// Check and record are atomic within a process. As Cache has no compare-and-set operation, two processes
+// sharing a cache may both accept a nonce used concurrently.
+type ReplayGuard struct {
+	Cache Cache
+
+	mu sync.Mutex
+}
+
+// Use records a nonce valid until the given time. It returns ErrReplay if the nonce was used before.
+// Expired requests should be rejected by the signature validation before calling Use.
+func (g *ReplayGuard) Use(nonce string, validUntil time.Time) error {
+	ttl := time.Until(validUntil)
+	if ttl <= 0 {
+		return nil
+	}
+	key := "nonce/" + hashToken(nonce)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var used bool
+	if g.Cache.Get(key, &used) && used {
+		return ErrReplay
+	}
+	g.Cache.Set(key, true, ttl)
+	return nil
+}
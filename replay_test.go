@@ -0,0 +1,39 @@
+package keystone
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReplayGuard(t *testing.T) {
+	g := &ReplayGuard{Cache: NewInMemoryCache(100)}
+	validUntil := time.Now().Add(time.Minute)
+
+	if err := g.Use("n-1", validUntil); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Use("n-1", validUntil); err != ErrReplay {
+		t.Errorf("Expected ErrReplay, got %v", err)
+	}
+	if err := g.Use("n-2", validUntil); err != nil {
+		t.Errorf("Expected other nonce to be accepted, got %v", err)
+	}
+
+	var accepted int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if g.Use("n-3", validUntil) == nil {
+				atomic.AddInt32(&accepted, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if accepted != 1 {
+		t.Errorf("Expected nonce to be accepted once, got %d", accepted)
+	}
+}
@@ -0,0 +1,79 @@
+package keystone
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// responseWriter wraps the http.ResponseWriter of a request to adjust the response header right before
+// it is written and to record the response status. It preserves http.Flusher, http.Hijacker and http.CloseNotifier of the wrapped writer,
+// so streaming responses (e.g. server-sent events) and WebSocket upgrades work behind the middleware.
+// http.ResponseController reaches other optional interfaces through Unwrap.
+type responseWriter struct {
+	http.ResponseWriter
+	//called once before the header is written if set
+	beforeWrite func(http.Header)
+	done        bool
+	//status of the response, 0 until the header is written
+	status int
+}
+
+func (w *responseWriter) prepareHeader() {
+	if w.done {
+		return
+	}
+	w.done = true
+	if w.beforeWrite != nil {
+		w.beforeWrite(w.ResponseWriter.Header())
+	}
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.prepareHeader()
+	if w.status == 0 && (code >= 200 || code == http.StatusSwitchingProtocols) {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.prepareHeader()
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, it does nothing if the wrapped writer doesn't support flushing
+func (w *responseWriter) Flush() {
+	w.prepareHeader()
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker, it fails if the wrapped writer doesn't support hijacking
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	//the response header of a hijacked connection is written by the handler itself
+	w.done = true
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// CloseNotify implements http.CloseNotifier, the returned channel never fires if the wrapped writer
+// doesn't support close notifications.
+func (w *responseWriter) CloseNotify() <-chan bool {
+	if n, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return n.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
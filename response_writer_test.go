@@ -0,0 +1,100 @@
+package keystone
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHijack(t *testing.T) {
+	idServer := identityMock(200, validTokenBody)
+	defer idServer.Close()
+
+	//echoes lines after upgrading the connection like a WebSocket handshake
+	upgrade := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Identity-Status") != "Confirmed" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		line, _ := rw.ReadString('\n')
+		rw.WriteString(line)
+		rw.Flush()
+	})
+	server := httptest.NewServer(New(idServer.URL).Handler(upgrade))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\nX-Auth-Token: 1234\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected upgrade, got %s", resp.Status)
+	}
+	io.WriteString(conn, "ping\n")
+	if line, _ := r.ReadString('\n'); line != "ping\n" {
+		t.Errorf("Expected echo over the upgraded connection, got %q", line)
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	idServer := identityMock(200, validTokenBody)
+	defer idServer.Close()
+
+	next := make(chan struct{})
+	events := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Vary", "Accept")
+		for _, event := range []string{"one", "two"} {
+			io.WriteString(w, "data: "+event+"\n\n")
+			w.(http.Flusher).Flush()
+			<-next
+		}
+		if _, ok := w.(http.CloseNotifier); !ok {
+			t.Error("Expected writer to implement http.CloseNotifier")
+		}
+	})
+	server := httptest.NewServer(New(idServer.URL).Handler(events))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("X-Auth-Token", "1234")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v := resp.Header.Values("Vary"); strings.Join(v, ", ") != "Accept, X-Auth-Token" {
+		t.Errorf("Expected Vary to be extended, got %q", v)
+	}
+	if v := resp.Header.Get("Cache-Control"); v != "private" {
+		t.Errorf("Expected Cache-Control private, got %q", v)
+	}
+	//each event is received before the handler continues
+	r := bufio.NewReader(resp.Body)
+	for _, event := range []string{"one", "two"} {
+		if line, _ := r.ReadString('\n'); line != "data: "+event+"\n" {
+			t.Fatalf("Expected event %s, got %q", event, line)
+		}
+		r.ReadString('\n')
+		next <- struct{}{}
+	}
+}
@@ -0,0 +1,56 @@
+package keystone
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+)
+
+// ErrRestricted is returned for requests rejected by Auth.VerifyRequest
+var ErrRestricted = errors.New("Token is restricted")
+
+// RestrictSourceIP returns a function for Auth.VerifyRequest enforcing source address restrictions
+// embedded in the token's extras by some clouds. The extra is expected to contain a list of CIDRs
+// or IP addresses, tokens without it are not restricted:
+//
+//	"extras": {"allowed_cidrs": ["10.0.0.0/8", "192.0.2.1"]}
+//
+// The client address is taken from clientIP, or from the request's RemoteAddr if nil. Behind a reverse
+// proxy pass a function extracting the address from a header set by the proxy.
+//
+//	auth.VerifyRequest = keystone.RestrictSourceIP("allowed_cidrs", nil)
+func RestrictSourceIP(extra string, clientIP func(r *http.Request) string) func(*http.Request, *Token) error {
+	return func(r *http.Request, t *Token) error {
+		raw, ok := t.Extras[extra]
+		if !ok {
+			return nil
+		}
+		var allowed []string
+		if err := json.Unmarshal(raw, &allowed); err != nil {
+			return fmt.Errorf("invalid %s: %w", extra, err)
+		}
+		var client string
+		if clientIP != nil {
+			client = clientIP(r)
+		} else if client, _, _ = net.SplitHostPort(r.RemoteAddr); client == "" {
+			client = r.RemoteAddr
+		}
+		addr, err := netip.ParseAddr(client)
+		if err != nil {
+			return fmt.Errorf("invalid client address %q", client)
+		}
+		addr = addr.Unmap()
+		for _, a := range allowed {
+			if prefix, err := netip.ParsePrefix(a); err == nil && prefix.Contains(addr) {
+				return nil
+			}
+			if ip, err := netip.ParseAddr(a); err == nil && ip.Unmap() == addr {
+				return nil
+			}
+		}
+		return fmt.Errorf("client address %s not allowed", addr)
+	}
+}
@@ -0,0 +1,60 @@
+package keystone
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRestrictSourceIP(t *testing.T) {
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		extras := ``
+		if r.Header.Get("X-Subject-Token") == "restricted" {
+			extras = `, "extras": {"allowed_cidrs": ["10.0.0.0/8", "2001:db8::1"]}`
+		}
+		w.Write([]byte(`{"token": {"expires_at": "2120-10-09T15:09:12.355Z", "user": {"id": "u-1"}` + extras + `}}`))
+	}))
+	defer idServer.Close()
+
+	var rejected error
+	a := Auth{Endpoint: idServer.URL, TokenCache: NewInMemoryCache(10), RejectUnauthenticated: true}
+	a.VerifyRequest = RestrictSourceIP("allowed_cidrs", nil)
+	a.OnValidationError = func(_ *http.Request, err error) { rejected = err }
+	h := a.Handler(okHandler)
+
+	cases := []struct {
+		token, remoteAddr string
+		code              int
+	}{
+		{"unrestricted", "192.0.2.1:1234", 200},
+		{"restricted", "10.1.2.3:1234", 200},
+		{"restricted", "[2001:db8::1]:1234", 200},
+		{"restricted", "[::ffff:10.1.2.3]:1234", 200},
+		//the token is cached now, the restriction must still apply
+		{"restricted", "192.0.2.1:1234", 401},
+		{"restricted", "[2001:db8::2]:1234", 401},
+	}
+	for _, c := range cases {
+		rejected = nil
+		rec := httptest.NewRecorder()
+		req := newRequest("GET", "/")
+		req.Header.Set("X-Auth-Token", c.token)
+		req.RemoteAddr = c.remoteAddr
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.code {
+			t.Errorf("%s from %s: expected status %d, got %d", c.token, c.remoteAddr, c.code, rec.Code)
+		}
+		if c.code == 401 && !errors.Is(rejected, ErrRestricted) {
+			t.Errorf("%s from %s: expected %v, got %v", c.token, c.remoteAddr, ErrRestricted, rejected)
+		}
+	}
+
+	forwarded := RestrictSourceIP("allowed_cidrs", func(r *http.Request) string { return r.Header.Get("X-Real-Ip") })
+	req := newRequest("GET", "/")
+	req.Header.Set("X-Real-Ip", "10.0.0.1")
+	token, _ := a.Validate("restricted")
+	if err := forwarded(req, token); err != nil {
+		t.Errorf("Expected client address of header to be allowed, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package keystone
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ErrTokenExpired is the cancellation cause of long-lived requests whose token expired, see Revalidation
+var ErrTokenExpired = errors.New("Token expired")
+
+// Revalidation periodically revalidates the token of long-lived requests (e.g. server-sent events or watch APIs)
+// instead of trusting it for the lifetime of the connection.
+// When the token expires or fails revalidation the request context is cancelled with the reason available via
+// context.Cause, so handlers streaming responses should stop once the context is done.
+// It must be placed behind the handler returned by Auth.Handler.
+//
+//	watch := &keystone.Revalidation{Auth: auth, Interval: time.Minute}
+//	http.Handle("/v1/watch", auth.Handler(watch.Handler(watchHandler)))
+type Revalidation struct {
+	Auth *Auth
+	//How often to revalidate the token against Keystone, bypassing the cache. Defaults to 1 minute.
+	Interval time.Duration
+	//Called when the token expired or became invalid, before the request context is cancelled. Optional.
+	OnInvalid func(r *http.Request, err error)
+}
+
+// Handler returns a http handler for use in a middleware chain.
+// Requests without a validated token are passed through unchanged.
+func (rv *Revalidation) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := TokenFromContext(r.Context())
+		authToken := authTokenFromRequest(r)
+		if !ok || authToken == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithCancelCause(r.Context())
+		defer cancel(nil)
+		r = r.WithContext(ctx)
+		go rv.watch(ctx, r, authToken, token.ExpiresAt, cancel)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// watch revalidates the token until ctx is done and cancels it if the token is no longer valid
+func (rv *Revalidation) watch(ctx context.Context, r *http.Request, authToken string, expiresAt time.Time, cancel context.CancelCauseFunc) {
+	interval := rv.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	expiry := time.NewTimer(time.Until(expiresAt))
+	defer expiry.Stop()
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-expiry.C:
+			err = ErrTokenExpired
+		case <-ticker.C:
+			token, _, lerr := rv.Auth.load(ctx, rv.Auth.Endpoint, authToken)
+			if lerr == nil {
+				lerr = rv.Auth.checkToken(token)
+			}
+			//keystone being unavailable doesn't mean the token was revoked
+			if lerr == nil || IsUnavailable(lerr) {
+				continue
+			}
+			err = lerr
+		}
+		rv.Auth.log(ctx, slog.LevelInfo, "Terminating request with invalid token",
+			"method", r.Method, "path", r.URL.Path, "token", RedactToken(authToken), "error", redactError(err, authToken))
+		if rv.OnInvalid != nil {
+			rv.OnInvalid(r, err)
+		}
+		cancel(err)
+		return
+	}
+}
@@ -0,0 +1,104 @@
+package keystone
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRevalidation(t *testing.T) {
+	var revoked atomic.Bool
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if revoked.Load() {
+			w.WriteHeader(404)
+			return
+		}
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z"}}`)
+	}))
+	defer idServer.Close()
+
+	auth := &Auth{Endpoint: idServer.URL}
+	var invalid atomic.Bool
+	rv := &Revalidation{Auth: auth, Interval: 10 * time.Millisecond, OnInvalid: func(r *http.Request, err error) {
+		invalid.Store(true)
+	}}
+	var cause error
+	stream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		revoked.Store(true)
+		select {
+		case <-r.Context().Done():
+			cause = context.Cause(r.Context())
+		case <-time.After(time.Second):
+		}
+	})
+
+	req := newRequest("GET", "/watch")
+	req.Header.Set("X-Auth-Token", "1234")
+	auth.Handler(rv.Handler(stream)).ServeHTTP(httptest.NewRecorder(), req)
+
+	if _, ok := cause.(*Error); !ok {
+		t.Errorf("Expected request to be cancelled with keystone error, got %v", cause)
+	}
+	if !invalid.Load() {
+		t.Error("Expected OnInvalid to be called")
+	}
+}
+
+func TestRevalidationExpiry(t *testing.T) {
+	token := &Token{ExpiresAt: time.Now().Add(20 * time.Millisecond)}
+	rv := &Revalidation{Auth: &Auth{OfflineMode: true}, Interval: time.Hour}
+	var cause error
+	h := rv.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		cause = context.Cause(r.Context())
+	}))
+
+	req := newRequest("GET", "/watch")
+	req.Header.Set("X-Auth-Token", "1234")
+	req = req.WithContext(withToken(req.Context(), token))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if cause != ErrTokenExpired {
+		t.Errorf("Expected ErrTokenExpired, got %v", cause)
+	}
+}
+
+func TestRevalidationRemovedToken(t *testing.T) {
+	var revoked atomic.Bool
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if revoked.Load() {
+			w.WriteHeader(404)
+			return
+		}
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z"}}`)
+	}))
+	defer idServer.Close()
+
+	auth := &Auth{Endpoint: idServer.URL, RemoveAuthToken: true}
+	rv := &Revalidation{Auth: auth, Interval: 10 * time.Millisecond}
+	var cause error
+	var header string
+	stream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("X-Auth-Token")
+		revoked.Store(true)
+		select {
+		case <-r.Context().Done():
+			cause = context.Cause(r.Context())
+		case <-time.After(time.Second):
+		}
+	})
+
+	req := newRequest("GET", "/watch")
+	req.Header.Set("X-Auth-Token", "1234")
+	auth.Handler(rv.Handler(stream)).ServeHTTP(httptest.NewRecorder(), req)
+
+	if header != "" {
+		t.Errorf("Expected token to be removed, got %q", header)
+	}
+	if _, ok := cause.(*Error); !ok {
+		t.Errorf("Expected request with removed token to be revalidated, got %v", cause)
+	}
+}
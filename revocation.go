@@ -0,0 +1,187 @@
+package keystone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrTokenRevoked is returned for cached tokens matching a revocation event, see Auth.SyncRevocations
+var ErrTokenRevoked = errors.New("Token revoked")
+
+// revocationRetention is how long revocation events are kept for checking cached tokens
+const revocationRetention = 24 * time.Hour
+
+// revocationEvent is an event of Keystone's OS-REVOKE API.
+// A token is revoked if it was issued before IssuedBefore and matches all attributes given by the event.
+type revocationEvent struct {
+	IssuedBefore  time.Time `json:"issued_before"`
+	RevokedAt     time.Time `json:"revoked_at"`
+	UserID        string    `json:"user_id"`
+	ProjectID     string    `json:"project_id"`
+	DomainID      string    `json:"domain_id"`
+	RoleID        string    `json:"role_id"`
+	AuditID       string    `json:"audit_id"`
+	AuditChainID  string    `json:"audit_chain_id"`
+	TrustID       string    `json:"trust_id"`
+	ConsumerID    string    `json:"consumer_id"`
+	AccessTokenID string    `json:"access_token_id"`
+}
+
+func (e *revocationEvent) matches(t *Token) bool {
+	//trusts and OAuth aren't part of the token context, so such events can't be matched
+	if e.TrustID != "" || e.ConsumerID != "" || e.AccessTokenID != "" {
+		return false
+	}
+	if t.IssuedAt.After(e.IssuedBefore) {
+		return false
+	}
+	if e.UserID != "" && e.UserID != t.User.ID {
+		return false
+	}
+	if e.ProjectID != "" && (t.Project == nil || e.ProjectID != t.Project.ID) {
+		return false
+	}
+	if e.DomainID != "" && e.DomainID != t.User.Domain.ID &&
+		(t.Project == nil || e.DomainID != t.Project.Domain.ID) && (t.Domain == nil || e.DomainID != t.Domain.ID) {
+		return false
+	}
+	if e.RoleID != "" && !hasRoleID(t, e.RoleID) {
+		return false
+	}
+	if e.AuditID != "" && (len(t.AuditIDs) == 0 || e.AuditID != t.AuditIDs[0]) {
+		return false
+	}
+	if e.AuditChainID != "" && (len(t.AuditIDs) == 0 || e.AuditChainID != t.AuditIDs[len(t.AuditIDs)-1]) {
+		return false
+	}
+	return true
+}
+
+func hasRoleID(t *Token, id string) bool {
+	for _, r := range t.Roles {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// revocationList holds the revocation events fetched from Keystone
+type revocationList struct {
+	mu     sync.RWMutex
+	events []revocationEvent
+	synced time.Time
+}
+
+// revokedAt returns when the token was revoked by the earliest matching event
+func (l *revocationList) revokedAt(t *Token) (time.Time, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var at time.Time
+	revoked := false
+	for i := range l.events {
+		if l.events[i].matches(t) && (!revoked || l.events[i].RevokedAt.Before(at)) {
+			at, revoked = l.events[i].RevokedAt, true
+		}
+	}
+	return at, revoked
+}
+
+// SyncRevocations fetches the revocation events issued since the last sync from Keystone's
+// OS-REVOKE API. Afterwards cached tokens matching an event are evicted and rejected with ErrTokenRevoked.
+// Tokens validated against Keystone are unaffected as Keystone checks revocations itself.
+// Listing revocation events requires a service or admin token, see ServiceCredentials.
+func (a *Auth) SyncRevocations(ctx context.Context) error {
+	if a.ServiceCredentials == nil {
+		return errors.New("Fetching revocation events requires ServiceCredentials")
+	}
+	serviceToken, err := a.serviceToken(a.serviceLogin(a.Endpoint))
+	if err != nil {
+		return err
+	}
+	a.revocations.mu.RLock()
+	since := a.revocations.synced
+	a.revocations.mu.RUnlock()
+
+	u := a.Endpoint + "/OS-REVOKE/events"
+	if !since.IsZero() {
+		u += "?since=" + url.QueryEscape(since.UTC().Format(time.RFC3339))
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", serviceToken)
+	req.Header.Set("User-Agent", a.UserAgent)
+	start := time.Now()
+	r, err := a.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return &Error{StatusCode: r.StatusCode, Status: r.Status}
+	}
+	var resp struct {
+		Events []revocationEvent `json:"events"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return fmt.Errorf("Failed to decode revocation events: %w", err)
+	}
+
+	a.revocations.mu.Lock()
+	defer a.revocations.mu.Unlock()
+	events := a.revocations.events[:0]
+	for _, e := range a.revocations.events {
+		if time.Since(e.RevokedAt) < revocationRetention {
+			events = append(events, e)
+		}
+	}
+	a.revocations.events = append(events, resp.Events...)
+	//overlap the next sync a bit as revocation times are only given in seconds
+	a.revocations.synced = start.Add(-time.Second)
+	a.log(ctx, slog.LevelDebug, "Synced revocation events", "new", len(resp.Events), "total", len(a.revocations.events))
+	return nil
+}
+
+// WatchRevocations calls SyncRevocations every interval until ctx is cancelled. Failures are logged.
+//
+//	go auth.WatchRevocations(ctx, time.Minute)
+func (a *Auth) WatchRevocations(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := a.SyncRevocations(ctx); err != nil && ctx.Err() == nil {
+			a.log(ctx, slog.LevelWarn, "Failed to sync revocation events", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkRevoked rejects a cached token matching a revocation event and evicts it from the cache.
+// Within RevocationGracePeriod the token is accepted but flagged as revoked.
+func (a *Auth) checkRevoked(ctx context.Context, authToken string, token *Token) error {
+	revokedAt, revoked := a.revocations.revokedAt(token)
+	if !revoked {
+		return nil
+	}
+	if time.Since(revokedAt) < a.RevocationGracePeriod {
+		token.Revoked = true
+		return nil
+	}
+	if err := a.Invalidate(authToken); err != nil {
+		a.log(ctx, slog.LevelWarn, "Failed to evict revoked token", "token", RedactToken(authToken), "error", err)
+	}
+	return ErrTokenRevoked
+}
@@ -0,0 +1,151 @@
+package keystone
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRevocationEventMatches(t *testing.T) {
+	issued := time.Now().Add(-time.Hour)
+	token := &Token{IssuedAt: issued, AuditIDs: []string{"a-1", "a-0"}, Project: &Project{ID: "p-1", Domain: Domain{ID: "d-1"}}}
+	token.User.ID = "u-1"
+	token.User.Domain.ID = "d-0"
+	later := issued.Add(time.Minute)
+
+	for _, tc := range []struct {
+		event   revocationEvent
+		revoked bool
+	}{
+		{revocationEvent{IssuedBefore: later, AuditID: "a-1"}, true},
+		{revocationEvent{IssuedBefore: later, AuditID: "a-0"}, false},
+		{revocationEvent{IssuedBefore: later, AuditChainID: "a-0"}, true},
+		{revocationEvent{IssuedBefore: later, UserID: "u-1"}, true},
+		{revocationEvent{IssuedBefore: issued.Add(-time.Minute), UserID: "u-1"}, false},
+		{revocationEvent{IssuedBefore: later, UserID: "u-1", ProjectID: "p-2"}, false},
+		{revocationEvent{IssuedBefore: later, DomainID: "d-1"}, true},
+		{revocationEvent{IssuedBefore: later, DomainID: "d-2"}, false},
+		{revocationEvent{IssuedBefore: later, RoleID: "r-1"}, false},
+		{revocationEvent{IssuedBefore: later, UserID: "u-1", TrustID: "t-1"}, false},
+	} {
+		if revoked := tc.event.matches(token); revoked != tc.revoked {
+			t.Errorf("Expected %+v to match: %v, got %v", tc.event, tc.revoked, revoked)
+		}
+	}
+}
+
+func TestSyncRevocations(t *testing.T) {
+	var revoked, validations atomic.Int32
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST":
+			w.Header().Set("X-Subject-Token", "service-token")
+			w.WriteHeader(201)
+			io.WriteString(w, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z"}}`)
+		case r.URL.Path == "/OS-REVOKE/events":
+			if r.Header.Get("X-Auth-Token") != "service-token" {
+				w.WriteHeader(403)
+				return
+			}
+			if revoked.Load() == 0 {
+				io.WriteString(w, `{"events": []}`)
+				return
+			}
+			io.WriteString(w, `{"events": [{"audit_id": "a-1", "issued_before": "2120-01-01T00:00:00Z", "revoked_at": "`+time.Now().UTC().Format(time.RFC3339)+`"}]}`)
+		default:
+			validations.Add(1)
+			io.WriteString(w, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z", "audit_ids": ["a-1"]}}`)
+		}
+	}))
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.TokenCache = NewInMemoryCache(10)
+	if err := a.SyncRevocations(context.Background()); err == nil {
+		t.Error("Expected sync without service credentials to fail")
+	}
+	a.ServiceCredentials = PasswordCredentials{UserID: "u-service", Password: "secret"}
+	if err := a.SyncRevocations(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := a.Validate("1234"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	revoked.Store(1)
+	if err := a.SyncRevocations(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Validate("1234"); err != ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+	//the revoked token was evicted, so it is validated against Keystone again
+	a.Validate("1234")
+	if n := validations.Load(); n != 2 {
+		t.Errorf("Expected 2 validations against Keystone, got %d", n)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z"}}`)
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	if err := a.Invalidate("1234"); err == nil {
+		t.Error("Expected Invalidate without cache to fail")
+	}
+	cache := NewInMemoryCache(10)
+	for _, configure := range []func(){
+		func() { a.TokenCache = cache },
+		func() { a.TokenCache, a.LoadingCache = nil, NewLoadingCache(cache, 0) },
+	} {
+		configure()
+		if _, err := a.Validate("1234"); err != nil {
+			t.Fatal(err)
+		}
+		if cache.Len() != 1 {
+			t.Fatalf("Expected token to be cached, got %d entries", cache.Len())
+		}
+		if err := a.Invalidate("1234"); err != nil {
+			t.Fatal(err)
+		}
+		if cache.Len() != 0 {
+			t.Errorf("Expected token to be evicted, got %d entries", cache.Len())
+		}
+	}
+}
+
+func TestRevocationGracePeriod(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z", "audit_ids": ["a-1"]}}`)
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.TokenCache = NewInMemoryCache(10)
+	a.RevocationGracePeriod = 50 * time.Millisecond
+	if _, err := a.Validate("1234"); err != nil {
+		t.Fatal(err)
+	}
+	a.revocations.events = []revocationEvent{{AuditID: "a-1", IssuedBefore: time.Now().Add(time.Hour), RevokedAt: time.Now()}}
+
+	token, err := a.Validate("1234")
+	if err != nil {
+		t.Fatalf("Expected revoked token to be accepted within the grace period, got %v", err)
+	}
+	if !token.Revoked {
+		t.Error("Expected token to be flagged as revoked")
+	}
+	if h := token.headers(); h["X-Token-Revoked"] != "True" {
+		t.Errorf("Expected X-Token-Revoked header, got %q", h["X-Token-Revoked"])
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := a.Validate("1234"); err != ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked after the grace period, got %v", err)
+	}
+}
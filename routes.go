@@ -0,0 +1,107 @@
+package keystone
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Access declares the identity requirements of a route
+type Access int
+
+const (
+	//TokenOptional validates tokens if present and delegates the decision to subsequent handlers.
+	//This is the behavior for requests not matching any route.
+	TokenOptional Access = iota
+	//Anonymous skips token validation altogether, e.g. for health checks or public assets.
+	//The request is passed on with X-Identity-Status: Invalid.
+	Anonymous
+	//TokenRequired rejects requests without a valid token like Auth.RejectUnauthenticated and
+	//requests lacking all of the route's roles with 403.
+	TokenRequired
+)
+
+// Route declares the identity requirements for requests with a matching path.
+//
+// Patterns are matched segment by segment. Each segment is a path.Match pattern,
+// e.g. * matches exactly one path segment. A trailing /** matches the path itself and everything below:
+//
+//	auth.Routes = []keystone.Route{
+//		{Pattern: "/healthz", Access: keystone.Anonymous},
+//		{Pattern: "/static/**", Access: keystone.Anonymous},
+//		{Pattern: "/v1/*/admin/**", Access: keystone.TokenRequired, Roles: []string{"admin"}},
+//		{Pattern: "/v1/**", Access: keystone.TokenRequired},
+//	}
+type Route struct {
+	Pattern string
+	Access  Access
+	//For TokenRequired routes: the token needs to have any of these roles. Any valid token is accepted if empty.
+	Roles []string
+	//Identity headers passed on for requests matching the route, overrides Auth.Headers if set.
+	Headers HeaderSet
+}
+
+// route returns the first route matching the path or nil
+func (a *Auth) route(p string) *Route {
+	for i := range a.Routes {
+		if matchRoute(a.Routes[i].Pattern, p) {
+			return &a.Routes[i]
+		}
+	}
+	return nil
+}
+
+// ErrMissingRole is passed to Auth.ErrorHandler for requests rejected because the token lacks the roles of a route
+var ErrMissingRole = errors.New("Token doesn't have any of the required roles")
+
+// allow enforces the roles of a TokenRequired route. It returns false if the request has to be rejected.
+func (r *Route) allow(token *Token) bool {
+	if r == nil || r.Access != TokenRequired || token == nil {
+		return true
+	}
+	return len(r.Roles) == 0 || HasRole(r.Roles...)(token)
+}
+
+// SkipPaths returns a function for Auth.SkipFunc exempting requests matching any of the patterns from validation.
+// Patterns are matched like those of a Route and can be restricted to a method like in http.ServeMux:
+//
+//	auth.SkipFunc = keystone.SkipPaths("/healthz", "GET /metrics", "/static/**")
+func SkipPaths(patterns ...string) func(*http.Request) bool {
+	type skip struct{ method, pattern string }
+	skips := make([]skip, len(patterns))
+	for i, p := range patterns {
+		if method, pattern, ok := strings.Cut(p, " "); ok {
+			skips[i] = skip{method, strings.TrimSpace(pattern)}
+		} else {
+			skips[i] = skip{"", p}
+		}
+	}
+	return func(r *http.Request) bool {
+		for _, s := range skips {
+			if (s.method == "" || s.method == r.Method) && matchRoute(s.pattern, r.URL.Path) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// matchRoute reports whether a url path matches a route pattern.
+// The path is cleaned first, so duplicate slashes and dot segments can't be used to evade a route.
+func matchRoute(pattern, p string) bool {
+	patterns := strings.Split(strings.Trim(pattern, "/"), "/")
+	segments := strings.Split(strings.Trim(path.Clean("/"+p), "/"), "/")
+	for i, pat := range patterns {
+		if pat == "**" && i == len(patterns)-1 {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if ok, err := path.Match(pat, segments[i]); err != nil || !ok {
+			return false
+		}
+	}
+	return len(patterns) == len(segments)
+}
@@ -0,0 +1,127 @@
+package keystone
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMatchRoute(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		match         bool
+	}{
+		{"/healthz", "/healthz", true},
+		{"/healthz", "/healthz/", true},
+		{"/healthz", "/healthz/deep", false},
+		{"/static/**", "/static", true},
+		{"/static/**", "/static/css/main.css", true},
+		{"/static/**", "/staticfoo", false},
+		{"/v1/*/admin/**", "/v1/p-1/admin/users", true},
+		{"/v1/*/admin/**", "/v1/admin", false},
+		{"/v1/*.json", "/v1/index.json", true},
+		{"/**", "/anything/at/all", true},
+		{"/v1/secret", "//v1/secret", true},
+		{"/v1/secret", "/v1//secret/", true},
+		{"/v1/secret", "/v1/./secret", true},
+		{"/public/**", "/public/../v1/secret", false},
+		{"/v1/**", "/public/../v1/secret", true},
+		{"/public/**", "/public/../../public/file", true},
+	}
+	for _, c := range cases {
+		if got := matchRoute(c.pattern, c.path); got != c.match {
+			t.Errorf("matchRoute(%q, %q) = %v, expected %v", c.pattern, c.path, got, c.match)
+		}
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	var calls int32
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z", "roles": [{"id": "1", "name": "member"}]}}`)
+	}))
+	defer idServer.Close()
+
+	a := Auth{Endpoint: idServer.URL, Routes: []Route{
+		{Pattern: "/healthz", Access: Anonymous},
+		{Pattern: "/v1/*/admin/**", Access: TokenRequired, Roles: []string{"admin"}},
+		{Pattern: "/v1/**", Access: TokenRequired},
+	}}
+	h := a.Handler(okHandler)
+
+	cases := []struct {
+		path   string
+		token  string
+		status int
+	}{
+		{"/healthz", "1234", 200},
+		{"/public", "", 200},
+		{"/v1/things", "", 401},
+		{"/v1/things", "1234", 200},
+		{"/v1/p-1/admin/users", "1234", 403},
+	}
+	for _, c := range cases {
+		req := newRequest("GET", c.path)
+		if c.token != "" {
+			req.Header.Set("X-Auth-Token", c.token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.path, c.status, w.Code)
+		}
+		if c.status == 401 && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: expected WWW-Authenticate header", c.path)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected 2 validation requests, got %d", n)
+	}
+}
+
+func TestSkipPaths(t *testing.T) {
+	var calls int32
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		io.WriteString(w, validTokenBody)
+	}))
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.RejectUnauthenticated = true
+	a.SkipFunc = SkipPaths("/healthz", "GET /metrics", "/static/**")
+	var status string
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status = r.Header.Get("X-Identity-Status")
+	}))
+
+	for _, tc := range []struct {
+		method, path string
+		skipped      bool
+	}{
+		{"GET", "/healthz", true},
+		{"POST", "/healthz", true},
+		{"GET", "/metrics", true},
+		{"POST", "/metrics", false},
+		{"GET", "/static/app.js", true},
+		{"GET", "/api", false},
+		{"GET", "/static/../api", false},
+		{"GET", "//metrics", true},
+	} {
+		atomic.StoreInt32(&calls, 0)
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("X-Auth-Token", "1234")
+		req.Header.Set("X-Identity-Status", "Confirmed")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		validated := atomic.LoadInt32(&calls) == 1
+		if validated == tc.skipped {
+			t.Errorf("Expected %s %s to be skipped: %v, got validated: %v", tc.method, tc.path, tc.skipped, validated)
+		}
+		if tc.skipped && status != "Invalid" {
+			t.Errorf("Expected spoofed identity headers to be removed for %s %s, got %q", tc.method, tc.path, status)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package keystone
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// setServerTiming adds a Server-Timing entry for the token validation to the response,
+// e.g. Server-Timing: keystone;dur=12.345;desc="remote"
+func setServerTiming(w http.ResponseWriter, d time.Duration, cached bool) {
+	desc := "remote"
+	if cached {
+		desc = "cache"
+	}
+	w.Header().Add("Server-Timing", fmt.Sprintf(`keystone;dur=%.3f;desc="%s"`, float64(d)/float64(time.Millisecond), desc))
+}
@@ -0,0 +1,27 @@
+package keystone
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestServerTiming(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z"}}`)
+	defer idServer.Close()
+
+	cache := cacheMock{}
+	a := Auth{Endpoint: idServer.URL, TokenCache: &cache, ServerTiming: true}
+	h := a.Handler(okHandler)
+
+	for _, desc := range []string{"remote", "cache"} {
+		rec := httptest.NewRecorder()
+		req := newRequest("GET", "/foo")
+		req.Header.Set("X-Auth-Token", "1234")
+		h.ServeHTTP(rec, req)
+		pattern := regexp.MustCompile(`^keystone;dur=\d+\.\d{3};desc="` + desc + `"$`)
+		if v := rec.Header().Get("Server-Timing"); !pattern.MatchString(v) {
+			t.Errorf("Expected Server-Timing header matching %s, got %q", pattern, v)
+		}
+	}
+}
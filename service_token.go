@@ -0,0 +1,36 @@
+package keystone
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const servicePrefix = "X-Service-"
+
+// authenticateService validates the X-Service-Token of a request and sets the X-Service-* headers.
+// It returns nil if the request has no valid service token.
+func (h *handler) authenticateService(req *http.Request, route *Route) *Token {
+	authToken := req.Header.Get("X-Service-Token")
+	if authToken == "" {
+		return nil
+	}
+	token, _, err := h.validateToken(req.Context(), authToken)
+	if err != nil {
+		h.log(req.Context(), slog.LevelInfo, "Service token validation failed", "token", RedactToken(authToken), "error", redactError(err, authToken))
+		req.Header.Set(servicePrefix+"Identity-Status", "Invalid")
+		return nil
+	}
+	if !h.checkServiceRoles(req, token) {
+		req.Header.Set(servicePrefix+"Identity-Status", "Invalid")
+		return nil
+	}
+	header := http.Header{}
+	h.setHeaders(header, token, route)
+	//the catalog of the service token would clash with the user's X-Service-Catalog
+	header.Del(catalogHeader)
+	for k, v := range header {
+		req.Header[servicePrefix+strings.TrimPrefix(k, "X-")] = v
+	}
+	return token
+}
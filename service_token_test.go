@@ -0,0 +1,63 @@
+package keystone
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServiceToken(t *testing.T) {
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Subject-Token") {
+		case "user":
+			io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z", "user": {"id": "u-1"}, "roles": [{"id": "1", "name": "member"}]}}`)
+		case "service":
+			io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z", "user": {"id": "nova"}, "roles": [{"id": "2", "name": "service"}]}}`)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer idServer.Close()
+
+	var authority Authority
+	var header http.Header
+	h := (&Auth{Endpoint: idServer.URL}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authority = AuthorityFromContext(r.Context())
+		header = r.Header
+	}))
+
+	req := newRequest("GET", "/")
+	req.Header.Set("X-Auth-Token", "user")
+	req.Header.Set("X-Service-Token", "service")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	expected := map[string]string{
+		"X-Identity-Status":         "Confirmed",
+		"X-User-Id":                 "u-1",
+		"X-Roles":                   "member",
+		"X-Service-Identity-Status": "Confirmed",
+		"X-Service-User-Id":         "nova",
+		"X-Service-Roles":           "service",
+	}
+	for k, v := range expected {
+		if got := header.Get(k); got != v {
+			t.Errorf("Expected %s to be %q, got %q", k, v, got)
+		}
+	}
+	if !authority.HasServiceRole("service") || authority.HasUserRole("service") {
+		t.Errorf("Unexpected authority: %+v", authority)
+	}
+
+	req = newRequest("GET", "/")
+	req.Header.Set("X-Auth-Token", "user")
+	req.Header.Set("X-Service-Token", "invalid")
+	req.Header.Set("X-Service-User-Id", "forged")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if header.Get("X-Service-Identity-Status") != "Invalid" || header.Get("X-Service-User-Id") != "" {
+		t.Errorf("Unexpected service headers for invalid service token: %v", header)
+	}
+	if authority.Service != nil {
+		t.Error("Expected no service token in context")
+	}
+}
@@ -0,0 +1,119 @@
+package keystone
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// serviceTokenMargin is the remaining lifetime at which the service token is renewed
+const serviceTokenMargin = time.Minute
+
+// serviceUser holds the token of Auth.ServiceCredentials or Auth.SecondaryServiceCredentials.
+// The token is read without locking, mu only serializes authentications.
+type serviceUser struct {
+	mu      sync.Mutex
+	current atomic.Pointer[serviceTokenState]
+}
+
+// serviceLogin is a service user together with the endpoint and credentials it authenticates with
+type serviceLogin struct {
+	endpoint    string
+	credentials Credentials
+	scope       *Scope
+	user        *serviceUser
+}
+
+// serviceLogin returns the service user authenticating validations against endpoint.
+// It returns nil if tokens are validated using themselves.
+func (a *Auth) serviceLogin(endpoint string) *serviceLogin {
+	if a.SecondaryEndpoint != "" && endpoint == a.SecondaryEndpoint {
+		if a.SecondaryServiceCredentials == nil {
+			return nil
+		}
+		return &serviceLogin{a.SecondaryEndpoint, a.SecondaryServiceCredentials, a.SecondaryServiceScope, &a.secondaryServiceUser}
+	}
+	if a.ServiceCredentials == nil {
+		return nil
+	}
+	return &serviceLogin{a.Endpoint, a.ServiceCredentials, a.ServiceScope, &a.serviceUser}
+}
+
+type serviceTokenState struct {
+	authToken string
+	expiresAt time.Time
+}
+
+// token returns the current service token if it isn't about to expire
+func (s *serviceUser) token() string {
+	if t := s.current.Load(); t != nil && time.Until(t.expiresAt) > serviceTokenMargin {
+		return t.authToken
+	}
+	return ""
+}
+
+// serviceToken returns the token of the service user, authenticating it if necessary
+func (a *Auth) serviceToken(s *serviceLogin) (string, error) {
+	if authToken := s.user.token(); authToken != "" {
+		return authToken, nil
+	}
+	s.user.mu.Lock()
+	defer s.user.mu.Unlock()
+	//another request may have authenticated while we were waiting
+	if authToken := s.user.token(); authToken != "" {
+		return authToken, nil
+	}
+	return a.authenticateServiceUser(s)
+}
+
+// renewServiceToken replaces a service token rejected by Keystone.
+// If another request already replaced it in the meantime, the new token is returned.
+func (a *Auth) renewServiceToken(s *serviceLogin, rejected string) (string, error) {
+	s.user.mu.Lock()
+	defer s.user.mu.Unlock()
+	if authToken := s.user.token(); authToken != rejected && authToken != "" {
+		return authToken, nil
+	}
+	return a.authenticateServiceUser(s)
+}
+
+// RefreshServiceToken immediately re-authenticates the service user given by ServiceCredentials
+// (and the one given by SecondaryServiceCredentials if set),
+// e.g. after its password or application credential was rotated. Otherwise the service token is only
+// renewed shortly before it expires or after Keystone rejected it.
+// It can be wired to an admin endpoint:
+//
+//	admin.HandleFunc("/refresh-service-token", func(w http.ResponseWriter, r *http.Request) {
+//		if err := auth.RefreshServiceToken(); err != nil {
+//			http.Error(w, err.Error(), http.StatusBadGateway)
+//		}
+//	})
+func (a *Auth) RefreshServiceToken() error {
+	if a.ServiceCredentials == nil {
+		return errors.New("No service credentials configured")
+	}
+	var errs []error
+	for _, endpoint := range []string{a.Endpoint, a.SecondaryEndpoint} {
+		s := a.serviceLogin(endpoint)
+		if endpoint == "" || s == nil {
+			continue
+		}
+		s.user.mu.Lock()
+		_, err := a.authenticateServiceUser(s)
+		s.user.mu.Unlock()
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// authenticateServiceUser obtains a new service token, the caller must hold s.user.mu
+func (a *Auth) authenticateServiceUser(s *serviceLogin) (string, error) {
+	authToken, token, err := a.authenticate(s.endpoint, s.credentials, s.scope)
+	if err != nil {
+		return "", fmt.Errorf("Failed to authenticate service user at %s: %w", s.endpoint, err)
+	}
+	s.user.current.Store(&serviceTokenState{authToken: authToken, expiresAt: token.ExpiresAt})
+	return authToken, nil
+}
@@ -0,0 +1,87 @@
+package keystone
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// serviceUserMock is a keystone issuing numbered service tokens of which only the latest one is valid
+type serviceUserMock struct {
+	mu     sync.Mutex
+	issued int
+}
+
+func (m *serviceUserMock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r.Method == "POST" {
+		m.issued++
+		w.Header().Set("X-Subject-Token", "service-"+strconv.Itoa(m.issued))
+		w.WriteHeader(201)
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z", "user": {"id": "u-service"}}}`)
+		return
+	}
+	if r.Header.Get("X-Auth-Token") != "service-"+strconv.Itoa(m.issued) {
+		w.WriteHeader(401)
+		return
+	}
+	io.WriteString(w, `{"token": {"expires_at": "2120-10-09T15:09:12.355Z", "user": {"id": "u-`+r.Header.Get("X-Subject-Token")+`"}}}`)
+}
+
+func (m *serviceUserMock) rotate() {
+	m.mu.Lock()
+	m.issued++
+	m.mu.Unlock()
+}
+
+func TestServiceCredentials(t *testing.T) {
+	mock := &serviceUserMock{}
+	idServer := httptest.NewServer(mock)
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.ServiceCredentials = PasswordCredentials{UserID: "u-service", Password: "secret"}
+
+	token, err := a.Validate("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.User.ID != "u-1234" {
+		t.Errorf("Unexpected token context: %+v", token)
+	}
+	if a.serviceUser.token() != "service-1" {
+		t.Errorf("Expected service token to be service-1, got %q", a.serviceUser.token())
+	}
+
+	//a service token rejected by keystone is renewed
+	mock.rotate()
+	if _, err := a.Validate("1234"); err != nil {
+		t.Fatal(err)
+	}
+	if a.serviceUser.token() != "service-3" {
+		t.Errorf("Expected service token to be renewed, got %q", a.serviceUser.token())
+	}
+}
+
+func TestRefreshServiceToken(t *testing.T) {
+	idServer := httptest.NewServer(&serviceUserMock{})
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	if err := a.RefreshServiceToken(); err == nil {
+		t.Error("Expected refresh without service credentials to fail")
+	}
+	a.ServiceCredentials = PasswordCredentials{UserID: "u-service", Password: "secret"}
+	for i := 1; i <= 2; i++ {
+		if err := a.RefreshServiceToken(); err != nil {
+			t.Fatal(err)
+		}
+		if expected := "service-" + strconv.Itoa(i); a.serviceUser.token() != expected {
+			t.Errorf("Expected service token to be %s, got %q", expected, a.serviceUser.token())
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package keystone
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const shadowPrefix = "X-Shadow-"
+
+// shadow validates the token of a request in shadow mode, see Auth.ShadowMode
+func (h *handler) shadow(w http.ResponseWriter, req *http.Request) {
+	for k := range req.Header {
+		if strings.HasPrefix(k, shadowPrefix) {
+			req.Header.Del(k)
+		}
+	}
+
+	token, _ := h.authenticate(w, req)
+	h.stats.count(token)
+	if token != nil {
+		header := http.Header{}
+		h.setHeaders(header, token, h.route(req.URL.Path))
+		for k, v := range header {
+			req.Header[shadowPrefix+strings.TrimPrefix(k, "X-")] = v
+		}
+		h.log(req.Context(), slog.LevelInfo, "Shadow validation confirmed", "method", req.Method, "path", req.URL.Path, "user_id", token.User.ID)
+	} else {
+		req.Header.Set(shadowPrefix+"Identity-Status", "Invalid")
+		if authToken := req.Header.Get("X-Auth-Token"); authToken != "" {
+			h.log(req.Context(), slog.LevelInfo, "Shadow validation invalid", "method", req.Method, "path", req.URL.Path, "token", RedactToken(authToken))
+		}
+	}
+	h.handler.ServeHTTP(w, req)
+}
@@ -0,0 +1,40 @@
+package keystone
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShadowMode(t *testing.T) {
+	idServer := identityMock(200, `
+{
+  "token": {
+    "expires_at": "2120-10-09T15:09:12.355Z",
+    "issued_at": "2015-10-08T15:09:12.355Z",
+    "user": {"id": "u-42e54ca0c", "name": "arc"}
+  }
+}`)
+	defer idServer.Close()
+
+	req := newRequest("GET", "/foo")
+	req.Header.Set("X-Auth-Token", "1234")
+	req.Header.Set("X-Identity-Status", "Confirmed")
+	req.Header.Set("X-User-Id", "legacy-user")
+	req.Header.Set("X-Shadow-User-Id", "spoofed")
+
+	h := checkHeaders(t, map[string]string{
+		"X-Identity-Status":        "Confirmed",
+		"X-User-Id":                "legacy-user",
+		"X-Shadow-Identity-Status": "Confirmed",
+		"X-Shadow-User-Id":         "u-42e54ca0c",
+	})
+	a := Auth{Endpoint: idServer.URL, ShadowMode: true}
+	rec := httptest.NewRecorder()
+	a.Handler(h).ServeHTTP(rec, req)
+	if body := rec.Body.String(); body != ok {
+		t.Fatalf("wrong body, got %q want %q", body, ok)
+	}
+	if s := a.Stats(); s.Confirmed != 1 {
+		t.Errorf("Expected 1 confirmed request, got %d", s.Confirmed)
+	}
+}
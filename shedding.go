@@ -0,0 +1,52 @@
+package keystone
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrOverloaded is returned for validations shed because too many validations are waiting for a
+// slot to contact Keystone, see Auth.MaxConcurrentValidations
+var ErrOverloaded = errors.New("Too many pending validations")
+
+// validationLimiter limits the number of concurrent requests to Keystone
+type validationLimiter struct {
+	once    sync.Once
+	slots   chan struct{}
+	waiting atomic.Int64
+}
+
+// acquire waits for a slot for contacting Keystone and returns a function releasing it.
+// If more than the applicable ShedQueueDepth validations are already waiting, it fails with ErrOverloaded.
+func (a *Auth) acquire(ctx context.Context) (func(), error) {
+	if a.MaxConcurrentValidations <= 0 {
+		return func() {}, nil
+	}
+	l := &a.limiter
+	l.once.Do(func() { l.slots = make(chan struct{}, a.MaxConcurrentValidations) })
+	release := func() { <-l.slots }
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	depth := validationOptionsFromContext(ctx).ShedQueueDepth
+	if depth == 0 {
+		depth = a.ShedQueueDepth
+	}
+	if depth > 0 && l.waiting.Load() >= int64(depth) {
+		a.stats.shed.Add(1)
+		return nil, ErrOverloaded
+	}
+	l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
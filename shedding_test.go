@@ -0,0 +1,52 @@
+package keystone
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadShedding(t *testing.T) {
+	received := make(chan struct{}, 10)
+	unblock := make(chan struct{})
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-unblock
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z"}}`)
+	}))
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.MaxConcurrentValidations = 1
+	a.ShedQueueDepth = 1
+	a.Routes = []Route{{Pattern: "/**", Access: TokenRequired}}
+	h := a.Handler(okHandler)
+
+	done := make(chan error, 2)
+	go func() { _, err := a.Validate("a"); done <- err }()
+	<-received
+	go func() { _, err := a.Validate("b"); done <- err }()
+	for a.limiter.waiting.Load() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Auth-Token", "c")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 503 with Retry-After for shed request, got %d %v", rec.Code, rec.Header())
+	}
+	if s := a.Stats(); s.Shed != 1 {
+		t.Errorf("Expected one shed validation, got %d", s.Shed)
+	}
+
+	close(unblock)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}
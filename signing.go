@@ -0,0 +1,121 @@
+package keystone
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSignature is returned for forwarded token contexts which aren't signed with an accepted key
+var ErrInvalidSignature = errors.New("Invalid token context signature")
+
+// SigningKey is a shared secret for signing forwarded token contexts with HMAC-SHA256.
+// The ID is transmitted with the signature, so verifiers can select the key.
+type SigningKey struct {
+	ID     string
+	Secret []byte
+	//The key isn't accepted for verification after this time. Never expires if zero.
+	Expires time.Time
+}
+
+// KeyRing signs forwarded token contexts (see TokenHeader and TokenMetadataKey) and verifies them using
+// multiple active keys. This allows rotating keys without restarting all services at once:
+//
+//  1. add the new key to Verification of all services
+//  2. switch Signing of the forwarding services to the new key and move the old one to Verification,
+//     optionally with an Expires covering the rollout
+//  3. remove the old key
+type KeyRing struct {
+	//Key used for signing, it is also accepted for verification
+	Signing SigningKey
+	//Additional keys accepted for verification, e.g. the previous and the upcoming signing key
+	Verification []SigningKey
+}
+
+// SignHeader serializes and signs the token context to the TokenHeader of an outgoing http request
+func (k *KeyRing) SignHeader(t *Token, h http.Header) {
+	h.Set(TokenHeader, k.sign(t))
+}
+
+// VerifyHeader verifies and reconstructs a token context serialized with KeyRing.SignHeader.
+// Expired token contexts are rejected.
+func (k *KeyRing) VerifyHeader(h http.Header) (*Token, error) {
+	v := h.Get(TokenHeader)
+	if v == "" {
+		return nil, ErrNoTokenContext
+	}
+	return k.verify(v)
+}
+
+// SignMetadata serializes and signs the token context to gRPC metadata, see Token.ToMetadata
+func (k *KeyRing) SignMetadata(t *Token) map[string][]string {
+	return map[string][]string{TokenMetadataKey: {k.sign(t)}}
+}
+
+// VerifyMetadata verifies and reconstructs a token context serialized with KeyRing.SignMetadata.
+// Expired token contexts are rejected.
+func (k *KeyRing) VerifyMetadata(md map[string][]string) (*Token, error) {
+	values := md[TokenMetadataKey]
+	if len(values) == 0 {
+		return nil, ErrNoTokenContext
+	}
+	return k.verify(values[0])
+}
+
+// sign returns "<key id>.<payload>.<signature>" with the payload and signature base64 encoded
+func (k *KeyRing) sign(t *Token) string {
+	signed := k.Signing.ID + "." + base64.RawURLEncoding.EncodeToString(t.marshal())
+	return signed + "." + base64.RawURLEncoding.EncodeToString(k.Signing.mac(signed))
+}
+
+func (k *KeyRing) verify(v string) (*Token, error) {
+	i := strings.LastIndexByte(v, '.')
+	if i < 0 {
+		return nil, ErrInvalidSignature
+	}
+	signed := v[:i]
+	id, payload, ok := strings.Cut(signed, ".")
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+	key, ok := k.key(id)
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(v[i+1:])
+	if err != nil || !hmac.Equal(sig, key.mac(signed)) {
+		return nil, ErrInvalidSignature
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, err
+	}
+	t, err := unmarshalToken(data)
+	if err != nil {
+		return nil, err
+	}
+	if !t.Valid() {
+		return nil, ErrTokenExpired
+	}
+	return t, nil
+}
+
+// key returns the active key with the given id
+func (k *KeyRing) key(id string) (SigningKey, bool) {
+	for _, key := range append([]SigningKey{k.Signing}, k.Verification...) {
+		if key.ID == id && len(key.Secret) > 0 && (key.Expires.IsZero() || time.Now().Before(key.Expires)) {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+func (key SigningKey) mac(signed string) []byte {
+	m := hmac.New(sha256.New, key.Secret)
+	m.Write([]byte(signed))
+	return m.Sum(nil)
+}
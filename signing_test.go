@@ -0,0 +1,60 @@
+package keystone
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestKeyRing(t *testing.T) {
+	token := &Token{ExpiresAt: time.Now().Add(time.Hour)}
+	token.User.ID = "u-1"
+
+	old := SigningKey{ID: "2026-01", Secret: []byte("old secret")}
+	current := SigningKey{ID: "2026-02", Secret: []byte("current secret")}
+	sender := &KeyRing{Signing: old}
+	//the receiver already accepts the new key while senders still sign with the old one
+	receiver := &KeyRing{Signing: current, Verification: []SigningKey{old}}
+
+	h := http.Header{}
+	sender.SignHeader(token, h)
+	got, err := receiver.VerifyHeader(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.User.ID != "u-1" {
+		t.Errorf("Unexpected token context: %+v", got)
+	}
+
+	md := (&KeyRing{Signing: current}).SignMetadata(token)
+	if _, err := receiver.VerifyMetadata(md); err != nil {
+		t.Error(err)
+	}
+
+	//keys past their rotation window aren't accepted anymore
+	receiver.Verification[0].Expires = time.Now().Add(-time.Second)
+	if _, err := receiver.VerifyHeader(h); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature for expired key, got %v", err)
+	}
+
+	//forged signatures and unknown keys are rejected
+	for _, v := range []string{
+		(&KeyRing{Signing: SigningKey{ID: current.ID, Secret: []byte("forged")}}).sign(token),
+		"2026-03.e30.AAAA",
+		"garbage",
+	} {
+		if _, err := receiver.VerifyHeader(http.Header{TokenHeader: {v}}); err != ErrInvalidSignature {
+			t.Errorf("Expected ErrInvalidSignature for %s, got %v", v, err)
+		}
+	}
+	if _, err := receiver.VerifyHeader(http.Header{}); err != ErrNoTokenContext {
+		t.Errorf("Expected ErrNoTokenContext, got %v", err)
+	}
+
+	expired := &Token{ExpiresAt: time.Now().Add(-time.Second)}
+	receiver.SignHeader(expired, h)
+	if _, err := receiver.VerifyHeader(h); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Expected ErrTokenExpired, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package keystone
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// flightGroup deduplicates concurrent loads of the same token
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+	//number of callers waiting for the result
+	waiters int
+	token   *Token
+	ttl     time.Duration
+	err     error
+}
+
+// do executes fn unless a call for key is already in flight, in which case it waits for its result.
+// Every caller receives its own copy of the token context.
+func (g *flightGroup) do(key string, fn Loader) (*Token, time.Duration, error) {
+	return g.doCtx(context.Background(), key, func(context.Context) (*Token, time.Duration, error) {
+		return fn(key)
+	})
+}
+
+// doCtx is like do, but callers stop waiting with ctx.Err() once their ctx is done.
+// The call is cancelled when all callers waiting for it gave up.
+func (g *flightGroup) doCtx(ctx context.Context, key string, fn func(context.Context) (*Token, time.Duration, error)) (*Token, time.Duration, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	f, ok := g.calls[key]
+	if !ok {
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = f
+		go func() {
+			f.token, f.ttl, f.err = fn(fctx)
+			g.forget(key, f)
+			cancel()
+			close(f.done)
+		}()
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		g.mu.Lock()
+		if f.waiters--; f.waiters == 0 {
+			f.cancel()
+			g.forgetLocked(key, f)
+		}
+		g.mu.Unlock()
+		return nil, 0, ctx.Err()
+	}
+
+	if f.err != nil {
+		return nil, 0, f.err
+	}
+	token := *f.token
+	return &token, f.ttl, nil
+}
+
+// forget removes the flight, so later calls start a new one
+func (g *flightGroup) forget(key string, f *flight) {
+	g.mu.Lock()
+	g.forgetLocked(key, f)
+	g.mu.Unlock()
+}
+
+func (g *flightGroup) forgetLocked(key string, f *flight) {
+	if g.calls[key] == f {
+		delete(g.calls, key)
+	}
+}
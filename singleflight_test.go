@@ -0,0 +1,164 @@
+package keystone
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrentValidationsCoalesced(t *testing.T) {
+	var calls int32
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z", "user": {"id": "u-1"}}}`)
+	}))
+	defer idServer.Close()
+
+	a := Auth{Endpoint: idServer.URL}
+	a.Handler(okHandler)
+
+	var wg sync.WaitGroup
+	tokens := make([]*Token, 20)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token, err := a.Validate("1234")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			tokens[i] = token
+		}(i)
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected concurrent validations to be coalesced into 1 request, got %d", n)
+	}
+	if tokens[0] == tokens[1] {
+		t.Error("Expected every caller to receive its own token context")
+	}
+}
+
+func TestValidationCancelled(t *testing.T) {
+	release := make(chan struct{})
+	cancelled := make(chan struct{}, 1)
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+			io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u-1"}}}`)
+		case <-r.Context().Done():
+			cancelled <- struct{}{}
+		}
+	}))
+	defer idServer.Close()
+	defer close(release)
+
+	a := Auth{Endpoint: idServer.URL}
+	a.Handler(okHandler)
+
+	//a validation nobody waits for anymore is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := a.ValidateContext(ctx, "1234"); !errors.Is(err, context.DeadlineExceeded) || !IsUnavailable(err) {
+		t.Errorf("Expected deadline to be exceeded, got %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the Keystone request to be cancelled")
+	}
+
+	//a shared validation continues as long as any request waits for it
+	ctx, cancel = context.WithCancel(context.Background())
+	go a.ValidateContext(ctx, "1234")
+	time.Sleep(10 * time.Millisecond)
+	done := make(chan error)
+	go func() {
+		_, err := a.ValidateContext(context.Background(), "1234")
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	release <- struct{}{}
+	if err := <-done; err != nil {
+		t.Errorf("Expected remaining request to receive the token, got %v", err)
+	}
+}
+
+func TestValidationTimeout(t *testing.T) {
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer idServer.Close()
+
+	a := Auth{Endpoint: idServer.URL, ValidationTimeout: 20 * time.Millisecond}
+	a.Handler(okHandler)
+	start := time.Now()
+	if _, err := a.Validate("1234"); !IsUnavailable(err) {
+		t.Errorf("Expected timeout, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Expected validation to time out after ValidationTimeout, took %s", time.Since(start))
+	}
+}
+
+// slowWriteCache signals writes and blocks them until released
+type slowWriteCache struct {
+	Cache
+	setting chan struct{}
+	release chan struct{}
+}
+
+func (c *slowWriteCache) Set(key string, value interface{}, ttl time.Duration) {
+	c.setting <- struct{}{}
+	<-c.release
+	c.Cache.Set(key, value, ttl)
+}
+
+func TestFlightEndsAfterCacheWrite(t *testing.T) {
+	var calls int32
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u-1"}}}`)
+	}))
+	defer idServer.Close()
+
+	cache := &slowWriteCache{Cache: NewInMemoryCache(10), setting: make(chan struct{}), release: make(chan struct{})}
+	a := Auth{Endpoint: idServer.URL, TokenCache: cache}
+	a.Handler(okHandler)
+
+	done := make(chan error, 2)
+	go func() {
+		_, err := a.Validate("1234")
+		done <- err
+	}()
+	<-cache.setting
+	//a request arriving while the token is being cached joins the flight instead of validating it again
+	go func() {
+		_, err := a.Validate("1234")
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(cache.release)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected 1 request to Keystone, got %d", n)
+	}
+}
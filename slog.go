@@ -0,0 +1,123 @@
+package keystone
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// log emits a structured event to Auth.Logger. If no Logger is configured events of level Info and above
+// are formatted as "message key=value ..." and passed to the package level Log function.
+// args are alternating keys and values.
+func (a *Auth) log(ctx context.Context, level slog.Level, msg string, args ...interface{}) {
+	if a.Logger != nil {
+		a.Logger.Log(ctx, level, msg, args...)
+		return
+	}
+	if level < slog.LevelInfo {
+		return
+	}
+	var b strings.Builder
+	if level >= slog.LevelWarn {
+		b.WriteString("WARNING: ")
+	}
+	b.WriteString(msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+	Log("%s", b.String())
+}
+
+// LogValue implements slog.LogValuer, logging the ids of the token's user and scope
+func (t Token) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("user_id", t.User.ID)}
+	if t.Project != nil {
+		attrs = append(attrs, slog.String("project_id", t.Project.ID))
+	}
+	if t.Domain != nil {
+		attrs = append(attrs, slog.String("domain_id", t.Domain.ID))
+	}
+	if t.systemScoped() {
+		attrs = append(attrs, slog.String("system_scope", "all"))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// LogHandler is a slog.Handler attaching the identity of authenticated requests to log records.
+// Records logged with the context of a request passing through Auth.Handler get
+// user_id, project_id and request_id attributes:
+//
+//	logger := slog.New(keystone.NewLogHandler(slog.NewJSONHandler(os.Stderr, nil)))
+//	logger.InfoContext(r.Context(), "deleting server")
+//
+// The identity attributes are never nested in groups opened with WithGroup. For loggers with groups
+// the wrapped handler is rebuilt for each record carrying identity attributes, which is more expensive.
+type LogHandler struct {
+	slog.Handler
+
+	//wrapped handler before the first WithGroup, nil if no group was opened
+	root slog.Handler
+	//WithGroup and WithAttrs calls since the first group, replayed on top of root and the identity attributes
+	calls []func(slog.Handler) slog.Handler
+}
+
+// NewLogHandler wraps h
+func NewLogHandler(h slog.Handler) *LogHandler {
+	return &LogHandler{Handler: h}
+}
+
+// Handle adds the identity attributes found in ctx to r and passes it to the wrapped handler
+func (h *LogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var attrs []slog.Attr
+	if t, ok := TokenFromContext(ctx); ok {
+		attrs = append(attrs, slog.String("user_id", t.User.ID))
+		if t.Project != nil {
+			attrs = append(attrs, slog.String("project_id", t.Project.ID))
+		}
+	}
+	if id := requestIDFromContext(ctx); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if len(attrs) == 0 {
+		return h.Handler.Handle(ctx, r)
+	}
+	if h.root == nil {
+		r.AddAttrs(attrs...)
+		return h.Handler.Handle(ctx, r)
+	}
+	handler := h.root.WithAttrs(attrs)
+	for _, call := range h.calls {
+		handler = call(handler)
+	}
+	return handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a LogHandler wrapping the result of the wrapped handler's WithAttrs
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(h.Handler.WithAttrs(attrs), h.root, func(handler slog.Handler) slog.Handler {
+		return handler.WithAttrs(attrs)
+	})
+}
+
+// WithGroup returns a LogHandler wrapping the result of the wrapped handler's WithGroup
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	root := h.root
+	if root == nil {
+		root = h.Handler
+	}
+	return h.with(h.Handler.WithGroup(name), root, func(handler slog.Handler) slog.Handler {
+		return handler.WithGroup(name)
+	})
+}
+
+// with returns a LogHandler wrapping handler, recording call for replaying it on top of root if a group was opened
+func (h *LogHandler) with(handler, root slog.Handler, call func(slog.Handler) slog.Handler) *LogHandler {
+	if root == nil {
+		return &LogHandler{Handler: handler}
+	}
+	return &LogHandler{Handler: handler, root: root, calls: append(h.calls[:len(h.calls):len(h.calls)], call)}
+}
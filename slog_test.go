@@ -0,0 +1,84 @@
+package keystone
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogHandler(t *testing.T) {
+	idServer := identityMock(200, `
+{
+  "token": {
+    "expires_at": "2120-10-09T15:09:12.355Z",
+    "issued_at": "2015-10-08T15:09:12.355Z",
+    "user": {"id": "u-42e54ca0c", "name": "arc"},
+    "project": {"id": "p-d61611de1", "name": "Arc"}
+  }
+}`)
+	defer idServer.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewTextHandler(&buf, nil)))
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "hello")
+	})
+
+	req := newRequest("GET", "/foo")
+	req.Header.Set("X-Auth-Token", "1234")
+	req.Header.Set("X-Openstack-Request-Id", "req-1")
+	a := Auth{Endpoint: idServer.URL}
+	a.Handler(h).ServeHTTP(httptest.NewRecorder(), req)
+
+	for _, attr := range []string{"user_id=u-42e54ca0c", "project_id=p-d61611de1", "request_id=req-1"} {
+		if !strings.Contains(buf.String(), attr) {
+			t.Errorf("Expected log record to contain %s, got %s", attr, buf.String())
+		}
+	}
+
+	//identity attributes aren't nested in groups of the logger
+	buf.Reset()
+	logger = slog.New(NewLogHandler(slog.NewTextHandler(&buf, nil))).With("a", 1).WithGroup("g").With("b", 2)
+	a.Handler(h).ServeHTTP(httptest.NewRecorder(), req)
+	for _, attr := range []string{" a=1 ", "user_id=u-42e54ca0c", "project_id=p-d61611de1", "request_id=req-1", "g.b=2"} {
+		if !strings.Contains(buf.String(), attr) {
+			t.Errorf("Expected log record to contain %s, got %s", attr, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "g.user_id") {
+		t.Errorf("Expected identity attributes outside of the group, got %s", buf.String())
+	}
+}
+
+func TestLogger(t *testing.T) {
+	idServer := identityMock(404, `{"error": {"code": 404, "message": "Could not find token", "title": "Not Found"}}`)
+	defer idServer.Close()
+
+	var buf bytes.Buffer
+	cache := cacheMock{}
+	a := Auth{
+		Endpoint:   idServer.URL,
+		TokenCache: &cache,
+		Logger:     slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+	req := newRequest("GET", "/foo")
+	req.Header.Set("X-Auth-Token", "secret-token-abcdef")
+	a.Handler(okHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+	for _, event := range []string{
+		`level=DEBUG msg="Token cache miss"`,
+		`level=DEBUG msg="Keystone request"`,
+		"status=404",
+		`level=INFO msg="Token validation failed"`,
+	} {
+		if !strings.Contains(buf.String(), event) {
+			t.Errorf("Expected log to contain %s, got %s", event, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "secret-token") {
+		t.Errorf("Expected token to be redacted, got %s", buf.String())
+	}
+}
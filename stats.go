@@ -0,0 +1,122 @@
+package keystone
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Stats contains counters describing the middleware's operation
+type Stats struct {
+	//Number of requests passed on with X-Identity-Status Confirmed
+	Confirmed uint64
+	//Number of requests passed on with X-Identity-Status Invalid
+	Invalid uint64
+	//Number of requests answered by Keystone with 429 Too Many Requests
+	Throttled uint64
+	//Number of confirmed requests by the Keystone endpoint which validated the token
+	Issuers map[string]uint64
+	//Number of tokens successfully validated against Keystone
+	Validated uint64
+	//Number of validated tokens expiring before Auth.CacheTime, which are cached for less than CacheTime.
+	//If this is a large share of Validated the deployment issues short lived tokens and CacheTime should be tuned.
+	ExpiringBeforeCacheTime uint64
+	//Number of cache writes dropped because the workers of Auth.AsyncCacheWrites were busy
+	DroppedCacheWrites uint64
+	//Number of stale tokens served from the cache while Keystone was unavailable, see Auth.StaleCacheTime
+	ServedStale uint64
+	//Number of validations shed because too many validations were waiting for Keystone, see Auth.ShedQueueDepth
+	Shed uint64
+	//Number of cached tokens revalidated in the background, see Auth.RefreshAhead
+	Refreshed uint64
+	//Number of token cache lookups exceeding Auth.CacheTimeout
+	CacheTimeouts uint64
+	//Number of validations not sent to Keystone because of Auth.ValidationRate or Auth.ValidationRatePerIP
+	RateLimited uint64
+}
+
+// lifetimeWindow is the number of validations after which the share of tokens
+// expiring before CacheTime is evaluated
+const lifetimeWindow = 100
+
+type stats struct {
+	confirmed     atomic.Uint64
+	invalid       atomic.Uint64
+	throttled     atomic.Uint64
+	issuers       sync.Map
+	validatedN    atomic.Uint64
+	expiringEarly atomic.Uint64
+
+	droppedCacheWrites atomic.Uint64
+	servedStale        atomic.Uint64
+	shed               atomic.Uint64
+	refreshed          atomic.Uint64
+	cacheHits          atomic.Uint64
+	cacheMisses        atomic.Uint64
+	cacheTimeouts      atomic.Uint64
+	rateLimited        atomic.Uint64
+
+	mu            sync.Mutex
+	windowTotal   uint64
+	windowExpired uint64
+}
+
+// Stats returns the current counters
+func (a *Auth) Stats() Stats {
+	return Stats{
+		Confirmed: a.stats.confirmed.Load(),
+		Invalid:   a.stats.invalid.Load(),
+		Throttled: a.stats.throttled.Load(),
+		Issuers:   a.stats.issuerCounts(),
+
+		Validated:               a.stats.validatedN.Load(),
+		ExpiringBeforeCacheTime: a.stats.expiringEarly.Load(),
+		DroppedCacheWrites:      a.stats.droppedCacheWrites.Load(),
+		ServedStale:             a.stats.servedStale.Load(),
+		Shed:                    a.stats.shed.Load(),
+		Refreshed:               a.stats.refreshed.Load(),
+		CacheTimeouts:           a.stats.cacheTimeouts.Load(),
+		RateLimited:             a.stats.rateLimited.Load(),
+	}
+}
+
+// validated counts a token validated against Keystone. Every lifetimeWindow validations it reports
+// how many tokens of the window expired before CacheTime.
+func (s *stats) validated(expiringEarly bool) (expired, total uint64, report bool) {
+	s.validatedN.Add(1)
+	if expiringEarly {
+		s.expiringEarly.Add(1)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.windowTotal++
+	if expiringEarly {
+		s.windowExpired++
+	}
+	if s.windowTotal < lifetimeWindow {
+		return 0, 0, false
+	}
+	expired, total = s.windowExpired, s.windowTotal
+	s.windowExpired, s.windowTotal = 0, 0
+	return expired, total, true
+}
+
+func (s *stats) issuerCounts() map[string]uint64 {
+	counts := map[string]uint64{}
+	s.issuers.Range(func(k, v interface{}) bool {
+		counts[k.(string)] = v.(*atomic.Uint64).Load()
+		return true
+	})
+	return counts
+}
+
+func (s *stats) count(token *Token) {
+	if token != nil {
+		s.confirmed.Add(1)
+		if token.Issuer != "" {
+			c, _ := s.issuers.LoadOrStore(token.Issuer, new(atomic.Uint64))
+			c.(*atomic.Uint64).Add(1)
+		}
+	} else {
+		s.invalid.Add(1)
+	}
+}
@@ -0,0 +1,37 @@
+package keystone
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpiringBeforeCacheTime(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z"}}`)
+	defer idServer.Close()
+
+	var warnings []string
+	defer func(log func(string, ...interface{})) { Log = log }(Log)
+	Log = func(format string, a ...interface{}) {
+		if msg := fmt.Sprintf(format, a...); strings.HasPrefix(msg, "WARNING") {
+			warnings = append(warnings, msg)
+		}
+	}
+
+	//the tokens expire in about a hundred years, way before the cache time
+	a := Auth{Endpoint: idServer.URL, CacheTime: 200 * 365 * 24 * time.Hour}
+	a.Handler(okHandler)
+	for i := 0; i < lifetimeWindow; i++ {
+		if _, err := a.Validate(strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := a.Stats(); s.Validated != lifetimeWindow || s.ExpiringBeforeCacheTime != lifetimeWindow {
+		t.Errorf("Unexpected stats: %+v", s)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("Expected one warning, got %v", warnings)
+	}
+}
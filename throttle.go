@@ -0,0 +1,70 @@
+package keystone
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ErrThrottled is returned while Keystone is throttling validation requests (HTTP 429).
+// No requests are sent to Keystone until the period given by its Retry-After header has passed.
+var ErrThrottled = errors.New("Keystone is throttling requests")
+
+const (
+	//backoff used if Keystone doesn't send a usable Retry-After header
+	defaultThrottleBackoff = 1 * time.Second
+	//upper bound for backing off, regardless of the Retry-After header
+	maxThrottleBackoff = 1 * time.Minute
+	//how long Keystone is considered degraded after it last asked us to back off, see Auth.ThrottledCacheFactor
+	degradedWindow = 5 * time.Minute
+)
+
+// throttleState keeps track of Keystone asking us to back off
+type throttleState struct {
+	until         atomic.Int64
+	degradedUntil atomic.Int64
+}
+
+func (t *throttleState) throttled() bool {
+	return time.Now().UnixNano() < t.until.Load()
+}
+
+// degraded reports if Keystone recently asked us to back off
+func (t *throttleState) degraded() bool {
+	return time.Now().UnixNano() < t.degradedUntil.Load()
+}
+
+// degrade records Keystone signalling degraded operation without rejecting the request
+func (t *throttleState) degrade() {
+	t.degradedUntil.Store(time.Now().Add(degradedWindow).UnixNano())
+}
+
+// backoff records a 429 response and returns how long to back off
+func (t *throttleState) backoff(r *http.Response) time.Duration {
+	d := retryAfter(r.Header.Get("Retry-After"))
+	if d <= 0 {
+		d = defaultThrottleBackoff
+	}
+	if d > maxThrottleBackoff {
+		d = maxThrottleBackoff
+	}
+	t.until.Store(time.Now().Add(d).UnixNano())
+	t.degrade()
+	return d
+}
+
+// retryAfter parses the value of a Retry-After header which is either a number of seconds or a http date
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(v); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
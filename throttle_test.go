@@ -0,0 +1,66 @@
+package keystone
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestThrottling(t *testing.T) {
+	var requests atomic.Int32
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	for i := 0; i < 3; i++ {
+		if _, err := a.Validate("1234"); err != ErrThrottled {
+			t.Fatalf("Expected %v, got %v", ErrThrottled, err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected a single request to keystone while throttled, got %d", n)
+	}
+	if s := a.Stats(); s.Throttled != 1 {
+		t.Errorf("Expected throttle count 1, got %d", s.Throttled)
+	}
+}
+
+func TestThrottledCacheFactor(t *testing.T) {
+	idServer := identityMock(200, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z"}}`)
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.CacheTime = time.Minute
+	a.ThrottledCacheFactor = 5
+	if _, ttl, err := a.load(context.Background(), a.Endpoint, "1234"); err != nil || ttl != time.Minute {
+		t.Errorf("Expected CacheTime while Keystone is healthy, got %s, %v", ttl, err)
+	}
+	a.throttle.degrade()
+	if _, ttl, err := a.load(context.Background(), a.Endpoint, "1234"); err != nil || ttl != 5*time.Minute {
+		t.Errorf("Expected extended CacheTime while Keystone is degraded, got %s, %v", ttl, err)
+	}
+	a.throttle.degradedUntil.Store(time.Now().Add(-time.Second).UnixNano())
+	if _, ttl, err := a.load(context.Background(), a.Endpoint, "1234"); err != nil || ttl != time.Minute {
+		t.Errorf("Expected CacheTime after Keystone recovered, got %s, %v", ttl, err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d := retryAfter("120"); d != 120*time.Second {
+		t.Errorf("Expected 120s, got %s", d)
+	}
+	date := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	if d := retryAfter(date); d <= 8*time.Second || d > 10*time.Second {
+		t.Errorf("Expected about 10s, got %s", d)
+	}
+	if d := retryAfter("soon"); d != 0 {
+		t.Errorf("Expected 0 for invalid header, got %s", d)
+	}
+}
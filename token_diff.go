@@ -0,0 +1,69 @@
+package keystone
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+// TokenDiff describes how the context of a token changed between two validations, e.g. because roles were
+// granted or revoked while the token was cached. See Auth.OnTokenChange.
+type TokenDiff struct {
+	//Roles the token gained and lost
+	AddedRoles   []string
+	RemovedRoles []string
+	//Scope before and after, e.g. "project:p-1", "domain:d-1" or "system:all". Empty for unscoped tokens.
+	OldScope string
+	NewScope string
+}
+
+// Changed reports whether the token context changed
+func (d TokenDiff) Changed() bool {
+	return len(d.AddedRoles) > 0 || len(d.RemovedRoles) > 0 || d.OldScope != d.NewScope
+}
+
+// DiffTokens compares the roles and scope of two contexts of a token
+func DiffTokens(old, fresh *Token) TokenDiff {
+	oldRoles, freshRoles := old.RoleNames(), fresh.RoleNames()
+	d := TokenDiff{OldScope: old.scope(), NewScope: fresh.scope()}
+	for _, r := range freshRoles {
+		if !slices.Contains(oldRoles, r) {
+			d.AddedRoles = append(d.AddedRoles, r)
+		}
+	}
+	for _, r := range oldRoles {
+		if !slices.Contains(freshRoles, r) {
+			d.RemovedRoles = append(d.RemovedRoles, r)
+		}
+	}
+	return d
+}
+
+// scope describes the scope of the token, see TokenDiff
+func (t Token) scope() string {
+	switch {
+	case t.Project != nil:
+		return "project:" + t.Project.ID
+	case t.Domain != nil:
+		return "domain:" + t.Domain.ID
+	case t.systemScoped():
+		return "system:all"
+	}
+	return ""
+}
+
+// reportChanges logs changes of a token's context since it was cached and calls Auth.OnTokenChange
+func (a *Auth) reportChanges(ctx context.Context, authToken string, cached, fresh *Token) {
+	if cached == nil || fresh == nil {
+		return
+	}
+	d := DiffTokens(cached, fresh)
+	if !d.Changed() {
+		return
+	}
+	a.log(ctx, slog.LevelInfo, "Token context changed since it was cached", "token", RedactToken(authToken), "user_id", fresh.User.ID,
+		"roles_added", d.AddedRoles, "roles_removed", d.RemovedRoles, "old_scope", d.OldScope, "new_scope", d.NewScope)
+	if a.OnTokenChange != nil {
+		a.OnTokenChange(cached, fresh, d)
+	}
+}
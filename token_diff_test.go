@@ -0,0 +1,85 @@
+package keystone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiffTokens(t *testing.T) {
+	old := &Token{Project: &Project{ID: "p-1"}, Roles: []struct {
+		ID   string
+		Name string
+	}{{"r-1", "member"}, {"r-2", "reader"}}}
+	fresh := &Token{Domain: &Domain{ID: "d-1"}, Roles: []struct {
+		ID   string
+		Name string
+	}{{"r-2", "reader"}, {"r-3", "admin"}}}
+
+	d := DiffTokens(old, fresh)
+	expected := TokenDiff{AddedRoles: []string{"admin"}, RemovedRoles: []string{"member"}, OldScope: "project:p-1", NewScope: "domain:d-1"}
+	if !reflect.DeepEqual(d, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, d)
+	}
+	if !d.Changed() {
+		t.Error("Expected diff to report a change")
+	}
+	if DiffTokens(old, old).Changed() {
+		t.Error("Expected no change comparing a token with itself")
+	}
+}
+
+func TestTokenChange(t *testing.T) {
+	var role atomic.Value
+	role.Store("member")
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "user": {"id": "u"}, "roles": [{"name": %q}]}}`, role.Load())
+	}))
+	defer idServer.Close()
+
+	a := New(idServer.URL)
+	a.TokenCache = NewInMemoryCache(10)
+	a.CacheTime = time.Minute
+	a.RefreshAhead = time.Minute - 20*time.Millisecond
+	changes := make(chan TokenDiff, 2)
+	a.OnTokenChange = func(cached, fresh *Token, d TokenDiff) { changes <- d }
+
+	if _, err := a.Validate("1234"); err != nil {
+		t.Fatal(err)
+	}
+
+	//forced revalidation
+	role.Store("admin")
+	ctx := WithValidationOptions(context.Background(), ValidationOptions{SkipCache: true})
+	if _, err := a.ValidateContext(ctx, "1234"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case d := <-changes:
+		if !reflect.DeepEqual(d.AddedRoles, []string{"admin"}) || !reflect.DeepEqual(d.RemovedRoles, []string{"member"}) {
+			t.Errorf("Unexpected diff %+v", d)
+		}
+	default:
+		t.Error("Expected role change to be reported")
+	}
+
+	//refresh ahead
+	role.Store("reader")
+	time.Sleep(30 * time.Millisecond)
+	if _, err := a.Validate("1234"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case d := <-changes:
+		if !reflect.DeepEqual(d.AddedRoles, []string{"reader"}) {
+			t.Errorf("Unexpected diff %+v", d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected role change to be reported by the background refresh")
+	}
+}
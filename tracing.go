@@ -0,0 +1,13 @@
+package keystone
+
+import "context"
+
+// Tracer traces token validations.
+// See the tracing/otel package for an OpenTelemetry implementation.
+type Tracer interface {
+	//StartValidation is called with the context of the request being authenticated before validating its token.
+	//The returned context is used for the validation including the requests sent to Keystone,
+	//the returned function is called once the validation finished.
+	//cached reports if the token context was served from the cache.
+	StartValidation(ctx context.Context) (context.Context, func(cached bool, err error))
+}
@@ -0,0 +1,94 @@
+// Package otel provides OpenTelemetry tracing for https://github.com/databus23/keystone.
+//
+//	tracer := otel.New(otelapi.GetTracerProvider())
+//	auth := keystone.New("https://keystone:5000/v3")
+//	auth.Tracer = tracer
+//	auth.Client = &http.Client{Transport: tracer.Transport(nil), Timeout: 5 * time.Second}
+package otel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/databus23/keystone"
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/databus23/keystone"
+
+// Tracer implements keystone.Tracer
+type Tracer struct {
+	tracer trace.Tracer
+	//Propagator used for injecting the trace context into requests to Keystone.
+	//Defaults to the global propagator.
+	Propagator propagation.TextMapPropagator
+}
+
+// New returns a tracer creating spans using tp
+func New(tp trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+// StartValidation starts a keystone.validate span, see keystone.Tracer
+func (t *Tracer) StartValidation(ctx context.Context) (context.Context, func(cached bool, err error)) {
+	ctx, span := t.tracer.Start(ctx, "keystone.validate", trace.WithSpanKind(trace.SpanKindInternal))
+	return ctx, func(cached bool, err error) {
+		span.SetAttributes(attribute.Bool("keystone.cache_hit", cached))
+		var kerr *keystone.Error
+		if errors.As(err, &kerr) {
+			span.SetAttributes(attribute.Int("http.response.status_code", kerr.StatusCode))
+		}
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// Transport returns a http.RoundTripper creating client spans for requests to Keystone
+// and propagating the trace context via request headers. base defaults to http.DefaultTransport.
+func (t *Tracer) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{tracer: t, base: base}
+}
+
+type transport struct {
+	tracer *Tracer
+	base   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.tracer.Start(req.Context(), "keystone "+req.Method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Host),
+		))
+	defer span.End()
+
+	propagator := t.tracer.Propagator
+	if propagator == nil {
+		propagator = otelapi.GetTextMapPropagator()
+	}
+	req = req.Clone(ctx)
+	propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
+
+var _ keystone.Tracer = &Tracer{}
@@ -0,0 +1,59 @@
+package otel
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/databus23/keystone"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	var traceparent string
+	idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		io.WriteString(w, `{"token": {"expires_at": "2120-10-08T08:40:33.100Z", "issued_at": "2015-10-08T07:40:33.099Z"}}`)
+	}))
+	defer idServer.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	tracer.Propagator = propagation.TraceContext{}
+
+	a := keystone.Auth{
+		Endpoint:   idServer.URL,
+		TokenCache: keystone.NewInMemoryCache(10),
+		Tracer:     tracer,
+		Client:     &http.Client{Transport: tracer.Transport(nil)},
+	}
+	a.Handler(http.NotFoundHandler())
+	for i := 0; i < 2; i++ {
+		if _, err := a.ValidateContext(context.Background(), "1234"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+	request, first, second := spans[0], spans[1], spans[2]
+	if request.Name() != "keystone GET" || request.Parent().SpanID() != first.SpanContext().SpanID() {
+		t.Errorf("Expected keystone request span to be a child of the validation span")
+	}
+	if traceparent == "" {
+		t.Error("Expected trace context to be propagated to keystone")
+	}
+	for i, span := range []sdktrace.ReadOnlySpan{first, second} {
+		for _, attr := range span.Attributes() {
+			if attr.Key == "keystone.cache_hit" && attr.Value.AsBool() != (i == 1) {
+				t.Errorf("Unexpected cache_hit attribute for validation %d: %v", i, attr.Value.AsBool())
+			}
+		}
+	}
+}
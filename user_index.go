@@ -0,0 +1,190 @@
+package keystone
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// userIndexSweepInterval is the minimum period between two sweeps of expired entries of all users
+const userIndexSweepInterval = time.Minute
+
+// UserIndex is a cache wrapper maintaining a secondary index from user ids to the cached tokens of the user.
+// This makes per user invalidation and session queries cheap without scanning the cache.
+//
+//	index := keystone.NewUserIndex(memory.New(time.Minute))
+//	auth.TokenCache = index
+//	...
+//	index.InvalidateUser(userID) //e.g. after a password change
+//
+// The index only covers tokens cached by this process. If c implements EvictionNotifier the index registers itself
+// to drop evicted entries, use UserIndex.OnEvict instead of the one of c for receiving evictions.
+type UserIndex struct {
+	cache Cache
+
+	mu      sync.Mutex
+	users   map[string]map[string]time.Time
+	keys    map[string]string
+	onEvict func(string, EvictReason)
+
+	lastSweep time.Time
+}
+
+// NewUserIndex returns a cache storing its entries in c while indexing them by user id
+func NewUserIndex(c Cache) *UserIndex {
+	i := &UserIndex{
+		cache: c,
+		users: make(map[string]map[string]time.Time),
+		keys:  make(map[string]string),
+	}
+	if n, ok := c.(EvictionNotifier); ok {
+		n.OnEvict(i.evicted)
+	}
+	return i
+}
+
+// Sessions returns the number of unexpired cached tokens of a user
+func (i *UserIndex) Sessions(userID string) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.prune(userID, time.Now())
+	return len(i.users[userID])
+}
+
+// InvalidateUser removes all cached tokens of a user, forcing them to be validated against Keystone again.
+// Entries can only be removed if the underlying cache implements Deleter.
+// It returns the number of removed tokens.
+func (i *UserIndex) InvalidateUser(userID string) int {
+	i.mu.Lock()
+	keys := i.users[userID]
+	delete(i.users, userID)
+	for key := range keys {
+		delete(i.keys, key)
+	}
+	i.mu.Unlock()
+
+	d, ok := i.cache.(Deleter)
+	if !ok {
+		return 0
+	}
+	for key := range keys {
+		d.Delete(key)
+	}
+	return len(keys)
+}
+
+// Set stores a value with the given ttl and indexes it if it is a token context
+func (i *UserIndex) Set(key string, value interface{}, ttl time.Duration) {
+	i.SetCtx(context.Background(), key, value, ttl)
+}
+
+// Get retrieves a value previously stored in the cache
+func (i *UserIndex) Get(key string, value interface{}) bool {
+	return i.GetCtx(context.Background(), key, value)
+}
+
+// SetCtx stores a value with the given ttl, see CacheCtx
+func (i *UserIndex) SetCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+	cacheSet(ctx, i.cache, key, value, ttl)
+	if userID := userOf(value); userID != "" {
+		i.add(userID, key, time.Now().Add(ttl))
+	}
+}
+
+// GetCtx retrieves a value previously stored in the cache, see CacheCtx
+func (i *UserIndex) GetCtx(ctx context.Context, key string, value interface{}) bool {
+	return cacheGet(ctx, i.cache, key, value)
+}
+
+// Delete removes an entry if the underlying cache implements Deleter
+func (i *UserIndex) Delete(key string) {
+	i.remove(key)
+	if d, ok := i.cache.(Deleter); ok {
+		d.Delete(key)
+	}
+}
+
+// OnEvict registers a function called for entries evicted from the underlying cache, see EvictionNotifier
+func (i *UserIndex) OnEvict(f func(key string, reason EvictReason)) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.onEvict = f
+}
+
+func (i *UserIndex) evicted(key string, reason EvictReason) {
+	i.remove(key)
+	i.mu.Lock()
+	f := i.onEvict
+	i.mu.Unlock()
+	if f != nil {
+		f(key, reason)
+	}
+}
+
+func (i *UserIndex) add(userID, key string, until time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if previous, ok := i.keys[key]; ok && previous != userID {
+		delete(i.users[previous], key)
+	}
+	if i.users[userID] == nil {
+		i.users[userID] = make(map[string]time.Time)
+	}
+	i.users[userID][key] = until
+	i.keys[key] = userID
+	i.sweep(time.Now())
+}
+
+func (i *UserIndex) remove(key string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	userID, ok := i.keys[key]
+	if !ok {
+		return
+	}
+	delete(i.keys, key)
+	delete(i.users[userID], key)
+	if len(i.users[userID]) == 0 {
+		delete(i.users, userID)
+	}
+}
+
+// prune drops expired entries of a user. Must be called with i.mu held.
+func (i *UserIndex) prune(userID string, now time.Time) {
+	for key, until := range i.users[userID] {
+		if !now.Before(until) {
+			delete(i.users[userID], key)
+			delete(i.keys, key)
+		}
+	}
+	if len(i.users[userID]) == 0 {
+		delete(i.users, userID)
+	}
+}
+
+// sweep drops expired entries of all users, at most once per userIndexSweepInterval.
+// Tokens of users which are never seen again would pile up otherwise. Must be called with i.mu held.
+func (i *UserIndex) sweep(now time.Time) {
+	if now.Sub(i.lastSweep) < userIndexSweepInterval {
+		return
+	}
+	i.lastSweep = now
+	for userID := range i.users {
+		i.prune(userID, now)
+	}
+}
+
+// userOf returns the user id of cached token contexts
+func userOf(value interface{}) string {
+	switch v := value.(type) {
+	case Token:
+		return v.User.ID
+	case *Token:
+		return v.User.ID
+	case cachedToken:
+		return v.Token.User.ID
+	case loadedToken:
+		return v.Token.User.ID
+	}
+	return ""
+}
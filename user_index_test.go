@@ -0,0 +1,86 @@
+package keystone
+
+import (
+	"testing"
+	"time"
+)
+
+type deletingCacheMock struct {
+	cacheMock
+	onEvict func(string, EvictReason)
+}
+
+func (c *deletingCacheMock) Delete(k string) {
+	delete(c.cacheMock, k)
+}
+
+func (c *deletingCacheMock) OnEvict(f func(string, EvictReason)) {
+	c.onEvict = f
+}
+
+func userToken(userID string) Token {
+	var t Token
+	t.User.ID = userID
+	return t
+}
+
+func TestUserIndex(t *testing.T) {
+	cache := &deletingCacheMock{cacheMock: cacheMock{}}
+	index := NewUserIndex(cache)
+
+	index.Set("t1", userToken("u-1"), time.Minute)
+	index.Set("t2", userToken("u-1"), time.Minute)
+	index.Set("t3", userToken("u-2"), time.Minute)
+	index.Set("t4", userToken("u-2"), -time.Second)
+	index.Set("other", "not a token", time.Minute)
+
+	if n := index.Sessions("u-1"); n != 2 {
+		t.Errorf("Expected 2 sessions for u-1, got %d", n)
+	}
+	if n := index.Sessions("u-2"); n != 1 {
+		t.Errorf("Expected expired token not to be counted, got %d sessions", n)
+	}
+
+	var evicted []string
+	index.OnEvict(func(key string, _ EvictReason) { evicted = append(evicted, key) })
+	cache.onEvict("t3", EvictExpired)
+	if n := index.Sessions("u-2"); n != 0 || len(evicted) != 1 {
+		t.Errorf("Expected evicted token to be removed from index and eviction to be passed on, got %d sessions, evicted: %v", n, evicted)
+	}
+
+	if n := index.InvalidateUser("u-1"); n != 2 {
+		t.Errorf("Expected 2 invalidated tokens, got %d", n)
+	}
+	var token Token
+	if index.Get("t1", &token) || index.Get("t2", &token) {
+		t.Error("Expected tokens of u-1 to be removed from the cache")
+	}
+	if index.Sessions("u-1") != 0 {
+		t.Error("Expected no sessions after invalidation")
+	}
+	var s string
+	if !index.Get("other", &s) || s != "not a token" {
+		t.Error("Expected unrelated entries to be untouched")
+	}
+}
+
+func TestUserIndexSweep(t *testing.T) {
+	index := NewUserIndex(&cacheMock{})
+	index.Set("t1", userToken("u-1"), time.Minute)
+	index.Set("t2", userToken("u-2"), -time.Second)
+	if _, ok := index.users["u-2"]; !ok {
+		t.Fatal("Expected expired entries to be kept until the next sweep")
+	}
+
+	index.lastSweep = time.Now().Add(-userIndexSweepInterval)
+	index.Set("t3", userToken("u-3"), time.Minute)
+	if _, ok := index.users["u-2"]; ok {
+		t.Error("Expected expired entries of other users to be swept")
+	}
+	if _, ok := index.keys["t2"]; ok {
+		t.Error("Expected expired key to be swept")
+	}
+	if len(index.users) != 2 {
+		t.Errorf("Expected 2 indexed users, got %d", len(index.users))
+	}
+}